@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/chai-rs/sevenhunter/internal/repo"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+)
+
+// bindUserCache puts the user cache in front of the repository unless USER_CACHE_SIZE is 0
+func bindUserCache() {
+	if conf.User.CacheSize <= 0 {
+		return
+	}
+
+	registry.UserCache = repo.NewCachedUserRepo(repo.CachedUserRepoOpts{
+		Repo: registry.UserRepo,
+		Size: conf.User.CacheSize,
+		TTL:  conf.User.CacheTTL,
+	})
+	registry.UserRepo = registry.UserCache
+}
+
+// watchUserChanges follows the users changed by other replicas when USER_CACHE_CHANGE_STREAM is set
+func watchUserChanges() (stop func() error) {
+	noop := func() error { return nil }
+	if !conf.User.CacheChangeStream || registry.UserCache == nil {
+		return noop
+	}
+	if conf.Storage.Driver != "mongo" {
+		logx.Panic().Str("driver", conf.Storage.Driver).Msg("the user cache change stream needs STORAGE_DRIVER=mongo")
+	}
+
+	stop, err := repo.WatchUserChanges(mongoDatabase(), registry.UserCache)
+	if err != nil {
+		logx.Panic().Err(err).Msg("failed to watch user changes, change streams need a replica set")
+	}
+
+	return stop
+}
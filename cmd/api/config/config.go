@@ -2,9 +2,15 @@ package config
 
 import (
 	"github.com/chai-rs/sevenhunter/pkg/config"
+	"github.com/chai-rs/sevenhunter/pkg/mongo"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 func Read() *Config {
 	return config.MustNew[Config]("")
 }
+
+// ReadMongo only reads the Mongo settings, for commands that need nothing else
+func ReadMongo() *mongo.Config {
+	return config.MustNew[mongo.Config]("mongo")
+}
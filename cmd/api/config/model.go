@@ -1,27 +1,92 @@
 package config
 
 import (
+	"time"
+
+	"github.com/chai-rs/sevenhunter/pkg/blob"
 	"github.com/chai-rs/sevenhunter/pkg/jwt"
+	"github.com/chai-rs/sevenhunter/pkg/mail"
 	"github.com/chai-rs/sevenhunter/pkg/mongo"
+	"github.com/chai-rs/sevenhunter/pkg/postgres"
+	"github.com/chai-rs/sevenhunter/pkg/sqlite"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
 )
 
 type Config struct {
-	Mongo     *mongo.Config           `required:"true"`
-	Auth      *jwt.TokenManagerConfig `required:"true"`
-	App       *AppConfig              `required:"true"`
-	Scheduler *SchedulerConfig        `required:"true"`
+	Storage     *StorageConfig          `required:"true"`
+	Mongo       *mongo.Config           `required:"true"`
+	Postgres    *postgres.Config        `required:"true"`
+	SQLite      *sqlite.Config          `required:"true"`
+	Auth        *jwt.TokenManagerConfig `required:"true"`
+	App         *AppConfig              `required:"true"`
+	Scheduler   *SchedulerConfig        `required:"true"`
+	Tracing     *tracing.Config         `required:"true"`
+	RateLimit   *RateLimitConfig        `split_words:"true" required:"true"`
+	Idempotency *IdempotencyConfig      `required:"true"`
+	User        *UserConfig             `required:"true"`
+	Blob        *blob.Config            `required:"true"`
+	Mail        *mail.Config            `required:"true"`
+	Events      *EventsConfig           `required:"true"`
 }
 
 type AppConfig struct {
-	Port               string `env:"APP_PORT" default:"8080"`
-	CorsAllowedOrigins string `env:"APP_CORS_ALLOWED_ORIGINS" default:"*"`
-	CorsAllowedMethods string `env:"APP_CORS_ALLOWED_METHODS" default:"GET,POST,PUT,DELETE,OPTIONS"`
+	Port               string        `env:"APP_PORT" default:"8080"`
+	CorsAllowedOrigins string        `env:"APP_CORS_ALLOWED_ORIGINS" default:"*"`
+	CorsAllowedMethods string        `env:"APP_CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE,OPTIONS"`
+	ShutdownDrainDelay time.Duration `env:"APP_SHUTDOWN_DRAIN_DELAY" split_words:"true" default:"5s"`
+	CursorSecret       string        `env:"APP_CURSOR_SECRET" split_words:"true"`
+	PublicURL          string        `env:"APP_PUBLIC_URL" split_words:"true" default:"http://localhost:8080"`
 }
 
 func (c AppConfig) Address() string {
 	return ":" + c.Port
 }
 
+type StorageConfig struct {
+	// Driver is mongo, postgres, sqlite or memory, memory keeps everything in process and loses it on restart
+	Driver string `default:"mongo"`
+}
+
 type SchedulerConfig struct {
 	UserCount string `env:"SCHEDULER_USER_COUNT" default:"*/10 * * * * *"`
+	UserPurge string `split_words:"true" default:"0 0 * * * *"`
+}
+
+type RateLimitConfig struct {
+	Store      string        `default:"memory"`
+	AuthLimit  int           `split_words:"true" default:"10"`
+	AuthWindow time.Duration `split_words:"true" default:"1m"`
+	AuthKey    string        `split_words:"true" default:"ip"`
+	UserLimit  int           `split_words:"true" default:"120"`
+	UserWindow time.Duration `split_words:"true" default:"1m"`
+	UserKey    string        `split_words:"true" default:"user"`
+}
+
+type IdempotencyConfig struct {
+	// Store is mongo or memory, it is mongo when the users are kept in Mongo and memory otherwise
+	Store   string
+	TTL     time.Duration `default:"24h"`
+	LockTTL time.Duration `split_words:"true" default:"1m"`
+}
+
+type EventsConfig struct {
+	// Workers run the async subscribers of the domain events
+	Workers   int `default:"4"`
+	QueueSize int `split_words:"true" default:"1024"`
+	// ShutdownTimeout is how long the async subscribers get to finish the queued events on shutdown
+	ShutdownTimeout time.Duration `split_words:"true" default:"10s"`
+}
+
+type UserConfig struct {
+	DeletionGracePeriod time.Duration `split_words:"true" default:"720h"`
+	AvatarMaxBytes      int64         `split_words:"true" default:"2097152"`
+	EmailChangeTTL      time.Duration `envconfig:"EMAIL_CHANGE_TTL" split_words:"true" default:"24h"`
+	EmailConfirmURL     string        `envconfig:"EMAIL_CONFIRM_URL" split_words:"true"`
+	// LoginHistoryRetention only applies to new entries, the TTL index drops them when they expire
+	LoginHistoryRetention time.Duration `split_words:"true" default:"2160h"`
+	// CacheSize is the number of users kept in memory for lookups by id, 0 turns the cache off
+	CacheSize int           `split_words:"true" default:"10000"`
+	CacheTTL  time.Duration `envconfig:"CACHE_TTL" split_words:"true" default:"30s"`
+	// CacheChangeStream drops users changed by other replicas from the cache, it needs Mongo as a replica set
+	CacheChangeStream bool `split_words:"true"`
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/internal/event"
+)
+
+// bindEvents creates the bus the services publish their domain events to, subscribers are added here.
+// Subscribe runs a subscriber before the response is sent, SubscribeAsync in the background for
+// anything slow such as mail or calls to other services.
+func bindEvents() {
+	bus := event.NewBus(event.BusOpts{
+		Workers:   conf.Events.Workers,
+		QueueSize: conf.Events.QueueSize,
+	})
+	registry.Events = bus
+}
+
+// closeEvents gives the async subscribers EVENTS_SHUTDOWN_TIMEOUT to handle the queued events
+func closeEvents() error {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Events.ShutdownTimeout)
+	defer cancel()
+
+	return registry.Events.Close(ctx)
+}
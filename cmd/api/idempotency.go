@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/chai-rs/sevenhunter/pkg/idempotency"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+)
+
+// newIdempotencyStore keeps the keys next to the users by default, drivers without a store of their own
+// fall back to memory so that they do not need Mongo
+func newIdempotencyStore() idempotency.Store {
+	store := conf.Idempotency.Store
+	if store == "" {
+		store = "memory"
+		if conf.Storage.Driver == "mongo" {
+			store = "mongo"
+		}
+	}
+
+	switch store {
+	case "mongo":
+		reconcileMongo(idempotency.MongoSchema)
+		return idempotency.NewMongoStore(mongoDatabase())
+	case "memory":
+		return idempotency.NewMemoryStore()
+	}
+
+	logx.Panic().Str("store", store).Msg("unknown idempotency store")
+	return nil
+}
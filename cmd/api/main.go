@@ -1,30 +1,61 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+
 	"github.com/chai-rs/sevenhunter/cmd/api/config"
 	_ "github.com/chai-rs/sevenhunter/docs"
+	"github.com/chai-rs/sevenhunter/internal/middleware"
 	"github.com/chai-rs/sevenhunter/internal/router"
+	"github.com/chai-rs/sevenhunter/pkg/cursor"
 	fx "github.com/chai-rs/sevenhunter/pkg/fiber"
+	"github.com/chai-rs/sevenhunter/pkg/health"
 	logx "github.com/chai-rs/sevenhunter/pkg/logger"
 	_ "github.com/chai-rs/sevenhunter/pkg/logger/autoload"
+	"github.com/chai-rs/sevenhunter/pkg/metrics"
 	"github.com/gofiber/contrib/fiberzerolog"
+	"github.com/gofiber/contrib/otelfiber/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/swagger"
 	"github.com/samber/lo"
 )
 
+const apiPrefix = "/v1/api"
+
 var (
 	registry *Registry
 	conf     *config.Config
 )
 
-func init() {
+// setup reads the configuration and builds the dependencies of the server
+func setup() {
 	conf = config.Read()
 	registry = &Registry{
-		MongoDB:      conf.Mongo.MustDatabase(),
 		TokenManager: conf.Auth.New(),
+		Health:       health.NewRegistry(),
+		Cursors:      cursor.NewCodec(lo.CoalesceOrEmpty(conf.App.CursorSecret, conf.Auth.Secret)),
+	}
+	bindRepos()
+	bindUserCache()
+	registry.Idempotency = newIdempotencyStore()
+
+	blobs, err := conf.Blob.New()
+	if err != nil {
+		logx.Fatal().Err(err).Msg("failed to create blob store")
 	}
+	registry.Blobs = blobs
+
+	mailer, err := conf.Mail.New()
+	if err != nil {
+		logx.Fatal().Err(err).Msg("failed to create mailer")
+	}
+	registry.Mailer = mailer
+	bindEvents()
+
+	registry.Health.Register("signing_key", registry.TokenManager)
 }
 
 // @title SevenHunter API
@@ -44,6 +75,19 @@ func init() {
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 func main() {
+	flag.Parse()
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			logx.Fatal().Err(err).Msg("failed to migrate")
+		}
+		return
+	}
+
+	setup()
+
+	// Initialize tracing
+	shutdownTracing := conf.Tracing.MustInit(context.Background())
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler:   fx.ErrorHandler,
@@ -60,18 +104,28 @@ func main() {
 		Logger: lo.ToPtr(logx.ConsoleWriter()),
 	}))
 
+	app.Use(otelfiber.Middleware(otelfiber.WithoutMetrics(true)))
+	app.Use(metrics.Middleware())
+
 	// Bind API routes
 	bindAPI(app)
 	app.Get("/swagger/*", swagger.HandlerDefault)
+	app.Get("/metrics", metrics.Handler())
+
+	// Follow changes made by other replicas
+	stopUserWatch := watchUserChanges()
 
 	// Start schedulers
-	shutdownScheduler := startScheduler()
+	shutdownScheduler, schedulerChecker := startScheduler()
+	registry.Health.Register("scheduler", schedulerChecker)
 
 	// Start the server
 	if err := fx.Start(app, fx.StartOpts{
-		Address: conf.App.Address(),
+		Address:    conf.App.Address(),
+		Health:     registry.Health,
+		DrainDelay: conf.App.ShutdownDrainDelay,
 		ShutdownFn: func() error {
-			return shutdownScheduler()
+			return errors.Join(shutdownScheduler(), stopUserWatch(), closeEvents(), shutdownTracing())
 		},
 	}); err != nil {
 		logx.Error().Err(err).Msg("failed to start application")
@@ -80,18 +134,41 @@ func main() {
 }
 
 func bindAPI(app *fiber.App) {
-	api := app.Group("/v1/api")
-	db := registry.MongoDB
+	api := app.Group(apiPrefix)
+	limitStore := newRateLimitStore()
+	limits := conf.RateLimit
 
 	// Auth
 	router.BindAuth(api, router.BindAuthOpts{
-		DB:           db,
-		TokenManager: registry.TokenManager,
+		UserRepo:              registry.UserRepo,
+		LoginHistory:          registry.LoginHistory,
+		UnitOfWork:            registry.UnitOfWork,
+		Events:                registry.Events,
+		TokenManager:          registry.TokenManager,
+		DeletionGracePeriod:   conf.User.DeletionGracePeriod,
+		LoginHistoryRetention: conf.User.LoginHistoryRetention,
+		RateLimit:             newRateLimit(limitStore, "auth", limits.AuthLimit, limits.AuthWindow, limits.AuthKey),
+		Idempotency: middleware.IdempotencyOpts{
+			Store:   registry.Idempotency,
+			TTL:     conf.Idempotency.TTL,
+			LockTTL: conf.Idempotency.LockTTL,
+		},
 	})
 
 	// User
 	router.BindUser(api, router.BindUserOpts{
-		DB:           db,
-		TokenManager: registry.TokenManager,
+		UserRepo:        registry.UserRepo,
+		LoginHistory:    registry.LoginHistory,
+		UnitOfWork:      registry.UnitOfWork,
+		Events:          registry.Events,
+		TokenManager:    registry.TokenManager,
+		Cursors:         registry.Cursors,
+		Blobs:           registry.Blobs,
+		AvatarURLPrefix: conf.App.PublicURL + apiPrefix + "/avatars",
+		AvatarMaxBytes:  conf.User.AvatarMaxBytes,
+		Mailer:          registry.Mailer,
+		EmailChangeTTL:  conf.User.EmailChangeTTL,
+		EmailConfirmURL: conf.User.EmailConfirmURL,
+		RateLimit:       newRateLimit(limitStore, "users", limits.UserLimit, limits.UserWindow, limits.UserKey),
 	})
 }
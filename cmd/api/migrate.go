@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/cmd/api/config"
+	"github.com/chai-rs/sevenhunter/migrations"
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+)
+
+const migrateUsage = "usage: main migrate up|down|status"
+
+// runMigrate runs `main migrate up|down|status` against the Mongo database, it only needs the MONGO_ settings
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	mongoConf := config.ReadMongo()
+	ctx := context.Background()
+	client, err := mongoConf.New(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Disconnect(ctx) }()
+
+	migrator, err := migrate.New(migrate.Opts{
+		DB:         client.Database(mongoConf.Database),
+		Migrations: migrations.All(),
+	})
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", len(applied))
+		return nil
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %s\n", reverted)
+		return nil
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatus(statuses)
+	}
+
+	return errors.New(migrateUsage)
+}
+
+func printMigrationStatus(statuses []migrate.Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "PENDING"
+		if status.Applied() {
+			appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", status.ID, appliedAt)
+	}
+	return w.Flush()
+}
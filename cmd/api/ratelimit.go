@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/middleware"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/chai-rs/sevenhunter/pkg/ratelimit"
+)
+
+func newRateLimitStore() ratelimit.Store {
+	switch conf.RateLimit.Store {
+	case "mongo":
+		reconcileMongo(ratelimit.MongoSchema)
+		return ratelimit.NewMongoStore(mongoDatabase())
+	case "memory":
+		return ratelimit.NewMemoryStore()
+	}
+
+	logx.Panic().Str("store", conf.RateLimit.Store).Msg("unknown rate limit store")
+	return nil
+}
+
+func newRateLimit(store ratelimit.Store, name string, limit int, window time.Duration, key string) middleware.RateLimitOpts {
+	opts := middleware.RateLimitOpts{
+		Name: name,
+		Limiter: ratelimit.NewLimiter(store, ratelimit.Policy{
+			Limit:  limit,
+			Window: window,
+		}),
+	}
+
+	switch key {
+	case "ip":
+		opts.Key = middleware.KeyByIP
+	case "user":
+		opts.Key = middleware.KeyByUserID
+	case "api_key":
+		opts.Key = middleware.KeyByAPIKey
+	default:
+		logx.Panic().Str("key", key).Str("limiter", name).Msg("unknown rate limit key")
+	}
+
+	return opts
+}
@@ -1,11 +1,35 @@
 package main
 
 import (
+	"database/sql"
+
+	"github.com/chai-rs/sevenhunter/internal/event"
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo"
+	"github.com/chai-rs/sevenhunter/pkg/blob"
+	"github.com/chai-rs/sevenhunter/pkg/cursor"
+	"github.com/chai-rs/sevenhunter/pkg/health"
+	"github.com/chai-rs/sevenhunter/pkg/idempotency"
 	"github.com/chai-rs/sevenhunter/pkg/jwt"
+	"github.com/chai-rs/sevenhunter/pkg/mail"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Registry struct {
+	// MongoDB, Postgres and SQLite are nil when nothing is stored in them
 	MongoDB      *mongo.Database
+	Postgres     *pgxpool.Pool
+	SQLite       *sql.DB
+	UserRepo     model.UserRepo
+	UserCache    *repo.CachedUserRepo // wraps UserRepo, nil when the cache is off
+	LoginHistory model.LoginHistoryRepo
+	UnitOfWork   model.UnitOfWork
+	Events       *event.Bus
 	TokenManager *jwt.TokenManager
+	Health       *health.Registry
+	Cursors      *cursor.Codec
+	Idempotency  idempotency.Store
+	Blobs        blob.Store
+	Mailer       mail.Mailer
 }
@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 
-	"github.com/chai-rs/sevenhunter/internal/repo"
+	"github.com/chai-rs/sevenhunter/internal/model"
 	"github.com/chai-rs/sevenhunter/internal/scheduler"
+	"github.com/chai-rs/sevenhunter/internal/service"
+	"github.com/chai-rs/sevenhunter/pkg/health"
 	logx "github.com/chai-rs/sevenhunter/pkg/logger"
 	"github.com/go-co-op/gocron/v2"
 )
 
-func startScheduler() (shutdown func() error) {
+func startScheduler() (shutdown func() error, checker health.CheckerFunc) {
 	sch, err := gocron.NewScheduler()
 	if err != nil {
 		logx.Fatal().Err(err).Msg("failed to create scheduler")
@@ -17,14 +22,38 @@ func startScheduler() (shutdown func() error) {
 
 	// Bind schedulers
 	bindUserCountScheduler(sch)
+	bindUserPurgeScheduler(sch)
 
 	sch.Start()
-	return sch.Shutdown
+
+	var running atomic.Bool
+	running.Store(true)
+
+	shutdown = func() error {
+		running.Store(false)
+		return sch.Shutdown()
+	}
+
+	checker = func(ctx context.Context) error {
+		if !running.Load() {
+			return errors.New("scheduler is not running")
+		}
+
+		for _, job := range sch.Jobs() {
+			if _, err := job.NextRun(); err != nil {
+				return fmt.Errorf("job %s is not scheduled: %w", job.Name(), err)
+			}
+		}
+
+		return nil
+	}
+
+	return shutdown, checker
 }
 
 func bindUserCountScheduler(sch gocron.Scheduler) {
 	userCountSchduler := scheduler.NewUserCountScheduler(scheduler.UserCountSchedulerOpts{
-		UserRepo: repo.NewUserRepo(registry.MongoDB),
+		UserRepo: registry.UserRepo,
 	})
 
 	_, err := sch.NewJob(
@@ -38,3 +67,30 @@ func bindUserCountScheduler(sch gocron.Scheduler) {
 		logx.Panic().Err(err).Msg("failed to create user-count job")
 	}
 }
+
+func bindUserPurgeScheduler(sch gocron.Scheduler) {
+	userRepo := registry.UserRepo
+	userPurgeScheduler := scheduler.NewUserPurgeScheduler(scheduler.UserPurgeSchedulerOpts{
+		UserRepo:    userRepo,
+		GracePeriod: conf.User.DeletionGracePeriod,
+		Purgers: []model.UserDataPurger{
+			// stored responses of idempotent requests may embed the user
+			model.UserDataPurgerFunc(registry.Idempotency.DeletePrincipal),
+			service.NewAvatarService(service.AvatarServiceOpts{
+				UserRepo: userRepo,
+				Blobs:    registry.Blobs,
+			}),
+			registry.LoginHistory,
+		},
+	})
+
+	_, err := sch.NewJob(
+		gocron.CronJob(conf.Scheduler.UserPurge, true),
+		gocron.NewTask(userPurgeScheduler.Run, context.Background()),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+
+	if err != nil {
+		logx.Panic().Err(err).Msg("failed to create user-purge job")
+	}
+}
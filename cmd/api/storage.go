@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	mongox "github.com/chai-rs/sevenhunter/pkg/mongo"
+	"github.com/chai-rs/sevenhunter/pkg/mongo/schema"
+	"github.com/chai-rs/sevenhunter/pkg/postgres"
+	"github.com/chai-rs/sevenhunter/pkg/sqlite"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoDatabase connects to Mongo the first time a store needs it
+func mongoDatabase() *mongo.Database {
+	if registry.MongoDB == nil {
+		registry.MongoDB = conf.Mongo.MustDatabase()
+		registry.Health.Register("mongo", mongox.HealthChecker(registry.MongoDB.Client()))
+	}
+
+	return registry.MongoDB
+}
+
+// reconcileMongo checks the collections a store is about to use, it logs the drift and
+// stops the server when MONGO_SCHEMA_STRICT is set
+func reconcileMongo(collections ...schema.Collection) {
+	_, err := schema.Reconcile(context.Background(), schema.Opts{
+		DB:          mongoDatabase(),
+		Collections: collections,
+		Mode:        schema.Mode(conf.Mongo.SchemaMode),
+		Strict:      conf.Mongo.SchemaStrict,
+	})
+	if err != nil {
+		logx.Panic().Err(err).Msg("failed to reconcile mongo schema")
+	}
+}
+
+// postgresPool connects to Postgres and brings its schema up to date before anything uses it
+func postgresPool() *pgxpool.Pool {
+	if registry.Postgres == nil {
+		pool := conf.Postgres.MustPool()
+		if err := repo.MigratePostgres(context.Background(), pool); err != nil {
+			logx.Panic().Err(err).Msg("failed to migrate postgres")
+		}
+
+		registry.Postgres = pool
+		registry.Health.Register("postgres", postgres.HealthChecker(pool))
+	}
+
+	return registry.Postgres
+}
+
+// sqliteDB opens the SQLite file and brings its schema up to date before anything uses it
+func sqliteDB() *sql.DB {
+	if registry.SQLite == nil {
+		db := conf.SQLite.MustDB()
+		if err := repo.MigrateSQLite(context.Background(), db); err != nil {
+			logx.Panic().Err(err).Msg("failed to migrate sqlite")
+		}
+
+		registry.SQLite = db
+		registry.Health.Register("sqlite", sqlite.HealthChecker(db))
+	}
+
+	return registry.SQLite
+}
+
+// mongoUnitOfWork runs transactions when the deployment supports them, on a standalone server
+// writes that belong together are applied one by one
+func mongoUnitOfWork(client *mongo.Client) model.UnitOfWork {
+	ok, err := mongox.SupportsTransactions(context.Background(), client)
+	if err != nil {
+		logx.Panic().Err(err).Msg("failed to check mongo deployment")
+	}
+	if !ok {
+		logx.Warn().Msg("mongo is not a replica set, running without transactions")
+		return model.WithoutTransaction{}
+	}
+
+	return repo.NewUnitOfWork(client)
+}
+
+func bindRepos() {
+	switch conf.Storage.Driver {
+	case "mongo":
+		db := mongoDatabase()
+		reconcileMongo(repo.UserSchema, repo.LoginHistorySchema)
+		registry.UserRepo = repo.NewUserRepo(db)
+		registry.LoginHistory = repo.NewLoginHistoryRepo(db)
+		registry.UnitOfWork = mongoUnitOfWork(db.Client())
+		return
+	case "postgres":
+		pool := postgresPool()
+		registry.UserRepo = repo.NewUserRepoPostgres(pool)
+		registry.LoginHistory = repo.NewLoginHistoryRepoPostgres(pool)
+		registry.UnitOfWork = repo.NewUnitOfWorkPostgres(pool)
+		return
+	case "sqlite":
+		db := sqliteDB()
+		registry.UserRepo = repo.NewUserRepoSQLite(db)
+		registry.LoginHistory = repo.NewLoginHistoryRepoSQLite(db)
+		registry.UnitOfWork = repo.NewUnitOfWorkSQLite(db)
+		return
+	case "memory":
+		registry.UserRepo = repo.NewMemoryUserRepo()
+		registry.LoginHistory = repo.NewMemoryLoginHistoryRepo()
+		registry.UnitOfWork = repo.NewMemoryUnitOfWork()
+		return
+	}
+
+	logx.Panic().Str("driver", conf.Storage.Driver).Msg("unknown storage driver")
+}
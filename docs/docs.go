@@ -19,9 +19,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/login": {
-            "post": {
-                "description": "Authenticate user with email and password",
+        "/admin/users/{user_id}/status": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve the account status of any user with the history of its changes. Admins only",
                 "consumes": [
                     "application/json"
                 ],
@@ -29,23 +34,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Admin"
                 ],
-                "summary": "User login",
+                "summary": "Get the status of a user",
                 "parameters": [
                     {
-                        "description": "Login credentials",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.LoginReq"
-                        }
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully authenticated",
+                        "description": "Successfully retrieved the status",
                         "schema": {
                             "allOf": [
                                 {
@@ -55,21 +58,33 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "result": {
-                                            "$ref": "#/definitions/dto.AuthResp"
+                                            "$ref": "#/definitions/dto.UserStatusResp"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Invalid request body or validation error",
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
                     },
-                    "401": {
-                        "description": "Invalid credentials",
+                    "403": {
+                        "description": "Not an admin",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -81,11 +96,14 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/auth/refresh": {
-            "post": {
-                "description": "Generate a new access token using a refresh token",
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Move a user to another status. Allowed transitions: pending to active or suspended, active to suspended or locked, suspended or locked back to active. Suspended, locked and pending users cannot sign in and their tokens stop working. Admins only",
                 "consumes": [
                     "application/json"
                 ],
@@ -93,23 +111,30 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Admin"
                 ],
-                "summary": "Refresh access token",
+                "summary": "Change the status of a user",
                 "parameters": [
                     {
-                        "description": "Refresh token",
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status and the reason for it",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.RefreshTokenReq"
+                            "$ref": "#/definitions/dto.ChangeUserStatusReq"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "New tokens generated successfully",
+                        "description": "Successfully changed the status",
                         "schema": {
                             "allOf": [
                                 {
@@ -119,7 +144,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "result": {
-                                            "$ref": "#/definitions/dto.AuthResp"
+                                            "$ref": "#/definitions/dto.UserStatusResp"
                                         }
                                     }
                                 }
@@ -127,13 +152,43 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request body or validation error",
+                        "description": "Invalid status or missing reason",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
                     },
                     "401": {
-                        "description": "Invalid or expired refresh token",
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an admin, or changing your own status",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Transition not allowed from the current status",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "412": {
+                        "description": "User changed during the update",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -147,9 +202,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/auth/register": {
+        "/auth/login": {
             "post": {
-                "description": "Create a new user account with email and password",
+                "description": "Authenticate user with email and password",
                 "consumes": [
                     "application/json"
                 ],
@@ -159,21 +214,21 @@ const docTemplate = `{
                 "tags": [
                     "Authentication"
                 ],
-                "summary": "Register a new user",
+                "summary": "User login",
                 "parameters": [
                     {
-                        "description": "Registration details",
+                        "description": "Login credentials",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.RegisterReq"
+                            "$ref": "#/definitions/dto.LoginReq"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "User successfully registered",
+                    "200": {
+                        "description": "Successfully authenticated",
                         "schema": {
                             "allOf": [
                                 {
@@ -196,8 +251,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/fx.Response"
                         }
                     },
-                    "409": {
-                        "description": "User already exists",
+                    "401": {
+                        "description": "Invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -211,14 +272,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/users": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Retrieve a paginated list of users",
+        "/auth/refresh": {
+            "post": {
+                "description": "Generate a new access token using a refresh token",
                 "consumes": [
                     "application/json"
                 ],
@@ -226,34 +282,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Authentication"
                 ],
-                "summary": "List users",
+                "summary": "Refresh access token",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Pagination cursor",
-                        "name": "cursor",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Number of items per page (max 100)",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "boolean",
-                        "default": false,
-                        "description": "Sort in ascending order",
-                        "name": "sort_asc",
-                        "in": "query"
+                        "description": "Refresh token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RefreshTokenReq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved users list",
+                        "description": "New tokens generated successfully",
                         "schema": {
                             "allOf": [
                                 {
@@ -263,18 +308,27 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "result": {
-                                            "type": "array",
-                                            "items": {
-                                                "$ref": "#/definitions/dto.UserResp"
-                                            }
+                                            "$ref": "#/definitions/dto.AuthResp"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
+                    "400": {
+                        "description": "Invalid request body or validation error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized - invalid or missing token",
+                        "description": "Invalid or expired refresh token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -288,14 +342,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/users/count": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Retrieve the total number of registered users",
+        "/auth/register": {
+            "post": {
+                "description": "Create a new user account with email and password",
                 "consumes": [
                     "application/json"
                 ],
@@ -303,12 +352,29 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Authentication"
+                ],
+                "summary": "Register a new user",
+                "parameters": [
+                    {
+                        "description": "Registration details",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RegisterReq"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Answers retries with the same key and body like the first request, with new tokens for the account it created",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
                 ],
-                "summary": "Get total user count",
                 "responses": {
-                    "200": {
-                        "description": "Successfully retrieved user count",
+                    "201": {
+                        "description": "User successfully registered",
                         "schema": {
                             "allOf": [
                                 {
@@ -318,15 +384,33 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "result": {
-                                            "$ref": "#/definitions/dto.CountUsersResp"
+                                            "$ref": "#/definitions/dto.AuthResp"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized - invalid or missing token",
+                    "400": {
+                        "description": "Invalid request body or validation error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "User already exists or a request with the same idempotency key is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency key already used with a different request",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -340,14 +424,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/users/profile": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Retrieve the authenticated user's profile information",
+        "/auth/restore": {
+            "post": {
+                "description": "Undo an account deletion with the account credentials while the grace period is running",
                 "consumes": [
                     "application/json"
                 ],
@@ -355,12 +434,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Users"
+                    "Authentication"
+                ],
+                "summary": "Restore a deleted account",
+                "parameters": [
+                    {
+                        "description": "Credentials of the deleted account",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.LoginReq"
+                        }
+                    }
                 ],
-                "summary": "Get current user profile",
                 "responses": {
                     "200": {
-                        "description": "Successfully retrieved user profile",
+                        "description": "Account restored and authenticated",
                         "schema": {
                             "allOf": [
                                 {
@@ -370,21 +460,33 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "result": {
-                                            "$ref": "#/definitions/dto.UserResp"
+                                            "$ref": "#/definitions/dto.AuthResp"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized - invalid or missing token",
+                    "400": {
+                        "description": "Invalid request body or credentials",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
                     },
                     "404": {
-                        "description": "User not found",
+                        "description": "No deleted account with this email",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "410": {
+                        "description": "Grace period is over",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -396,16 +498,713 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "security": [
-                    {
+            }
+        },
+        "/avatars/{user_id}": {
+            "get": {
+                "description": "Serve an avatar thumbnail, the URL is taken from avatar_url of the profile",
+                "produces": [
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get a user avatar",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            64,
+                            128,
+                            256
+                        ],
+                        "type": "integer",
+                        "default": 256,
+                        "description": "Thumbnail size in pixels",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Avatar version, set by avatar_url",
+                        "name": "v",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Avatar thumbnail",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified"
+                    },
+                    "400": {
+                        "description": "Unsupported size",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User has no avatar",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve a paginated list of users",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "List users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Opaque pagination cursor, taken from pagination.next_cursor or pagination.prev_cursor. It keeps the sort of the listing it came from",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of items per page (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created_at",
+                            "name",
+                            "email"
+                        ],
+                        "type": "string",
+                        "default": "created_at",
+                        "description": "Sort field, ties are broken by id",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Sort in ascending order",
+                        "name": "sort_asc",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name prefix",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Email prefix",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive substring of the name or email",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Created at or after, unix milliseconds",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Created at or before, unix milliseconds",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "admin"
+                        ],
+                        "type": "string",
+                        "description": "Role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "active",
+                            "suspended",
+                            "locked"
+                        ],
+                        "type": "string",
+                        "description": "Account status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved users list",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/dto.UserResp"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filters or cursor",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/count": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve the total number of registered users",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get total user count",
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved user count",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "$ref": "#/definitions/dto.CountUsersResp"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/email/confirm": {
+            "post": {
+                "description": "Apply a pending email change with the token sent to the new address. It does not need an access token, the confirmation token identifies the user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Confirm an email change",
+                "parameters": [
+                    {
+                        "description": "Token from the confirmation email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ConfirmEmailReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Email changed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "$ref": "#/definitions/dto.UserResp"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the updated profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or expired token, or the change is no longer pending",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Email address is already in use",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieve the authenticated user's profile information",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Get current user profile",
+                "responses": {
+                    "200": {
+                        "description": "Successfully retrieved user profile",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "$ref": "#/definitions/dto.UserResp"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the profile, send it back in If-Match when updating"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the authenticated user's profile information. A different email is not applied right away: it is returned as pending_email and a confirmation is sent to it, the current address is notified",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Update current user profile",
+                "parameters": [
+                    {
+                        "description": "Updated user information",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UpdateUserReq"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the profile being edited, the update is rejected when it has changed since",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully updated user profile",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "$ref": "#/definitions/dto.UserResp"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the updated profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or validation error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Email address is already in use",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "412": {
+                        "description": "Profile changed since the If-Match version or during the update",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete the authenticated user's account. It can be restored with POST /auth/restore until the deletion grace period is over, then it is purged",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Delete current user account",
+                "responses": {
+                    "200": {
+                        "description": "Successfully deleted user account",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Change only the fields present in the patch, a new email waits for confirmation like with PUT. Send a JSON merge patch (RFC 7386) with Content-Type application/merge-patch+json, or a JSON patch (RFC 6902) limited to add, replace and remove with Content-Type application/json-patch+json",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Users"
+                ],
+                "summary": "Partially update current user profile",
+                "parameters": [
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.PatchUserReq"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the profile being edited, the update is rejected when it has changed since",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successfully updated user profile",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.Response"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "$ref": "#/definitions/dto.UserResp"
+                                        }
+                                    }
+                                }
+                            ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the updated profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid patch or validation error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Email address is already in use",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "412": {
+                        "description": "Profile changed since the If-Match version or during the update",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported patch format",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/profile/avatar": {
+            "put": {
+                "security": [
+                    {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update the authenticated user's profile information",
+                "description": "Upload a JPEG, PNG, GIF or WebP image. It is cropped to a square and stored as JPEG thumbnails, the profile avatar_url then points at them",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
@@ -413,21 +1212,19 @@ const docTemplate = `{
                 "tags": [
                     "Users"
                 ],
-                "summary": "Update current user profile",
+                "summary": "Upload current user avatar",
                 "parameters": [
                     {
-                        "description": "Updated user information",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.UpdateUserReq"
-                        }
+                        "type": "file",
+                        "description": "Avatar image",
+                        "name": "avatar",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Successfully updated user profile",
+                        "description": "Avatar uploaded",
                         "schema": {
                             "allOf": [
                                 {
@@ -442,10 +1239,16 @@ const docTemplate = `{
                                     }
                                 }
                             ]
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the updated profile"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Invalid request body or validation error",
+                        "description": "Missing file, invalid image or dimensions too large",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -456,8 +1259,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/fx.Response"
                         }
                     },
-                    "404": {
-                        "description": "User not found",
+                    "413": {
+                        "description": "Avatar is too large",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported image format",
+                        "schema": {
+                            "$ref": "#/definitions/fx.Response"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -469,14 +1284,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/users/profile/logins": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Delete the authenticated user's account",
+                "description": "Retrieve the sign-ins, token refreshes and restores of the authenticated user, newest first. Failed attempts are included and entries are kept for a limited time",
                 "consumes": [
                     "application/json"
                 ],
@@ -486,10 +1303,46 @@ const docTemplate = `{
                 "tags": [
                     "Users"
                 ],
-                "summary": "Delete current user account",
+                "summary": "List own login history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Opaque pagination cursor, taken from pagination.next_cursor",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Number of items per page (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Successfully deleted user account",
+                        "description": "Successfully retrieved the login history",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/fx.PaginatedResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "result": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/dto.LoginEventResp"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid cursor",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -500,8 +1353,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/fx.Response"
                         }
                     },
-                    "404": {
-                        "description": "User not found",
+                    "429": {
+                        "description": "Too many requests",
                         "schema": {
                             "$ref": "#/definitions/fx.Response"
                         }
@@ -531,6 +1384,39 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.ChangeUserStatusReq": {
+            "type": "object",
+            "required": [
+                "reason",
+                "status"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "active",
+                        "suspended",
+                        "locked"
+                    ]
+                }
+            }
+        },
+        "dto.ConfirmEmailReq": {
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
         "dto.CountUsersResp": {
             "type": "object",
             "properties": {
@@ -539,6 +1425,40 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.LoginEventResp": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "ip": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "password",
+                        "refresh",
+                        "restore",
+                        "register"
+                    ]
+                },
+                "reason": {
+                    "description": "Reason is empty for a successful login",
+                    "type": "string",
+                    "example": "invalid_password"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
         "dto.LoginReq": {
             "type": "object",
             "required": [
@@ -558,6 +1478,42 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.PatchUserReq": {
+            "type": "object",
+            "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "bio": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "locale": {
+                    "type": "string",
+                    "example": "th-TH"
+                },
+                "metadata": {
+                    "description": "Metadata entries set to null are removed, the other entries are kept",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+66812345678"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Bangkok"
+                }
+            }
+        },
         "dto.RefreshTokenReq": {
             "type": "object",
             "required": [
@@ -601,21 +1557,53 @@ const docTemplate = `{
                 "name"
             ],
             "properties": {
+                "avatar_url": {
+                    "type": "string",
+                    "maxLength": 2048
+                },
+                "bio": {
+                    "type": "string",
+                    "maxLength": 500
+                },
                 "email": {
                     "type": "string",
                     "maxLength": 200,
                     "minLength": 5
                 },
+                "locale": {
+                    "type": "string",
+                    "example": "th-TH"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "name": {
                     "type": "string",
                     "maxLength": 32,
                     "minLength": 2
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+66812345678"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Bangkok"
                 }
             }
         },
         "dto.UserResp": {
             "type": "object",
             "properties": {
+                "avatar_url": {
+                    "type": "string"
+                },
+                "bio": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "integer"
                 },
@@ -625,8 +1613,129 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "last_login_at": {
+                    "description": "LastLoginAt is 0 when the user never signed in",
+                    "type": "integer"
+                },
+                "locale": {
+                    "type": "string",
+                    "example": "th-TH"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "name": {
                     "type": "string"
+                },
+                "pending_email": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+66812345678"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Bangkok"
+                }
+            }
+        },
+        "dto.UserStatusChangeResp": {
+            "type": "object",
+            "properties": {
+                "changed_at": {
+                    "type": "integer"
+                },
+                "changed_by": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.UserStatusResp": {
+            "type": "object",
+            "properties": {
+                "changed_at": {
+                    "description": "ChangedAt is 0 when the status never changed",
+                    "type": "integer"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.UserStatusChangeResp"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "fx.PaginatedResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Error or informational message",
+                    "type": "string",
+                    "example": "Operation completed successfully"
+                },
+                "pagination": {
+                    "description": "Cursors to navigate between pages",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/fx.Pagination"
+                        }
+                    ]
+                },
+                "result": {
+                    "description": "The page items"
+                },
+                "success": {
+                    "description": "Indicates if the request was successful",
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "fx.Pagination": {
+            "type": "object",
+            "properties": {
+                "has_more": {
+                    "description": "Indicates if there are items after this page",
+                    "type": "boolean",
+                    "example": true
+                },
+                "next_cursor": {
+                    "description": "Cursor to request the next page, empty on the last page",
+                    "type": "string",
+                    "example": "eyJzIjoiY3JlYXRlZF9hdCJ9.c2lnbmF0dXJl"
+                },
+                "prev_cursor": {
+                    "description": "Cursor to request the previous page, empty on the first page",
+                    "type": "string",
+                    "example": "eyJzIjoiY3JlYXRlZF9hdCJ9.c2lnbmF0dXJl"
                 }
             }
         },
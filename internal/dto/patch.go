@@ -0,0 +1,216 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+)
+
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch = func(e error) error { return errx.E(http.StatusBadRequest, e, "invalid patch: "+e.Error()) }
+)
+
+// PatchUserReq holds the profile fields a patch sets, fields left nil are not changed
+type PatchUserReq struct {
+	Name      *string `json:"name,omitempty"`
+	Email     *string `json:"email,omitempty"`
+	AvatarURL *string `json:"avatar_url,omitempty"`
+	Bio       *string `json:"bio,omitempty"`
+	Locale    *string `json:"locale,omitempty" example:"th-TH"`
+	Timezone  *string `json:"timezone,omitempty" example:"Asia/Bangkok"`
+	Phone     *string `json:"phone,omitempty" example:"+66812345678"`
+	// Metadata entries set to null are removed, the other entries are kept
+	Metadata map[string]*string `json:"metadata,omitempty"`
+	// ReplaceMetadata drops the existing metadata before Metadata is merged
+	ReplaceMetadata bool `json:"-"`
+}
+
+func (r *PatchUserReq) Model(id string) model.UpdateUserOpts {
+	return model.UpdateUserOpts{
+		ID:              id,
+		Name:            r.Name,
+		Email:           r.Email,
+		AvatarURL:       r.AvatarURL,
+		Bio:             r.Bio,
+		Locale:          r.Locale,
+		Timezone:        r.Timezone,
+		Phone:           r.Phone,
+		Metadata:        r.Metadata,
+		ReplaceMetadata: r.ReplaceMetadata,
+	}
+}
+
+// UnmarshalMergePatch reads an RFC 7386 JSON merge patch. Members set the matching field and null
+// removes it, which is only allowed for optional fields.
+func (r *PatchUserReq) UnmarshalMergePatch(body []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return ErrInvalidPatch(fmt.Errorf("merge patch must be a JSON object"))
+	}
+
+	for field, value := range members {
+		if err := r.set(field, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// UnmarshalJSONPatch reads an RFC 6902 JSON patch. Only add, replace and remove operations on
+// profile fields and metadata entries are supported, they are applied in order.
+func (r *PatchUserReq) UnmarshalJSONPatch(body []byte) error {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return ErrInvalidPatch(fmt.Errorf("JSON patch must be an array of operations"))
+	}
+
+	for _, op := range operations {
+		path, ok := strings.CutPrefix(op.Path, "/")
+		if !ok {
+			return ErrInvalidPatch(fmt.Errorf("unsupported path %q", op.Path))
+		}
+		field, key, nested := strings.Cut(path, "/")
+		if nested && (field != "metadata" || strings.Contains(key, "/")) {
+			return ErrInvalidPatch(fmt.Errorf("unsupported path %q", op.Path))
+		}
+
+		value := op.Value
+		switch op.Op {
+		case "add", "replace":
+			if value == nil {
+				return ErrInvalidPatch(fmt.Errorf("%s operation on %q needs a value", op.Op, op.Path))
+			}
+		case "remove":
+			value = json.RawMessage("null")
+		default:
+			return ErrInvalidPatch(fmt.Errorf("unsupported operation %q", op.Op))
+		}
+
+		var err error
+		switch {
+		case nested:
+			err = r.setMetadataEntry(unescapePointer(key), value)
+		case field == "metadata":
+			// unlike a merge patch, a JSON patch on the whole object replaces it
+			r.ReplaceMetadata = true
+			r.Metadata = nil
+			err = r.set(field, value)
+		default:
+			err = r.set(field, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PatchUserReq) set(field string, value json.RawMessage) error {
+	switch field {
+	case "name":
+		return setRequired(field, &r.Name, value)
+	case "email":
+		return setRequired(field, &r.Email, value)
+	case "avatar_url":
+		return setOptional(field, &r.AvatarURL, value)
+	case "bio":
+		return setOptional(field, &r.Bio, value)
+	case "locale":
+		return setOptional(field, &r.Locale, value)
+	case "timezone":
+		return setOptional(field, &r.Timezone, value)
+	case "phone":
+		return setOptional(field, &r.Phone, value)
+	case "metadata":
+		return r.mergeMetadata(value)
+	}
+
+	return ErrInvalidPatch(fmt.Errorf("unknown field %q", field))
+}
+
+// mergeMetadata merges a metadata object like RFC 7386 does, null clears every entry
+func (r *PatchUserReq) mergeMetadata(value json.RawMessage) error {
+	if string(value) == "null" {
+		r.ReplaceMetadata = true
+		r.Metadata = nil
+		return nil
+	}
+
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(value, &entries); err != nil {
+		return ErrInvalidPatch(fmt.Errorf("field %q must be an object", "metadata"))
+	}
+
+	for key, entry := range entries {
+		if err := r.setMetadataEntry(key, entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PatchUserReq) setMetadataEntry(key string, value json.RawMessage) error {
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]*string)
+	}
+
+	if string(value) == "null" {
+		r.Metadata[key] = nil
+		return nil
+	}
+
+	entry := new(string)
+	if err := json.Unmarshal(value, entry); err != nil {
+		return ErrInvalidPatch(fmt.Errorf("metadata %q must be a string", key))
+	}
+
+	r.Metadata[key] = entry
+	return nil
+}
+
+// unescapePointer decodes a JSON pointer reference token (RFC 6901)
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// setOptional sets the field or clears it on null
+func setOptional(field string, dst **string, value json.RawMessage) error {
+	if string(value) == "null" {
+		*dst = new(string)
+		return nil
+	}
+
+	return setRequired(field, dst, value)
+}
+
+func setRequired[T any](field string, dst **T, value json.RawMessage) error {
+	if string(value) == "null" {
+		return ErrInvalidPatch(fmt.Errorf("field %q cannot be removed", field))
+	}
+
+	v := new(T)
+	if err := json.Unmarshal(value, v); err != nil {
+		return ErrInvalidPatch(fmt.Errorf("field %q has the wrong type", field))
+	}
+
+	*dst = v
+	return nil
+}
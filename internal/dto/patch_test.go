@@ -0,0 +1,145 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchUserReq_UnmarshalMergePatch(t *testing.T) {
+	type Testcase struct {
+		name     string
+		input    string
+		expected PatchUserReq
+		isError  bool
+	}
+
+	testcases := []Testcase{
+		{
+			name:     "sets only the present members",
+			input:    `{"name":"John Updated"}`,
+			expected: PatchUserReq{Name: lo.ToPtr("John Updated")},
+		},
+		{
+			name:     "empty patch changes nothing",
+			input:    `{}`,
+			expected: PatchUserReq{},
+		},
+		{
+			name:     "clears optional fields on null",
+			input:    `{"bio":null,"timezone":"Asia/Bangkok"}`,
+			expected: PatchUserReq{Bio: lo.ToPtr(""), Timezone: lo.ToPtr("Asia/Bangkok")},
+		},
+		{
+			name:     "merges metadata entries",
+			input:    `{"metadata":{"theme":"dark","beta":null}}`,
+			expected: PatchUserReq{Metadata: map[string]*string{"theme": lo.ToPtr("dark"), "beta": nil}},
+		},
+		{
+			name:     "clears metadata on null",
+			input:    `{"metadata":null}`,
+			expected: PatchUserReq{ReplaceMetadata: true},
+		},
+		{
+			name:    "rejects metadata values that are not strings",
+			input:   `{"metadata":{"theme":{"color":"dark"}}}`,
+			isError: true,
+		},
+		{
+			name:    "rejects removing a required field",
+			input:   `{"email":null}`,
+			isError: true,
+		},
+		{
+			name:    "rejects unknown members",
+			input:   `{"role":"admin"}`,
+			isError: true,
+		},
+		{
+			name:    "rejects members of the wrong type",
+			input:   `{"name":42}`,
+			isError: true,
+		},
+		{
+			name:    "rejects a patch that is not an object",
+			input:   `["name"]`,
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var req PatchUserReq
+			err := req.UnmarshalMergePatch([]byte(tc.input))
+			if tc.isError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, req)
+		})
+	}
+}
+
+func TestPatchUserReq_UnmarshalJSONPatch(t *testing.T) {
+	type Testcase struct {
+		name     string
+		input    string
+		expected PatchUserReq
+		isError  bool
+	}
+
+	testcases := []Testcase{
+		{
+			name:     "applies replace and add in order",
+			input:    `[{"op":"replace","path":"/name","value":"First"},{"op":"add","path":"/email","value":"john@example.com"},{"op":"replace","path":"/name","value":"Second"}]`,
+			expected: PatchUserReq{Name: lo.ToPtr("Second"), Email: lo.ToPtr("john@example.com")},
+		},
+		{
+			name:     "sets and removes metadata entries",
+			input:    `[{"op":"add","path":"/metadata/ui~1theme","value":"dark"},{"op":"remove","path":"/metadata/beta"},{"op":"remove","path":"/phone"}]`,
+			expected: PatchUserReq{Phone: lo.ToPtr(""), Metadata: map[string]*string{"ui/theme": lo.ToPtr("dark"), "beta": nil}},
+		},
+		{
+			name:     "replaces the whole metadata",
+			input:    `[{"op":"replace","path":"/metadata","value":{"theme":"dark"}}]`,
+			expected: PatchUserReq{ReplaceMetadata: true, Metadata: map[string]*string{"theme": lo.ToPtr("dark")}},
+		},
+		{
+			name:    "rejects removing a required field",
+			input:   `[{"op":"remove","path":"/name"}]`,
+			isError: true,
+		},
+		{
+			name:    "rejects unsupported operations",
+			input:   `[{"op":"move","from":"/name","path":"/email"}]`,
+			isError: true,
+		},
+		{
+			name:    "rejects nested paths",
+			input:   `[{"op":"replace","path":"/name/first","value":"John"}]`,
+			isError: true,
+		},
+		{
+			name:    "rejects replace without value",
+			input:   `[{"op":"replace","path":"/name"}]`,
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var req PatchUserReq
+			err := req.UnmarshalJSONPatch([]byte(tc.input))
+			if tc.isError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, req)
+		})
+	}
+}
@@ -1,15 +1,28 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/chai-rs/sevenhunter/internal/model"
 	"github.com/samber/lo"
 )
 
 type UserResp struct {
-	ID        string `json:"id"`
-	Email     string `json:"email"`
-	Name      string `json:"name"`
-	CreatedAt int64  `json:"created_at"`
+	ID           string            `json:"id"`
+	Email        string            `json:"email"`
+	PendingEmail string            `json:"pending_email"`
+	Name         string            `json:"name"`
+	Role         string            `json:"role"`
+	Status       string            `json:"status"`
+	AvatarURL    string            `json:"avatar_url"`
+	Bio          string            `json:"bio"`
+	Locale       string            `json:"locale" example:"th-TH"`
+	Timezone     string            `json:"timezone" example:"Asia/Bangkok"`
+	Phone        string            `json:"phone" example:"+66812345678"`
+	Metadata     map[string]string `json:"metadata"`
+	CreatedAt    int64             `json:"created_at"`
+	// LastLoginAt is 0 when the user never signed in
+	LastLoginAt int64 `json:"last_login_at"`
 }
 
 func NewUserResp(m *model.User) *UserResp {
@@ -18,10 +31,20 @@ func NewUserResp(m *model.User) *UserResp {
 	}
 
 	return &UserResp{
-		ID:        m.ID(),
-		Name:      m.Name(),
-		Email:     m.Email(),
-		CreatedAt: m.CreatedAt().UnixMilli(),
+		ID:           m.ID(),
+		Name:         m.Name(),
+		Email:        m.Email(),
+		PendingEmail: m.PendingEmail(),
+		Role:         string(m.Role()),
+		Status:       string(m.Status()),
+		AvatarURL:    m.AvatarURL(),
+		Bio:          m.Bio(),
+		Locale:       m.Locale(),
+		Timezone:     m.Timezone(),
+		Phone:        m.Phone(),
+		Metadata:     lo.Ternary(m.Metadata() != nil, m.Metadata(), map[string]string{}),
+		CreatedAt:    m.CreatedAt().UnixMilli(),
+		LastLoginAt:  lo.Ternary(m.LastLoginAt().IsZero(), 0, m.LastLoginAt().UnixMilli()),
 	}
 }
 
@@ -32,38 +55,176 @@ func NewUsersRespList(users []model.User) []UserResp {
 }
 
 type ListUsersReq struct {
-	Cursor  string `query:"cursor"`
-	Limit   int    `query:"limit"`
-	SortAsc bool   `query:"sort_asc"`
+	Cursor      string `query:"cursor"`
+	Limit       int    `query:"limit"`
+	Sort        string `query:"sort"`
+	SortAsc     bool   `query:"sort_asc"`
+	Name        string `query:"name"`
+	Email       string `query:"email"`
+	Search      string `query:"q"`
+	CreatedFrom int64  `query:"created_from"`
+	CreatedTo   int64  `query:"created_to"`
+	Role        string `query:"role"`
+	Status      string `query:"status"`
 }
 
-func (r *ListUsersReq) Model() model.ListUserOpts {
+func (r *ListUsersReq) Model(cursor *model.UserCursor) model.ListUserOpts {
 	opts := model.ListUserOpts{}
-	opts.Cursor = r.Cursor
+	opts.Cursor = cursor
+	opts.Sort = model.UserSortField(r.Sort)
 	opts.SortAsc = r.SortAsc
+	opts.Limit = r.Limit
+	opts.NamePrefix = r.Name
+	opts.EmailPrefix = r.Email
+	opts.Search = r.Search
+	opts.Role = model.UserRole(r.Role)
+	opts.Status = model.UserStatus(r.Status)
 
-	if r.Limit <= 0 {
-		opts.Limit = 10
-	} else if r.Limit > 100 {
-		opts.Limit = 100
+	if r.CreatedFrom > 0 {
+		opts.CreatedFrom = time.UnixMilli(r.CreatedFrom)
+	}
+	if r.CreatedTo > 0 {
+		opts.CreatedTo = time.UnixMilli(r.CreatedTo)
 	}
 
 	return opts
 }
 
+// UpdateUserReq replaces the name and requests a change of the email,
+// the optional profile fields are only changed when present
 type UpdateUserReq struct {
-	Name  string `json:"name" validate:"required,min=2,max=32"`
-	Email string `json:"email" validate:"required,email,min=5,max=200"`
+	Name      string            `json:"name" validate:"required,min=2,max=32"`
+	Email     string            `json:"email" validate:"required,email,min=5,max=200"`
+	AvatarURL *string           `json:"avatar_url,omitempty" validate:"omitempty,url,max=2048"`
+	Bio       *string           `json:"bio,omitempty" validate:"omitempty,max=500"`
+	Locale    *string           `json:"locale,omitempty" example:"th-TH"`
+	Timezone  *string           `json:"timezone,omitempty" example:"Asia/Bangkok"`
+	Phone     *string           `json:"phone,omitempty" example:"+66812345678"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
 func (r *UpdateUserReq) Model(id string) model.UpdateUserOpts {
-	return model.UpdateUserOpts{
-		ID:    id,
-		Name:  r.Name,
-		Email: r.Email,
+	opts := model.UpdateUserOpts{
+		ID:        id,
+		Name:      &r.Name,
+		Email:     &r.Email,
+		AvatarURL: r.AvatarURL,
+		Bio:       r.Bio,
+		Locale:    r.Locale,
+		Timezone:  r.Timezone,
+		Phone:     r.Phone,
+	}
+
+	if r.Metadata != nil {
+		opts.ReplaceMetadata = true
+		opts.Metadata = make(map[string]*string, len(r.Metadata))
+		for key, value := range r.Metadata {
+			opts.Metadata[key] = &value
+		}
+	}
+
+	return opts
+}
+
+type ConfirmEmailReq struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ChangeUserStatusReq struct {
+	Status string `json:"status" validate:"required" enums:"pending,active,suspended,locked"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+func (r *ChangeUserStatusReq) Model(id, changedBy string) model.ChangeUserStatusOpts {
+	return model.ChangeUserStatusOpts{
+		ID:        id,
+		Status:    model.UserStatus(r.Status),
+		Reason:    r.Reason,
+		ChangedBy: changedBy,
+	}
+}
+
+type UserStatusChangeResp struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Reason    string `json:"reason"`
+	ChangedBy string `json:"changed_by"`
+	ChangedAt int64  `json:"changed_at"`
+}
+
+type UserStatusResp struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+	// ChangedAt is 0 when the status never changed
+	ChangedAt int64                  `json:"changed_at"`
+	History   []UserStatusChangeResp `json:"history"`
+}
+
+func NewUserStatusResp(m *model.User) *UserStatusResp {
+	if m == nil {
+		return nil
+	}
+
+	resp := &UserStatusResp{
+		ID:     m.ID(),
+		Status: string(m.Status()),
+		Reason: m.StatusReason(),
+		History: lo.Map(m.StatusHistory(), func(item model.UserStatusChange, _ int) UserStatusChangeResp {
+			return UserStatusChangeResp{
+				From:      string(item.From),
+				To:        string(item.To),
+				Reason:    item.Reason,
+				ChangedBy: item.ChangedBy,
+				ChangedAt: item.ChangedAt.UnixMilli(),
+			}
+		}),
+	}
+	if changedAt := m.StatusChangedAt(); !changedAt.IsZero() {
+		resp.ChangedAt = changedAt.UnixMilli()
+	}
+
+	return resp
+}
+
+type ListLoginsReq struct {
+	Cursor string `query:"cursor"`
+	Limit  int    `query:"limit"`
+}
+
+func (r *ListLoginsReq) Model(userID string, cursor *model.LoginCursor) model.ListLoginOpts {
+	return model.ListLoginOpts{
+		UserID: userID,
+		Cursor: cursor,
+		Limit:  r.Limit,
 	}
 }
 
+type LoginEventResp struct {
+	ID      string `json:"id"`
+	Method  string `json:"method" enums:"password,refresh,restore,register"`
+	Success bool   `json:"success"`
+	// Reason is empty for a successful login
+	Reason    string `json:"reason" example:"invalid_password"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	At        int64  `json:"at"`
+}
+
+func NewLoginEventsResp(events []model.LoginEvent) []LoginEventResp {
+	return lo.Map(events, func(item model.LoginEvent, _ int) LoginEventResp {
+		return LoginEventResp{
+			ID:        item.ID,
+			Method:    string(item.Method),
+			Success:   item.Success,
+			Reason:    item.Reason,
+			IP:        item.IP,
+			UserAgent: item.UserAgent,
+			At:        item.At.UnixMilli(),
+		}
+	})
+}
+
 type CountUsersResp struct {
 	Count int64 `json:"count"`
 }
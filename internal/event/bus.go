@@ -0,0 +1,205 @@
+// Package event delivers the domain events published by the services to the subscribers registered at startup
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 1024
+)
+
+// Bus is an in-process model.EventPublisher. Sync subscribers run inside Publish, one after the other in the
+// order they subscribed, so they are done before the request that caused the event returns. Async subscribers
+// run later on a pool of workers, they must not rely on the request being around anymore.
+//
+// A failing subscriber is logged and counted, it never fails the operation that published the event nor keeps
+// the other subscribers from getting it. Events are not persisted, async deliveries still queued when the
+// process dies are lost.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+	closed      bool
+	queue       chan delivery
+	workers     sync.WaitGroup
+}
+
+type BusOpts struct {
+	// Workers run the async subscribers, DefaultWorkers when zero
+	Workers int
+	// QueueSize is how many async deliveries wait for a worker before Publish blocks, DefaultQueueSize when zero
+	QueueSize int
+}
+
+type subscriber struct {
+	name   string
+	async  bool
+	handle func(ctx context.Context, event model.Event) error
+}
+
+type delivery struct {
+	ctx   context.Context
+	sub   subscriber
+	event model.Event
+}
+
+func NewBus(opts BusOpts) *Bus {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	b := &Bus{
+		subscribers: make(map[string][]subscriber),
+		queue:       make(chan delivery, queueSize),
+	}
+
+	b.workers.Add(workers)
+	for range workers {
+		go b.work()
+	}
+
+	return b
+}
+
+var _ model.EventPublisher = (*Bus)(nil)
+
+// Subscribe runs fn for every event of type E inside Publish. name identifies the subscriber in logs and metrics.
+func Subscribe[E model.Event](b *Bus, name string, fn func(ctx context.Context, event E) error) {
+	subscribe(b, name, false, fn)
+}
+
+// SubscribeAsync runs fn for every event of type E on a worker once Publish handed it over.
+// ctx carries the values of the publishing request but is never canceled, so fn must not publish
+// itself: with a full queue it would wait for the workers it keeps busy.
+func SubscribeAsync[E model.Event](b *Bus, name string, fn func(ctx context.Context, event E) error) {
+	subscribe(b, name, true, fn)
+}
+
+func subscribe[E model.Event](b *Bus, name string, async bool, fn func(ctx context.Context, event E) error) {
+	var zero E
+	eventName := zero.EventName()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventName] = append(b.subscribers[eventName], subscriber{
+		name:  name,
+		async: async,
+		handle: func(ctx context.Context, event model.Event) error {
+			typed, ok := event.(E)
+			if !ok {
+				return fmt.Errorf("event %s is a %T, not a %T", eventName, event, zero)
+			}
+			return fn(ctx, typed)
+		},
+	})
+}
+
+// Publish delivers event to its sync subscribers and queues it for the async ones.
+// It waits for room in the queue until ctx is done, the async deliveries are dropped then.
+func (b *Bus) Publish(ctx context.Context, event model.Event) {
+	name := event.EventName()
+	eventsPublished.WithLabelValues(name).Inc()
+
+	// subscribers only append, the snapshot stays valid without the lock so a subscriber can publish too
+	b.mu.RLock()
+	subs := b.subscribers[name]
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		if sub.async {
+			b.enqueue(ctx, sub, event)
+		} else {
+			b.deliver(ctx, sub, event)
+		}
+	}
+}
+
+func (b *Bus) enqueue(ctx context.Context, sub subscriber, event model.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		b.drop(sub, event, errors.New("event bus is closed"))
+		return
+	}
+
+	select {
+	case b.queue <- delivery{ctx: context.WithoutCancel(ctx), sub: sub, event: event}:
+	case <-ctx.Done():
+		b.drop(sub, event, ctx.Err())
+	}
+}
+
+// Close stops taking async deliveries and waits until the queued ones are done or ctx is
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event bus closed with %d deliveries left: %w", len(b.queue), ctx.Err())
+	}
+}
+
+func (b *Bus) work() {
+	defer b.workers.Done()
+	for d := range b.queue {
+		b.deliver(d.ctx, d.sub, d.event)
+	}
+}
+
+// deliver hands event to a subscriber, a panic is treated like an error so it cannot take the process down
+func (b *Bus) deliver(ctx context.Context, sub subscriber, event model.Event) {
+	name := event.EventName()
+	ctx, span := tracer.Start(ctx, "event."+name, trace.WithAttributes(
+		attribute.String("event.subscriber", sub.name),
+		attribute.Bool("event.async", sub.async),
+	))
+
+	var err error
+	defer tracing.End(span, &err)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+		if err != nil {
+			eventFailures.WithLabelValues(name, sub.name).Inc()
+			logx.Error().Err(err).Str("event", name).Str("subscriber", sub.name).Msg("failed to handle event")
+		}
+	}()
+
+	err = sub.handle(ctx, event)
+}
+
+func (b *Bus) drop(sub subscriber, event model.Event, reason error) {
+	eventsDropped.WithLabelValues(event.EventName(), sub.name).Inc()
+	logx.Error().Err(reason).Str("event", event.EventName()).Str("subscriber", sub.name).Msg("dropped event")
+}
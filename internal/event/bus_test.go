@@ -0,0 +1,118 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_Sync(t *testing.T) {
+	bus := NewBus(BusOpts{})
+	t.Cleanup(func() { _ = bus.Close(context.Background()) })
+
+	var got []string
+	Subscribe(bus, "first", func(_ context.Context, e model.UserDeleted) error {
+		got = append(got, "first:"+e.UserID)
+		return errors.New("subscriber failed")
+	})
+	Subscribe(bus, "panicking", func(_ context.Context, e model.UserDeleted) error {
+		panic("subscriber panicked")
+	})
+	Subscribe(bus, "second", func(_ context.Context, e model.UserDeleted) error {
+		got = append(got, "second:"+e.UserID)
+		return nil
+	})
+	Subscribe(bus, "other", func(_ context.Context, e model.PasswordChanged) error {
+		got = append(got, "other:"+e.UserID)
+		return nil
+	})
+
+	// failing subscribers do not keep the next ones from the event
+	bus.Publish(context.Background(), model.UserDeleted{UserID: "123"})
+	require.Equal(t, []string{"first:123", "second:123"}, got)
+
+	bus.Publish(context.Background(), model.UserRegistered{})
+	require.Len(t, got, 2)
+}
+
+func TestBus_Async(t *testing.T) {
+	bus := NewBus(BusOpts{Workers: 2})
+
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	SubscribeAsync(bus, "slow", func(_ context.Context, e model.PasswordChanged) error {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, e.UserID)
+		return nil
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		bus.Publish(context.Background(), model.PasswordChanged{UserID: id})
+	}
+
+	// closing waits for the queued events
+	require.NoError(t, bus.Close(context.Background()))
+	require.ElementsMatch(t, []string{"1", "2", "3"}, got)
+
+	// nothing is queued once closed
+	bus.Publish(context.Background(), model.PasswordChanged{UserID: "4"})
+	require.Len(t, got, 3)
+}
+
+func TestBus_AsyncOutlivesRequest(t *testing.T) {
+	bus := NewBus(BusOpts{})
+
+	type key struct{}
+	received := make(chan context.Context, 1)
+	SubscribeAsync(bus, "request", func(ctx context.Context, e model.UserDeleted) error {
+		received <- ctx
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	bus.Publish(ctx, model.UserDeleted{UserID: "123"})
+	cancel()
+	require.NoError(t, bus.Close(context.Background()))
+
+	got := <-received
+	require.Equal(t, "value", got.Value(key{}))
+	require.NoError(t, got.Err())
+}
+
+func TestBus_FullQueue(t *testing.T) {
+	bus := NewBus(BusOpts{Workers: 1, QueueSize: 1})
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	SubscribeAsync(bus, "blocked", func(_ context.Context, e model.UserDeleted) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	// the worker holds the first event and the queue the second one
+	bus.Publish(context.Background(), model.UserDeleted{UserID: "1"})
+	<-started
+	bus.Publish(context.Background(), model.UserDeleted{UserID: "2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	bus.Publish(ctx, model.UserDeleted{UserID: "3"})
+	require.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer closeCancel()
+	require.ErrorIs(t, bus.Close(closeCtx), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, bus.Close(context.Background()))
+}
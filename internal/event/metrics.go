@@ -0,0 +1,30 @@
+package event
+
+import (
+	"github.com/chai-rs/sevenhunter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	eventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "events",
+		Name:      "published_total",
+		Help:      "Total number of published domain events by event.",
+	}, []string{"event"})
+
+	eventFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "events",
+		Name:      "failures_total",
+		Help:      "Total number of domain events a subscriber failed to handle by event and subscriber.",
+	}, []string{"event", "subscriber"})
+
+	eventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "events",
+		Name:      "dropped_total",
+		Help:      "Total number of domain events never handed to an async subscriber by event and subscriber.",
+	}, []string{"event", "subscriber"})
+)
@@ -0,0 +1,5 @@
+package event
+
+import "github.com/chai-rs/sevenhunter/pkg/tracing"
+
+var tracer = tracing.Tracer("github.com/chai-rs/sevenhunter/internal/event")
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"context"
+
 	"github.com/chai-rs/sevenhunter/internal/dto"
+	"github.com/chai-rs/sevenhunter/internal/middleware"
 	"github.com/chai-rs/sevenhunter/internal/model"
 	fx "github.com/chai-rs/sevenhunter/pkg/fiber"
 	"github.com/gofiber/fiber/v2"
@@ -21,6 +24,14 @@ func NewAuthHandler(opts AuthHandlerOpts) *AuthHandler {
 	}
 }
 
+// clientContext carries the client of the request to the login history
+func clientContext(c *fiber.Ctx) context.Context {
+	return model.WithClientInfo(c.UserContext(), model.ClientInfo{
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+	})
+}
+
 // Register godoc
 // @Summary Register a new user
 // @Description Create a new user account with email and password
@@ -28,22 +39,38 @@ func NewAuthHandler(opts AuthHandlerOpts) *AuthHandler {
 // @Accept json
 // @Produce json
 // @Param request body dto.RegisterReq true "Registration details"
+// @Param Idempotency-Key header string false "Answers retries with the same key and body like the first request, with new tokens for the account it created"
 // @Success 201 {object} fx.Response{result=dto.AuthResp} "User successfully registered"
 // @Failure 400 {object} fx.Response "Invalid request body or validation error"
-// @Failure 409 {object} fx.Response "User already exists"
+// @Failure 409 {object} fx.Response "User already exists or a request with the same idempotency key is in progress"
+// @Failure 422 {object} fx.Response "Idempotency key already used with a different request"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	// a retry of a registration that went through gets new tokens, the first ones are never stored
+	if userID, ok := middleware.Replaying(c); ok {
+		result, err := h.service.ReplayRegister(c.UserContext(), userID)
+		if err != nil {
+			return err
+		}
+
+		fx.NoStore(c)
+		return fx.Created(c, dto.NewAuthResp(result))
+	}
+
 	var req dto.RegisterReq
 	if err := c.BodyParser(&req); err != nil {
 		return err
 	}
 
-	result, err := h.service.Register(c.Context(), *req.Model())
+	result, err := h.service.Register(c.UserContext(), *req.Model())
 	if err != nil {
 		return err
 	}
 
+	middleware.KeepForReplay(c, result.User.ID())
+	fx.NoStore(c)
 	return fx.Created(c, dto.NewAuthResp(result))
 }
 
@@ -57,6 +84,7 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 // @Success 200 {object} fx.Response{result=dto.AuthResp} "Successfully authenticated"
 // @Failure 400 {object} fx.Response "Invalid request body or validation error"
 // @Failure 401 {object} fx.Response "Invalid credentials"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
@@ -65,11 +93,12 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		return err
 	}
 
-	result, err := h.service.Login(c.Context(), *req.Model())
+	result, err := h.service.Login(clientContext(c), *req.Model())
 	if err != nil {
 		return err
 	}
 
+	fx.NoStore(c)
 	return fx.Ok(c, dto.NewAuthResp(result))
 }
 
@@ -83,6 +112,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 // @Success 200 {object} fx.Response{result=dto.AuthResp} "New tokens generated successfully"
 // @Failure 400 {object} fx.Response "Invalid request body or validation error"
 // @Failure 401 {object} fx.Response "Invalid or expired refresh token"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
@@ -91,10 +121,40 @@ func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
 		return err
 	}
 
-	result, err := h.service.RefreshToken(c.Context(), req.RefreshToken)
+	result, err := h.service.RefreshToken(clientContext(c), req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	fx.NoStore(c)
+	return fx.Ok(c, dto.NewAuthResp(result))
+}
+
+// Restore godoc
+// @Summary Restore a deleted account
+// @Description Undo an account deletion with the account credentials while the grace period is running
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body dto.LoginReq true "Credentials of the deleted account"
+// @Success 200 {object} fx.Response{result=dto.AuthResp} "Account restored and authenticated"
+// @Failure 400 {object} fx.Response "Invalid request body or credentials"
+// @Failure 404 {object} fx.Response "No deleted account with this email"
+// @Failure 410 {object} fx.Response "Grace period is over"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /auth/restore [post]
+func (h *AuthHandler) Restore(c *fiber.Ctx) error {
+	var req dto.LoginReq
+	if err := c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	result, err := h.service.Restore(clientContext(c), *req.Model())
 	if err != nil {
 		return err
 	}
 
+	fx.NoStore(c)
 	return fx.Ok(c, dto.NewAuthResp(result))
 }
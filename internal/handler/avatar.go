@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/chai-rs/sevenhunter/internal/dto"
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	fx "github.com/chai-rs/sevenhunter/pkg/fiber"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	ErrAvatarMissing = errx.M(http.StatusBadRequest, "avatar file is required")
+)
+
+const (
+	avatarFormField = "avatar"
+	// versioned avatar URLs change on every upload, so their content never changes
+	avatarCacheImmutable = "public, max-age=31536000, immutable"
+	avatarCacheDefault   = "public, max-age=300"
+)
+
+type AvatarHandler struct {
+	service model.AvatarService
+}
+
+type AvatarHandlerOpts struct {
+	Service model.AvatarService
+}
+
+func NewAvatarHandler(opts AvatarHandlerOpts) *AvatarHandler {
+	return &AvatarHandler{
+		service: opts.Service,
+	}
+}
+
+// Upload godoc
+// @Summary Upload current user avatar
+// @Description Upload a JPEG, PNG, GIF or WebP image. It is cropped to a square and stored as JPEG thumbnails, the profile avatar_url then points at them
+// @Tags Users
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param avatar formData file true "Avatar image"
+// @Success 200 {object} fx.Response{result=dto.UserResp} "Avatar uploaded"
+// @Header 200 {string} ETag "Version of the updated profile"
+// @Failure 400 {object} fx.Response "Missing file, invalid image or dimensions too large"
+// @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 413 {object} fx.Response "Avatar is too large"
+// @Failure 415 {object} fx.Response "Unsupported image format"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /users/profile/avatar [put]
+func (h *AvatarHandler) Upload(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	header, err := c.FormFile(avatarFormField)
+	if err != nil {
+		return ErrAvatarMissing
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return errx.E(http.StatusBadRequest, err, "failed to read avatar")
+	}
+	defer file.Close()
+
+	user, err := h.service.Upload(c.UserContext(), userID, file)
+	if err != nil {
+		return err
+	}
+
+	fx.SetETag(c, user.Version())
+	return fx.Ok(c, dto.NewUserResp(user))
+}
+
+// Get godoc
+// @Summary Get a user avatar
+// @Description Serve an avatar thumbnail, the URL is taken from avatar_url of the profile
+// @Tags Users
+// @Produce jpeg
+// @Param user_id path string true "User ID"
+// @Param size query int false "Thumbnail size in pixels" Enums(64, 128, 256) default(256)
+// @Param v query string false "Avatar version, set by avatar_url"
+// @Success 200 {file} binary "Avatar thumbnail"
+// @Success 304 "Not modified"
+// @Failure 400 {object} fx.Response "Unsupported size"
+// @Failure 404 {object} fx.Response "User has no avatar"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /avatars/{user_id} [get]
+func (h *AvatarHandler) Get(c *fiber.Ctx) error {
+	r, obj, err := h.service.Open(c.UserContext(), c.Params("user_id"), c.QueryInt("size", model.DefaultAvatarSize))
+	if err != nil {
+		return err
+	}
+
+	cacheControl := avatarCacheDefault
+	if c.Query("v") != "" {
+		cacheControl = avatarCacheImmutable
+	}
+	c.Set(fiber.HeaderCacheControl, cacheControl)
+	c.Set(fiber.HeaderETag, strconv.Quote(obj.ETag))
+	c.Set(fiber.HeaderLastModified, obj.ModTime.UTC().Format(http.TimeFormat))
+
+	if c.Fresh() {
+		r.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, obj.ContentType)
+	return c.SendStream(r, int(obj.Size))
+}
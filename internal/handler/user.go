@@ -2,29 +2,39 @@ package handler
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/chai-rs/sevenhunter/internal/dto"
 	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/pkg/cursor"
 	errx "github.com/chai-rs/sevenhunter/pkg/error"
 	fx "github.com/chai-rs/sevenhunter/pkg/fiber"
 	"github.com/gofiber/fiber/v2"
 )
 
+var (
+	ErrUnsupportedPatch = errx.M(http.StatusUnsupportedMediaType, "patch must be application/merge-patch+json or application/json-patch+json")
+)
+
 type UserHandler struct {
 	service model.UserService
+	cursors *cursor.Codec
 }
 
 type UserHandlerOpts struct {
 	Service model.UserService
+	Cursors *cursor.Codec
 }
 
 func NewUserHandler(opts UserHandlerOpts) *UserHandler {
 	return &UserHandler{
 		service: opts.Service,
+		cursors: opts.Cursors,
 	}
 }
 
-func (h *UserHandler) getUserID(c *fiber.Ctx) (string, error) {
+// currentUserID returns the ID of the user authenticated by middleware.Auth
+func currentUserID(c *fiber.Ctx) (string, error) {
 	userID, ok := c.Locals("user_id").(string)
 	if !ok || userID == "" {
 		return "", errx.M(http.StatusUnauthorized, "unauthorized")
@@ -40,11 +50,21 @@ func (h *UserHandler) getUserID(c *fiber.Ctx) (string, error) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param cursor query string false "Pagination cursor"
+// @Param cursor query string false "Opaque pagination cursor, taken from pagination.next_cursor or pagination.prev_cursor. It keeps the sort of the listing it came from"
 // @Param limit query int false "Number of items per page (max 100)" default(10)
+// @Param sort query string false "Sort field, ties are broken by id" Enums(created_at, name, email) default(created_at)
 // @Param sort_asc query bool false "Sort in ascending order" default(false)
-// @Success 200 {object} fx.Response{result=[]dto.UserResp} "Successfully retrieved users list"
+// @Param name query string false "Name prefix"
+// @Param email query string false "Email prefix"
+// @Param q query string false "Case-insensitive substring of the name or email"
+// @Param created_from query int false "Created at or after, unix milliseconds"
+// @Param created_to query int false "Created at or before, unix milliseconds"
+// @Param role query string false "Role" Enums(user, admin)
+// @Param status query string false "Account status" Enums(pending, active, suspended, locked)
+// @Success 200 {object} fx.PaginatedResponse{result=[]dto.UserResp} "Successfully retrieved users list"
+// @Failure 400 {object} fx.Response "Invalid filters or cursor"
 // @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /users [get]
 func (h *UserHandler) List(c *fiber.Ctx) error {
@@ -53,12 +73,42 @@ func (h *UserHandler) List(c *fiber.Ctx) error {
 		return err
 	}
 
-	users, err := h.service.List(c.Context(), req.Model())
+	var position *model.UserCursor
+	if req.Cursor != "" {
+		position = &model.UserCursor{}
+		if err := h.cursors.Decode(req.Cursor, position); err != nil {
+			return err
+		}
+	}
+
+	page, err := h.service.List(c.UserContext(), req.Model(position))
+	if err != nil {
+		return err
+	}
+
+	next, err := h.encodeCursor(page.Next)
 	if err != nil {
 		return err
 	}
 
-	return fx.Ok(c, dto.NewUsersRespList(users))
+	prev, err := h.encodeCursor(page.Prev)
+	if err != nil {
+		return err
+	}
+
+	return fx.Paginated(c, dto.NewUsersRespList(page.Users), fx.Pagination{
+		NextCursor: next,
+		PrevCursor: prev,
+		HasMore:    page.HasMore,
+	})
+}
+
+func (h *UserHandler) encodeCursor(position *model.UserCursor) (string, error) {
+	if position == nil {
+		return "", nil
+	}
+
+	return h.cursors.Encode(position)
 }
 
 // Get godoc
@@ -69,40 +119,48 @@ func (h *UserHandler) List(c *fiber.Ctx) error {
 // @Produce json
 // @Security BearerAuth
 // @Success 200 {object} fx.Response{result=dto.UserResp} "Successfully retrieved user profile"
+// @Header 200 {string} ETag "Version of the profile, send it back in If-Match when updating"
 // @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
 // @Failure 404 {object} fx.Response "User not found"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /users/profile [get]
 func (h *UserHandler) Get(c *fiber.Ctx) error {
-	userID, err := h.getUserID(c)
+	userID, err := currentUserID(c)
 	if err != nil {
 		return err
 	}
 
-	user, err := h.service.Get(c.Context(), userID)
+	user, err := h.service.Get(c.UserContext(), userID)
 	if err != nil {
 		return err
 	}
 
+	fx.SetETag(c, user.Version())
 	return fx.Ok(c, dto.NewUserResp(user))
 }
 
 // Update godoc
 // @Summary Update current user profile
-// @Description Update the authenticated user's profile information
+// @Description Update the authenticated user's profile information. A different email is not applied right away: it is returned as pending_email and a confirmation is sent to it, the current address is notified
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body dto.UpdateUserReq true "Updated user information"
+// @Param If-Match header string false "ETag of the profile being edited, the update is rejected when it has changed since"
 // @Success 200 {object} fx.Response{result=dto.UserResp} "Successfully updated user profile"
+// @Header 200 {string} ETag "Version of the updated profile"
 // @Failure 400 {object} fx.Response "Invalid request body or validation error"
 // @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
 // @Failure 404 {object} fx.Response "User not found"
+// @Failure 409 {object} fx.Response "Email address is already in use"
+// @Failure 412 {object} fx.Response "Profile changed since the If-Match version or during the update"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /users/profile [put]
 func (h *UserHandler) Update(c *fiber.Ctx) error {
-	userID, err := h.getUserID(c)
+	userID, err := currentUserID(c)
 	if err != nil {
 		return err
 	}
@@ -112,17 +170,105 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 		return err
 	}
 
-	user, err := h.service.Update(c.Context(), req.Model(userID))
+	opts := req.Model(userID)
+	opts.Versions = fx.IfMatch(c)
+
+	user, err := h.service.Update(c.UserContext(), opts)
+	if err != nil {
+		return err
+	}
+
+	fx.SetETag(c, user.Version())
+	return fx.Ok(c, dto.NewUserResp(user))
+}
+
+// Patch godoc
+// @Summary Partially update current user profile
+// @Description Change only the fields present in the patch, a new email waits for confirmation like with PUT. Send a JSON merge patch (RFC 7386) with Content-Type application/merge-patch+json, or a JSON patch (RFC 6902) limited to add, replace and remove with Content-Type application/json-patch+json
+// @Tags Users
+// @Accept json
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.PatchUserReq true "Fields to change"
+// @Param If-Match header string false "ETag of the profile being edited, the update is rejected when it has changed since"
+// @Success 200 {object} fx.Response{result=dto.UserResp} "Successfully updated user profile"
+// @Header 200 {string} ETag "Version of the updated profile"
+// @Failure 400 {object} fx.Response "Invalid patch or validation error"
+// @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 404 {object} fx.Response "User not found"
+// @Failure 409 {object} fx.Response "Email address is already in use"
+// @Failure 412 {object} fx.Response "Profile changed since the If-Match version or during the update"
+// @Failure 415 {object} fx.Response "Unsupported patch format"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /users/profile [patch]
+func (h *UserHandler) Patch(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.PatchUserReq
+	mediaType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case dto.MIMEMergePatch, fiber.MIMEApplicationJSON:
+		err = req.UnmarshalMergePatch(c.Body())
+	case dto.MIMEJSONPatch:
+		err = req.UnmarshalJSONPatch(c.Body())
+	default:
+		err = ErrUnsupportedPatch
+	}
+	if err != nil {
+		return err
+	}
+
+	opts := req.Model(userID)
+	opts.Versions = fx.IfMatch(c)
+
+	user, err := h.service.Update(c.UserContext(), opts)
+	if err != nil {
+		return err
+	}
+
+	fx.SetETag(c, user.Version())
+	return fx.Ok(c, dto.NewUserResp(user))
+}
+
+// ConfirmEmail godoc
+// @Summary Confirm an email change
+// @Description Apply a pending email change with the token sent to the new address. It does not need an access token, the confirmation token identifies the user
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param request body dto.ConfirmEmailReq true "Token from the confirmation email"
+// @Success 200 {object} fx.Response{result=dto.UserResp} "Email changed"
+// @Header 200 {string} ETag "Version of the updated profile"
+// @Failure 400 {object} fx.Response "Invalid or expired token, or the change is no longer pending"
+// @Failure 404 {object} fx.Response "User not found"
+// @Failure 409 {object} fx.Response "Email address is already in use"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /users/email/confirm [post]
+func (h *UserHandler) ConfirmEmail(c *fiber.Ctx) error {
+	var req dto.ConfirmEmailReq
+	if err := c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	user, err := h.service.ConfirmEmail(c.UserContext(), req.Token)
 	if err != nil {
 		return err
 	}
 
+	fx.SetETag(c, user.Version())
 	return fx.Ok(c, dto.NewUserResp(user))
 }
 
 // Delete godoc
 // @Summary Delete current user account
-// @Description Delete the authenticated user's account
+// @Description Delete the authenticated user's account. It can be restored with POST /auth/restore until the deletion grace period is over, then it is purged
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -130,21 +276,136 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 // @Success 200 {object} fx.Response "Successfully deleted user account"
 // @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
 // @Failure 404 {object} fx.Response "User not found"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /users/profile [delete]
 func (h *UserHandler) Delete(c *fiber.Ctx) error {
-	userID, err := h.getUserID(c)
+	userID, err := currentUserID(c)
 	if err != nil {
 		return err
 	}
 
-	if err := h.service.Delete(c.Context(), userID); err != nil {
+	if err := h.service.Delete(c.UserContext(), userID); err != nil {
 		return err
 	}
 
 	return fx.Ok(c)
 }
 
+// GetStatus godoc
+// @Summary Get the status of a user
+// @Description Retrieve the account status of any user with the history of its changes. Admins only
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User ID"
+// @Success 200 {object} fx.Response{result=dto.UserStatusResp} "Successfully retrieved the status"
+// @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 403 {object} fx.Response "Not an admin"
+// @Failure 404 {object} fx.Response "User not found"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /admin/users/{user_id}/status [get]
+func (h *UserHandler) GetStatus(c *fiber.Ctx) error {
+	user, err := h.service.Get(c.UserContext(), c.Params("user_id"))
+	if err != nil {
+		return err
+	}
+
+	return fx.Ok(c, dto.NewUserStatusResp(user))
+}
+
+// ChangeStatus godoc
+// @Summary Change the status of a user
+// @Description Move a user to another status. Allowed transitions: pending to active or suspended, active to suspended or locked, suspended or locked back to active. Suspended, locked and pending users cannot sign in and their tokens stop working. Admins only
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "User ID"
+// @Param request body dto.ChangeUserStatusReq true "New status and the reason for it"
+// @Success 200 {object} fx.Response{result=dto.UserStatusResp} "Successfully changed the status"
+// @Failure 400 {object} fx.Response "Invalid status or missing reason"
+// @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 403 {object} fx.Response "Not an admin, or changing your own status"
+// @Failure 404 {object} fx.Response "User not found"
+// @Failure 409 {object} fx.Response "Transition not allowed from the current status"
+// @Failure 412 {object} fx.Response "User changed during the update"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /admin/users/{user_id}/status [put]
+func (h *UserHandler) ChangeStatus(c *fiber.Ctx) error {
+	adminID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.ChangeUserStatusReq
+	if err := c.BodyParser(&req); err != nil {
+		return err
+	}
+
+	user, err := h.service.ChangeStatus(c.UserContext(), req.Model(c.Params("user_id"), adminID))
+	if err != nil {
+		return err
+	}
+
+	return fx.Ok(c, dto.NewUserStatusResp(user))
+}
+
+// Logins godoc
+// @Summary List own login history
+// @Description Retrieve the sign-ins, token refreshes and restores of the authenticated user, newest first. Failed attempts are included and entries are kept for a limited time
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "Opaque pagination cursor, taken from pagination.next_cursor"
+// @Param limit query int false "Number of items per page (max 100)" default(20)
+// @Success 200 {object} fx.PaginatedResponse{result=[]dto.LoginEventResp} "Successfully retrieved the login history"
+// @Failure 400 {object} fx.Response "Invalid cursor"
+// @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 429 {object} fx.Response "Too many requests"
+// @Failure 500 {object} fx.Response "Internal server error"
+// @Router /users/profile/logins [get]
+func (h *UserHandler) Logins(c *fiber.Ctx) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var req dto.ListLoginsReq
+	if err := c.QueryParser(&req); err != nil {
+		return err
+	}
+
+	var position *model.LoginCursor
+	if req.Cursor != "" {
+		position = &model.LoginCursor{}
+		if err := h.cursors.Decode(req.Cursor, position); err != nil {
+			return err
+		}
+	}
+
+	page, err := h.service.ListLogins(c.UserContext(), req.Model(userID, position))
+	if err != nil {
+		return err
+	}
+
+	var next string
+	if page.Next != nil {
+		if next, err = h.cursors.Encode(page.Next); err != nil {
+			return err
+		}
+	}
+
+	return fx.Paginated(c, dto.NewLoginEventsResp(page.Events), fx.Pagination{
+		NextCursor: next,
+		HasMore:    page.HasMore,
+	})
+}
+
 // Count godoc
 // @Summary Get total user count
 // @Description Retrieve the total number of registered users
@@ -154,10 +415,11 @@ func (h *UserHandler) Delete(c *fiber.Ctx) error {
 // @Security BearerAuth
 // @Success 200 {object} fx.Response{result=dto.CountUsersResp} "Successfully retrieved user count"
 // @Failure 401 {object} fx.Response "Unauthorized - invalid or missing token"
+// @Failure 429 {object} fx.Response "Too many requests"
 // @Failure 500 {object} fx.Response "Internal server error"
 // @Router /users/count [get]
 func (h *UserHandler) Count(c *fiber.Ctx) error {
-	count, err := h.service.Count(c.Context())
+	count, err := h.service.Count(c.UserContext())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo"
+	"github.com/chai-rs/sevenhunter/internal/service"
+	fx "github.com/chai-rs/sevenhunter/pkg/fiber"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserHandler_UpdateIfMatch(t *testing.T) {
+	type Testcase struct {
+		name    string
+		ifMatch string
+		status  int
+	}
+
+	testcases := []Testcase{
+		{name: "without header", status: http.StatusOK},
+		{name: "any version", ifMatch: "*", status: http.StatusOK},
+		{name: "current version", ifMatch: `"2", "1"`, status: http.StatusOK},
+		{name: "other version", ifMatch: `"2"`, status: http.StatusPreconditionFailed},
+		{name: "weak tags only", ifMatch: `W/"1"`, status: http.StatusPreconditionFailed},
+		{name: "garbage", ifMatch: "not-a-tag", status: http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo := repo.NewMemoryUserRepo()
+			user, err := model.NewUser(model.UserOpts{
+				Name:           "John Doe",
+				Email:          "john@example.com",
+				HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+				CreatedAt:      time.Now(),
+			})
+			require.NoError(t, err)
+			created, err := userRepo.Create(context.Background(), user)
+			require.NoError(t, err)
+
+			h := NewUserHandler(UserHandlerOpts{
+				Service: service.NewUserService(service.UserServiceOpts{UserRepo: userRepo}),
+			})
+			app := fiber.New(fiber.Config{ErrorHandler: fx.ErrorHandler})
+			app.Put("/users/profile", func(c *fiber.Ctx) error {
+				c.Locals("user_id", created.ID())
+				return c.Next()
+			}, h.Update)
+
+			req := httptest.NewRequest(http.MethodPut, "/users/profile", strings.NewReader(`{"name":"Jane Doe","email":"john@example.com"}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tc.ifMatch != "" {
+				req.Header.Set(fiber.HeaderIfMatch, tc.ifMatch)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode)
+		})
+	}
+}
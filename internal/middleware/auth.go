@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/chai-rs/sevenhunter/internal/model"
@@ -43,16 +45,34 @@ func Auth(tm *jwt.TokenManager, userRepo model.UserRepo) fiber.Handler {
 		}
 
 		userID := claims.Subject
-		exist, err := userRepo.ExistsByID(c.Context(), userID)
+		user, err := userRepo.FindByID(c.UserContext(), userID)
+		var appErr *errx.Error
+		if errors.As(err, &appErr) && appErr.Code == http.StatusNotFound {
+			return errx.M(http.StatusUnauthorized, "user not found")
+		}
 		if err != nil {
 			return err
 		}
 
-		if !exist {
-			return errx.M(http.StatusUnauthorized, "user not found")
+		// access tokens outlive a suspension or a lock, so the status is checked on every request
+		if err := user.CanSignIn(); err != nil {
+			return err
 		}
 
 		c.Locals("user_id", userID)
+		c.Locals("user_role", user.Role())
+		return c.Next()
+	}
+}
+
+// RequireRole only lets users with one of the roles through, it must run after Auth
+func RequireRole(roles ...model.UserRole) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("user_role").(model.UserRole)
+		if !slices.Contains(roles, role) {
+			return errx.M(http.StatusForbidden, "forbidden")
+		}
+
 		return c.Next()
 	}
 }
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/chai-rs/sevenhunter/pkg/idempotency"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+const (
+	maxIdempotencyKeyLength   = 255
+	anonymousPrincipal        = "anonymous"
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = time.Minute
+	// replayStateContentType marks a record that holds the state kept by KeepForReplay instead of a response
+	replayStateContentType = "application/x-idempotency-replay-state"
+	localReplayState       = "idempotency_replay_state"
+	localReplaying         = "idempotency_replaying"
+)
+
+var (
+	ErrInvalidIdempotencyKey  = errx.M(http.StatusBadRequest, "invalid idempotency key")
+	ErrIdempotencyKeyInFlight = errx.M(http.StatusConflict, "a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyMismatch = errx.M(http.StatusUnprocessableEntity, "idempotency key was already used with a different request")
+)
+
+type IdempotencyOpts struct {
+	Store idempotency.Store
+	// TTL is how long a completed response is replayed
+	TTL time.Duration
+	// LockTTL is how long an in-flight claim blocks retries if the request never completes
+	LockTTL time.Duration
+}
+
+// Idempotency replays the stored response of POST requests retried with the same Idempotency-Key header.
+// Keys are scoped to the authenticated user when it runs after Auth, and to the client IP otherwise so
+// that anonymous clients cannot replay each other's responses. Responses marked Cache-Control: no-store,
+// such as the ones carrying tokens, are never stored: the handler keeps what it needs to answer a retry
+// with KeepForReplay, without it the key is released and a retry runs again.
+func Idempotency(opts IdempotencyOpts) fiber.Handler {
+	if opts.Store == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	lockTTL := opts.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = defaultIdempotencyLockTTL
+	}
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" || c.Method() != fiber.MethodPost {
+			return c.Next()
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return ErrInvalidIdempotencyKey
+		}
+
+		principal, scope := anonymousPrincipal, anonymousPrincipal+":"+c.IP()
+		if userID, ok := c.Locals("user_id").(string); ok && userID != "" {
+			principal, scope = userID, userID
+		}
+
+		ctx := c.UserContext()
+		rec := idempotency.Record{
+			Key:         scope + ":" + key,
+			Principal:   principal,
+			RequestHash: requestHash(c),
+			ExpiresAt:   time.Now().Add(lockTTL),
+		}
+
+		existing, err := opts.Store.Begin(ctx, rec)
+		if err != nil {
+			logx.Error().Err(err).Msg("failed to claim idempotency key")
+			return errx.InternalServerError
+		}
+
+		if existing != nil {
+			switch {
+			case existing.RequestHash != rec.RequestHash:
+				return ErrIdempotencyKeyMismatch
+			case !existing.Completed:
+				return ErrIdempotencyKeyInFlight
+			}
+
+			c.Set(HeaderIdempotentReplayed, "true")
+			if existing.ContentType == replayStateContentType {
+				c.Locals(localReplaying, string(existing.Body))
+				return c.Next()
+			}
+			c.Set(fiber.HeaderContentType, existing.ContentType)
+			return c.Status(existing.StatusCode).Send(existing.Body)
+		}
+
+		// render errors here so the response that gets stored is the one the client sees
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				releaseIdempotencyKey(c, opts.Store, rec.Key)
+				return err
+			}
+		}
+
+		status := c.Response().StatusCode()
+		if status >= http.StatusInternalServerError {
+			releaseIdempotencyKey(c, opts.Store, rec.Key)
+			return nil
+		}
+
+		contentType := string(c.Response().Header.ContentType())
+		body := append([]byte(nil), c.Response().Body()...)
+		if state, ok := c.Locals(localReplayState).(string); ok {
+			contentType, body = replayStateContentType, []byte(state)
+		} else if strings.Contains(string(c.Response().Header.Peek(fiber.HeaderCacheControl)), "no-store") {
+			releaseIdempotencyKey(c, opts.Store, rec.Key)
+			return nil
+		}
+		if err := opts.Store.Complete(ctx, rec.Key, status, contentType, body, time.Now().Add(ttl)); err != nil {
+			logx.Error().Err(err).Msg("failed to store idempotent response")
+		}
+
+		return nil
+	}
+}
+
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// KeepForReplay has Idempotency store state instead of the response, for responses that must not be kept
+// such as the ones carrying tokens. A retry runs the handler again and Replaying hands it state back.
+func KeepForReplay(c *fiber.Ctx, state string) {
+	c.Locals(localReplayState, state)
+}
+
+// Replaying returns the state kept by KeepForReplay when c retries a request that already succeeded
+func Replaying(c *fiber.Ctx) (string, bool) {
+	state, ok := c.Locals(localReplaying).(string)
+	return state, ok
+}
+
+func releaseIdempotencyKey(c *fiber.Ctx, store idempotency.Store, key string) {
+	if err := store.Release(c.UserContext(), key); err != nil {
+		logx.Error().Err(err).Msg("failed to release idempotency key")
+	}
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	fx "github.com/chai-rs/sevenhunter/pkg/fiber"
+	"github.com/chai-rs/sevenhunter/pkg/idempotency"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	type Testcase struct {
+		name     string
+		arrange  func(t *testing.T, store idempotency.Store)
+		key      string
+		userID   string
+		body     string
+		status   int
+		response string
+		calls    int
+	}
+
+	const body = `{"email":"john@example.com"}`
+	hash, ip := func() (string, string) {
+		app := fiber.New()
+		var h, ip string
+		app.Post("/register", func(c *fiber.Ctx) error {
+			h, ip = requestHash(c), c.IP()
+			return nil
+		})
+		_, err := app.Test(httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+		require.NoError(t, err)
+		return h, ip
+	}()
+	anonymousStoreKey := "anonymous:" + ip + ":key-1"
+
+	testcases := []Testcase{
+		{
+			name:     "request without key is not recorded",
+			body:     body,
+			status:   http.StatusCreated,
+			response: `{"success":true,"result":1}`,
+			calls:    1,
+		},
+		{
+			name:     "first request with key runs the handler",
+			key:      "key-1",
+			body:     body,
+			status:   http.StatusCreated,
+			response: `{"success":true,"result":1}`,
+			calls:    1,
+		},
+		{
+			name: "retry replays the stored response",
+			arrange: func(t *testing.T, store idempotency.Store) {
+				_, err := store.Begin(context.Background(), idempotency.Record{
+					Key:         anonymousStoreKey,
+					RequestHash: hash,
+					ExpiresAt:   time.Now().Add(time.Minute),
+				})
+				require.NoError(t, err)
+				require.NoError(t, store.Complete(context.Background(), anonymousStoreKey, http.StatusCreated, fiber.MIMEApplicationJSON, []byte(`{"success":true,"result":0}`), time.Now().Add(time.Hour)))
+			},
+			key:      "key-1",
+			body:     body,
+			status:   http.StatusCreated,
+			response: `{"success":true,"result":0}`,
+			calls:    0,
+		},
+		{
+			name: "concurrent duplicate is rejected",
+			arrange: func(t *testing.T, store idempotency.Store) {
+				_, err := store.Begin(context.Background(), idempotency.Record{
+					Key:         anonymousStoreKey,
+					RequestHash: hash,
+					ExpiresAt:   time.Now().Add(time.Minute),
+				})
+				require.NoError(t, err)
+			},
+			key:    "key-1",
+			body:   body,
+			status: http.StatusConflict,
+			calls:  0,
+		},
+		{
+			name: "key reused with a different body is rejected",
+			arrange: func(t *testing.T, store idempotency.Store) {
+				_, err := store.Begin(context.Background(), idempotency.Record{
+					Key:         "123:key-1",
+					RequestHash: "other",
+					ExpiresAt:   time.Now().Add(time.Minute),
+				})
+				require.NoError(t, err)
+			},
+			key:    "key-1",
+			userID: "123",
+			body:   body,
+			status: http.StatusUnprocessableEntity,
+			calls:  0,
+		},
+		{
+			name: "anonymous key reused with a different body is rejected",
+			arrange: func(t *testing.T, store idempotency.Store) {
+				_, err := store.Begin(context.Background(), idempotency.Record{
+					Key:         anonymousStoreKey,
+					RequestHash: hash,
+					ExpiresAt:   time.Now().Add(time.Minute),
+				})
+				require.NoError(t, err)
+			},
+			key:    "key-1",
+			body:   `{"email":"jane@example.com"}`,
+			status: http.StatusUnprocessableEntity,
+			calls:  0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := idempotency.NewMemoryStore()
+			if tc.arrange != nil {
+				tc.arrange(t, store)
+			}
+
+			calls := 0
+			app := fiber.New(fiber.Config{ErrorHandler: fx.ErrorHandler})
+			app.Use(func(c *fiber.Ctx) error {
+				c.Locals("user_id", tc.userID)
+				return c.Next()
+			})
+			app.Use(Idempotency(IdempotencyOpts{Store: store}))
+			app.Post("/register", func(c *fiber.Ctx) error {
+				calls++
+				return fx.Created(c, calls)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(tc.body))
+			if tc.key != "" {
+				req.Header.Set(HeaderIdempotencyKey, tc.key)
+			}
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode)
+			require.Equal(t, tc.calls, calls)
+
+			if tc.response != "" {
+				respBody, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.JSONEq(t, tc.response, string(respBody))
+			}
+		})
+	}
+}
+
+func TestIdempotency_NoStore(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: fx.ErrorHandler})
+	app.Use(Idempotency(IdempotencyOpts{Store: store}))
+	app.Post("/register", func(c *fiber.Ctx) error {
+		if userID, ok := Replaying(c); ok {
+			fx.NoStore(c)
+			return fx.Created(c, "new token for "+userID)
+		}
+
+		calls++
+		if c.Query("keep") != "" {
+			KeepForReplay(c, "123")
+		}
+		fx.NoStore(c)
+		return fx.Created(c, "token")
+	})
+
+	send := func(path string) (*http.Response, string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"email":"john@example.com"}`))
+		req.Header.Set(HeaderIdempotencyKey, "key-1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(respBody)
+	}
+
+	// without state to replay the key is given up, so a retry runs again
+	resp, _ := send("/register")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = send("/register")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, 2, calls)
+
+	// with it the retry gets a response built from the state instead of the stored tokens
+	resp, respBody := send("/register?keep=1")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.JSONEq(t, `{"success":true,"result":"token"}`, respBody)
+
+	resp, respBody = send("/register?keep=1")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "true", resp.Header.Get(HeaderIdempotentReplayed))
+	require.JSONEq(t, `{"success":true,"result":"new token for 123"}`, respBody)
+	require.Equal(t, 3, calls)
+}
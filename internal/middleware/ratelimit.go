@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/chai-rs/sevenhunter/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderAPIKey             = "X-API-Key"
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+var ErrTooManyRequests = errx.M(http.StatusTooManyRequests, "too many requests")
+
+// KeyFunc identifies the client a request is counted against
+type KeyFunc func(c *fiber.Ctx) string
+
+func KeyByIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// KeyByUserID keys on the authenticated user, it must run after Auth and falls back to the client IP
+func KeyByUserID(c *fiber.Ctx) string {
+	if userID, ok := c.Locals("user_id").(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return KeyByIP(c)
+}
+
+// KeyByAPIKey keys on a hash of the X-API-Key header and falls back to the client IP
+func KeyByAPIKey(c *fiber.Ctx) string {
+	if key := c.Get(HeaderAPIKey); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:])
+	}
+	return KeyByIP(c)
+}
+
+type RateLimitOpts struct {
+	// Name scopes the counters, routes sharing a name share limits
+	Name    string
+	Limiter *ratelimit.Limiter
+	Key     KeyFunc
+}
+
+func RateLimit(opts RateLimitOpts) fiber.Handler {
+	if opts.Limiter == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	keyFn := opts.Key
+	if keyFn == nil {
+		keyFn = KeyByIP
+	}
+
+	return func(c *fiber.Ctx) error {
+		result, err := opts.Limiter.Take(c.UserContext(), opts.Name+":"+keyFn(c))
+		if err != nil {
+			// fail open, a broken limiter store must not take the API down
+			logx.Error().Err(err).Str("limiter", opts.Name).Msg("failed to apply rate limit")
+			return c.Next()
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(result.Reset.Seconds())))
+		c.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+		c.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+		c.Set(HeaderRateLimitReset, reset)
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, reset)
+			return ErrTooManyRequests
+		}
+
+		return c.Next()
+	}
+}
@@ -11,6 +11,8 @@ func (t AuthTokenType) String() string {
 const (
 	AccessToken  AuthTokenType = "access_token"
 	RefreshToken AuthTokenType = "refresh_token"
+	// EmailChangeToken confirms that the owner of a new email address asked for the change
+	EmailChangeToken AuthTokenType = "email_change_token"
 )
 
 type AccessTokenClaims struct {
@@ -25,6 +27,12 @@ type RefreshTokenClaims struct {
 	jwt.RegisteredClaims
 }
 
+type EmailChangeClaims struct {
+	Email string        `json:"email"`
+	Type  AuthTokenType `json:"type"`
+	jwt.RegisteredClaims
+}
+
 type RegisterOpts struct {
 	Name     string
 	Email    string
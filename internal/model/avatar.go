@@ -0,0 +1,40 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/chai-rs/sevenhunter/pkg/blob"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+)
+
+const (
+	DefaultAvatarSize = 256
+	// MaxAvatarDimension bounds the width and height of uploads so decoding stays cheap
+	MaxAvatarDimension = 4096
+)
+
+// AvatarSizes are the square thumbnail sizes generated for every upload, in pixels
+var AvatarSizes = []int{64, 128, 256}
+
+var (
+	ErrAvatarTooLarge        = errx.M(http.StatusRequestEntityTooLarge, "avatar is too large")
+	ErrAvatarUnsupported     = errx.M(http.StatusUnsupportedMediaType, "avatar must be a JPEG, PNG, GIF or WebP image")
+	ErrAvatarInvalidImage    = errx.M(http.StatusBadRequest, "avatar is not a valid image")
+	ErrAvatarDimensions      = errx.M(http.StatusBadRequest, fmt.Sprintf("avatar must be at most %dx%d pixels", MaxAvatarDimension, MaxAvatarDimension))
+	ErrAvatarUnsupportedSize = errx.M(http.StatusBadRequest, fmt.Sprintf("avatar size must be one of %v", AvatarSizes))
+)
+
+// AvatarKey is the blob key of a user's avatar thumbnail
+func AvatarKey(userID string, size int) string {
+	return fmt.Sprintf("avatars/%s/%d.jpg", userID, size)
+}
+
+type AvatarService interface {
+	// Upload stores the thumbnails of an image and points the user's avatar URL at them
+	Upload(ctx context.Context, userID string, r io.Reader) (*User, error)
+	// Open returns a thumbnail of a live user, the caller closes the reader
+	Open(ctx context.Context, userID string, size int) (io.ReadCloser, *blob.Object, error)
+}
@@ -0,0 +1,46 @@
+package model
+
+import "strings"
+
+// emailProviderRules rewrite the lowercased local part and domain of providers that deliver
+// several spellings of an address to the same mailbox
+var emailProviderRules = map[string]func(local string) (string, string){
+	"gmail.com":      gmailAddress,
+	"googlemail.com": gmailAddress,
+}
+
+// gmailAddress ignores dots and "+" suffixes, googlemail.com is an alias of gmail.com
+func gmailAddress(local string) (string, string) {
+	local, _, _ = strings.Cut(local, "+")
+	return strings.ReplaceAll(local, ".", ""), "gmail.com"
+}
+
+// NormalizeEmail trims the address and lowercases its domain, the local part keeps the case it was entered with
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	return email[:at+1] + strings.ToLower(email[at+1:])
+}
+
+// CanonicalEmail identifies the mailbox of an address: two addresses with the same canonical
+// form belong to the same person and cannot be used by different users
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(NormalizeEmail(email))
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+	if rule, ok := emailProviderRules[domain]; ok {
+		local, domain = rule(local)
+	}
+
+	return local + "@" + domain
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	type Testcase struct {
+		input    string
+		expected string
+	}
+
+	testcases := []Testcase{
+		{input: "john@example.com", expected: "john@example.com"},
+		{input: "  John.Doe@Example.COM ", expected: "John.Doe@example.com"},
+		{input: "not-an-email", expected: "not-an-email"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, NormalizeEmail(tc.input))
+		})
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	type Testcase struct {
+		input    string
+		expected string
+	}
+
+	testcases := []Testcase{
+		{input: "john@example.com", expected: "john@example.com"},
+		{input: " John@Example.com", expected: "john@example.com"},
+		{input: "john.doe+news@example.com", expected: "john.doe+news@example.com"},
+		{input: "John.Doe+news@Gmail.com", expected: "johndoe@gmail.com"},
+		{input: "j.o.h.n@googlemail.com", expected: "john@gmail.com"},
+		{input: "not-an-email", expected: "not-an-email"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, CanonicalEmail(tc.input))
+		})
+	}
+}
+
+func TestUser_UpdateEmail(t *testing.T) {
+	newUser := func(t *testing.T) *User {
+		user, err := NewUser(UserOpts{
+			ID:             "123",
+			Name:           "John Doe",
+			Email:          "john.doe@example.com",
+			HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			CreatedAt:      time.Now(),
+		})
+		require.NoError(t, err)
+		return user
+	}
+
+	t.Run("another spelling of the same mailbox is applied right away", func(t *testing.T) {
+		user := newUser(t)
+		email := " John.Doe@Example.COM"
+		require.NoError(t, user.Update(UpdateUserOpts{Email: &email}))
+
+		require.Equal(t, "John.Doe@example.com", user.Email())
+		require.Empty(t, user.PendingEmail())
+		require.Equal(t, []UserField{UserFieldEmail}, user.Changes())
+	})
+
+	t.Run("another mailbox waits for confirmation", func(t *testing.T) {
+		user := newUser(t)
+		email := "John@Example.com "
+		require.NoError(t, user.Update(UpdateUserOpts{Email: &email}))
+
+		require.Equal(t, "john.doe@example.com", user.Email())
+		require.Equal(t, "John@example.com", user.PendingEmail())
+		require.Equal(t, "john@example.com", CanonicalEmail(user.PendingEmail()))
+	})
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"context"
+	"time"
+)
+
+// Event is something that happened to a user, it is published once the change is stored
+type Event interface {
+	// EventName identifies the kind of event in logs and metrics, e.g. user.registered
+	EventName() string
+}
+
+// EventPublisher hands events to their subscribers
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event)
+}
+
+// NoEvents drops every event, for services nobody listens to
+type NoEvents struct{}
+
+func (NoEvents) Publish(context.Context, Event) {}
+
+// UserRegistered is published when an account is created
+type UserRegistered struct {
+	// User is a copy that subscribers are free to keep
+	User *User
+	At   time.Time
+}
+
+func (UserRegistered) EventName() string {
+	return "user.registered"
+}
+
+// UserUpdated is published when the profile, the email or the status of a user changes
+type UserUpdated struct {
+	// User is a copy of the saved user that subscribers are free to keep
+	User    *User
+	Changes []UserField
+	At      time.Time
+}
+
+func (UserUpdated) EventName() string {
+	return "user.updated"
+}
+
+// UserDeleted is published when a user deletes their account, it can still be restored during the grace period
+type UserDeleted struct {
+	UserID string
+	At     time.Time
+}
+
+func (UserDeleted) EventName() string {
+	return "user.deleted"
+}
+
+// UserLoggedIn is published for every registration and successful sign-in, token refresh and restore
+type UserLoggedIn struct {
+	UserID    string
+	Method    LoginMethod
+	IP        string
+	UserAgent string
+	At        time.Time
+}
+
+func (UserLoggedIn) EventName() string {
+	return "user.logged_in"
+}
+
+// PasswordChanged is for when a user sets a new password, the API has no way to do so yet
+type PasswordChanged struct {
+	UserID string
+	At     time.Time
+}
+
+func (PasswordChanged) EventName() string {
+	return "user.password_changed"
+}
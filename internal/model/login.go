@@ -0,0 +1,99 @@
+package model
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type LoginMethod string
+
+const (
+	LoginMethodPassword LoginMethod = "password"
+	LoginMethodRefresh  LoginMethod = "refresh"
+	LoginMethodRestore  LoginMethod = "restore"
+	// LoginMethodRegister is the sign-in that comes with creating the account
+	LoginMethodRegister LoginMethod = "register"
+)
+
+// LoginEvent is an entry of the login history of a user
+type LoginEvent struct {
+	ID      string
+	UserID  string
+	Method  LoginMethod
+	Success bool
+	// Reason explains a failure, e.g. invalid_password or the account status
+	Reason    string
+	IP        string
+	UserAgent string
+	At        time.Time
+	// ExpiresAt is when the event is dropped from the history
+	ExpiresAt time.Time
+}
+
+const LoginFailureInvalidPassword = "invalid_password"
+
+// ClientInfo describes the client a request comes from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type clientInfoKey struct{}
+
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFrom returns the client of the request, empty outside of a request
+func ClientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
+
+// LoginCursor is the position of an event within a login history, newest first
+type LoginCursor struct {
+	At time.Time `json:"at"`
+	ID string    `json:"id"`
+}
+
+type ListLoginOpts struct {
+	UserID string
+	Cursor *LoginCursor
+	Limit  int
+}
+
+func (opts ListLoginOpts) Validate() error {
+	err := v.ValidateStruct(&opts,
+		v.Field(&opts.UserID, v.Required),
+	)
+	if err != nil {
+		return errx.E(http.StatusBadRequest, err)
+	}
+
+	if opts.Cursor != nil && (opts.Cursor.ID == "" || opts.Cursor.At.IsZero()) {
+		return errx.M(http.StatusBadRequest, "invalid cursor")
+	}
+
+	return nil
+}
+
+func (opts *ListLoginOpts) GetLimit() int {
+	if opts.Limit <= 0 {
+		return 20
+	}
+	if opts.Limit > 100 {
+		return 100
+	}
+	return opts.Limit
+}
+
+type LoginPage struct {
+	Events []LoginEvent
+	// Next points after the last event, nil when there are no more events
+	Next    *LoginCursor
+	HasMore bool
+}
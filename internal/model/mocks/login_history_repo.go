@@ -0,0 +1,221 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: testify
+
+package mocks
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// NewMockLoginHistoryRepo creates a new instance of MockLoginHistoryRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockLoginHistoryRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockLoginHistoryRepo {
+	mock := &MockLoginHistoryRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockLoginHistoryRepo is an autogenerated mock type for the LoginHistoryRepo type
+type MockLoginHistoryRepo struct {
+	mock.Mock
+}
+
+type MockLoginHistoryRepo_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockLoginHistoryRepo) EXPECT() *MockLoginHistoryRepo_Expecter {
+	return &MockLoginHistoryRepo_Expecter{mock: &_m.Mock}
+}
+
+// List provides a mock function for the type MockLoginHistoryRepo
+func (_mock *MockLoginHistoryRepo) List(ctx context.Context, opts model.ListLoginOpts) (*model.LoginPage, error) {
+	ret := _mock.Called(ctx, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 *model.LoginPage
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ListLoginOpts) (*model.LoginPage, error)); ok {
+		return returnFunc(ctx, opts)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ListLoginOpts) *model.LoginPage); ok {
+		r0 = returnFunc(ctx, opts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.LoginPage)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, model.ListLoginOpts) error); ok {
+		r1 = returnFunc(ctx, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockLoginHistoryRepo_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockLoginHistoryRepo_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+//   - opts model.ListLoginOpts
+func (_e *MockLoginHistoryRepo_Expecter) List(ctx interface{}, opts interface{}) *MockLoginHistoryRepo_List_Call {
+	return &MockLoginHistoryRepo_List_Call{Call: _e.mock.On("List", ctx, opts)}
+}
+
+func (_c *MockLoginHistoryRepo_List_Call) Run(run func(ctx context.Context, opts model.ListLoginOpts)) *MockLoginHistoryRepo_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.ListLoginOpts
+		if args[1] != nil {
+			arg1 = args[1].(model.ListLoginOpts)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockLoginHistoryRepo_List_Call) Return(loginPage *model.LoginPage, err error) *MockLoginHistoryRepo_List_Call {
+	_c.Call.Return(loginPage, err)
+	return _c
+}
+
+func (_c *MockLoginHistoryRepo_List_Call) RunAndReturn(run func(ctx context.Context, opts model.ListLoginOpts) (*model.LoginPage, error)) *MockLoginHistoryRepo_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// PurgeUserData provides a mock function for the type MockLoginHistoryRepo
+func (_mock *MockLoginHistoryRepo) PurgeUserData(ctx context.Context, userID string) error {
+	ret := _mock.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeUserData")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockLoginHistoryRepo_PurgeUserData_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'PurgeUserData'
+type MockLoginHistoryRepo_PurgeUserData_Call struct {
+	*mock.Call
+}
+
+// PurgeUserData is a helper method to define mock.On call
+//   - ctx context.Context
+//   - userID string
+func (_e *MockLoginHistoryRepo_Expecter) PurgeUserData(ctx interface{}, userID interface{}) *MockLoginHistoryRepo_PurgeUserData_Call {
+	return &MockLoginHistoryRepo_PurgeUserData_Call{Call: _e.mock.On("PurgeUserData", ctx, userID)}
+}
+
+func (_c *MockLoginHistoryRepo_PurgeUserData_Call) Run(run func(ctx context.Context, userID string)) *MockLoginHistoryRepo_PurgeUserData_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockLoginHistoryRepo_PurgeUserData_Call) Return(err error) *MockLoginHistoryRepo_PurgeUserData_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockLoginHistoryRepo_PurgeUserData_Call) RunAndReturn(run func(ctx context.Context, userID string) error) *MockLoginHistoryRepo_PurgeUserData_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Record provides a mock function for the type MockLoginHistoryRepo
+func (_mock *MockLoginHistoryRepo) Record(ctx context.Context, event model.LoginEvent) error {
+	ret := _mock.Called(ctx, event)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Record")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.LoginEvent) error); ok {
+		r0 = returnFunc(ctx, event)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockLoginHistoryRepo_Record_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Record'
+type MockLoginHistoryRepo_Record_Call struct {
+	*mock.Call
+}
+
+// Record is a helper method to define mock.On call
+//   - ctx context.Context
+//   - event model.LoginEvent
+func (_e *MockLoginHistoryRepo_Expecter) Record(ctx interface{}, event interface{}) *MockLoginHistoryRepo_Record_Call {
+	return &MockLoginHistoryRepo_Record_Call{Call: _e.mock.On("Record", ctx, event)}
+}
+
+func (_c *MockLoginHistoryRepo_Record_Call) Run(run func(ctx context.Context, event model.LoginEvent)) *MockLoginHistoryRepo_Record_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 model.LoginEvent
+		if args[1] != nil {
+			arg1 = args[1].(model.LoginEvent)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockLoginHistoryRepo_Record_Call) Return(err error) *MockLoginHistoryRepo_Record_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockLoginHistoryRepo_Record_Call) RunAndReturn(run func(ctx context.Context, event model.LoginEvent) error) *MockLoginHistoryRepo_Record_Call {
+	_c.Call.Return(run)
+	return _c
+}
@@ -6,6 +6,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/chai-rs/sevenhunter/internal/model"
 	mock "github.com/stretchr/testify/mock"
@@ -425,24 +426,92 @@ func (_c *MockUserRepo_FindByID_Call) RunAndReturn(run func(ctx context.Context,
 	return _c
 }
 
+// FindDeletedByEmail provides a mock function for the type MockUserRepo
+func (_mock *MockUserRepo) FindDeletedByEmail(ctx context.Context, email string) (*model.User, error) {
+	ret := _mock.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindDeletedByEmail")
+	}
+
+	var r0 *model.User
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (*model.User, error)); ok {
+		return returnFunc(ctx, email)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) *model.User); ok {
+		r0 = returnFunc(ctx, email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockUserRepo_FindDeletedByEmail_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FindDeletedByEmail'
+type MockUserRepo_FindDeletedByEmail_Call struct {
+	*mock.Call
+}
+
+// FindDeletedByEmail is a helper method to define mock.On call
+//   - ctx context.Context
+//   - email string
+func (_e *MockUserRepo_Expecter) FindDeletedByEmail(ctx interface{}, email interface{}) *MockUserRepo_FindDeletedByEmail_Call {
+	return &MockUserRepo_FindDeletedByEmail_Call{Call: _e.mock.On("FindDeletedByEmail", ctx, email)}
+}
+
+func (_c *MockUserRepo_FindDeletedByEmail_Call) Run(run func(ctx context.Context, email string)) *MockUserRepo_FindDeletedByEmail_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserRepo_FindDeletedByEmail_Call) Return(user *model.User, err error) *MockUserRepo_FindDeletedByEmail_Call {
+	_c.Call.Return(user, err)
+	return _c
+}
+
+func (_c *MockUserRepo_FindDeletedByEmail_Call) RunAndReturn(run func(ctx context.Context, email string) (*model.User, error)) *MockUserRepo_FindDeletedByEmail_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // List provides a mock function for the type MockUserRepo
-func (_mock *MockUserRepo) List(ctx context.Context, opts model.ListUserOpts) ([]model.User, error) {
+func (_mock *MockUserRepo) List(ctx context.Context, opts model.ListUserOpts) (*model.UserPage, error) {
 	ret := _mock.Called(ctx, opts)
 
 	if len(ret) == 0 {
 		panic("no return value specified for List")
 	}
 
-	var r0 []model.User
+	var r0 *model.UserPage
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ListUserOpts) ([]model.User, error)); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ListUserOpts) (*model.UserPage, error)); ok {
 		return returnFunc(ctx, opts)
 	}
-	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ListUserOpts) []model.User); ok {
+	if returnFunc, ok := ret.Get(0).(func(context.Context, model.ListUserOpts) *model.UserPage); ok {
 		r0 = returnFunc(ctx, opts)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.User)
+			r0 = ret.Get(0).(*model.UserPage)
 		}
 	}
 	if returnFunc, ok := ret.Get(1).(func(context.Context, model.ListUserOpts) error); ok {
@@ -483,12 +552,263 @@ func (_c *MockUserRepo_List_Call) Run(run func(ctx context.Context, opts model.L
 	return _c
 }
 
-func (_c *MockUserRepo_List_Call) Return(users []model.User, err error) *MockUserRepo_List_Call {
+func (_c *MockUserRepo_List_Call) Return(userPage *model.UserPage, err error) *MockUserRepo_List_Call {
+	_c.Call.Return(userPage, err)
+	return _c
+}
+
+func (_c *MockUserRepo_List_Call) RunAndReturn(run func(ctx context.Context, opts model.ListUserOpts) (*model.UserPage, error)) *MockUserRepo_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListDeleted provides a mock function for the type MockUserRepo
+func (_mock *MockUserRepo) ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.User, error) {
+	ret := _mock.Called(ctx, before, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListDeleted")
+	}
+
+	var r0 []model.User
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time, int) ([]model.User, error)); ok {
+		return returnFunc(ctx, before, limit)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, time.Time, int) []model.User); ok {
+		r0 = returnFunc(ctx, before, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.User)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = returnFunc(ctx, before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockUserRepo_ListDeleted_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListDeleted'
+type MockUserRepo_ListDeleted_Call struct {
+	*mock.Call
+}
+
+// ListDeleted is a helper method to define mock.On call
+//   - ctx context.Context
+//   - before time.Time
+//   - limit int
+func (_e *MockUserRepo_Expecter) ListDeleted(ctx interface{}, before interface{}, limit interface{}) *MockUserRepo_ListDeleted_Call {
+	return &MockUserRepo_ListDeleted_Call{Call: _e.mock.On("ListDeleted", ctx, before, limit)}
+}
+
+func (_c *MockUserRepo_ListDeleted_Call) Run(run func(ctx context.Context, before time.Time, limit int)) *MockUserRepo_ListDeleted_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 time.Time
+		if args[1] != nil {
+			arg1 = args[1].(time.Time)
+		}
+		var arg2 int
+		if args[2] != nil {
+			arg2 = args[2].(int)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserRepo_ListDeleted_Call) Return(users []model.User, err error) *MockUserRepo_ListDeleted_Call {
 	_c.Call.Return(users, err)
 	return _c
 }
 
-func (_c *MockUserRepo_List_Call) RunAndReturn(run func(ctx context.Context, opts model.ListUserOpts) ([]model.User, error)) *MockUserRepo_List_Call {
+func (_c *MockUserRepo_ListDeleted_Call) RunAndReturn(run func(ctx context.Context, before time.Time, limit int) ([]model.User, error)) *MockUserRepo_ListDeleted_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Purge provides a mock function for the type MockUserRepo
+func (_mock *MockUserRepo) Purge(ctx context.Context, id string) error {
+	ret := _mock.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Purge")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUserRepo_Purge_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Purge'
+type MockUserRepo_Purge_Call struct {
+	*mock.Call
+}
+
+// Purge is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id string
+func (_e *MockUserRepo_Expecter) Purge(ctx interface{}, id interface{}) *MockUserRepo_Purge_Call {
+	return &MockUserRepo_Purge_Call{Call: _e.mock.On("Purge", ctx, id)}
+}
+
+func (_c *MockUserRepo_Purge_Call) Run(run func(ctx context.Context, id string)) *MockUserRepo_Purge_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserRepo_Purge_Call) Return(err error) *MockUserRepo_Purge_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUserRepo_Purge_Call) RunAndReturn(run func(ctx context.Context, id string) error) *MockUserRepo_Purge_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RecordLogin provides a mock function for the type MockUserRepo
+func (_mock *MockUserRepo) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	ret := _mock.Called(ctx, id, at)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RecordLogin")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = returnFunc(ctx, id, at)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUserRepo_RecordLogin_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RecordLogin'
+type MockUserRepo_RecordLogin_Call struct {
+	*mock.Call
+}
+
+// RecordLogin is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id string
+//   - at time.Time
+func (_e *MockUserRepo_Expecter) RecordLogin(ctx interface{}, id interface{}, at interface{}) *MockUserRepo_RecordLogin_Call {
+	return &MockUserRepo_RecordLogin_Call{Call: _e.mock.On("RecordLogin", ctx, id, at)}
+}
+
+func (_c *MockUserRepo_RecordLogin_Call) Run(run func(ctx context.Context, id string, at time.Time)) *MockUserRepo_RecordLogin_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 time.Time
+		if args[2] != nil {
+			arg2 = args[2].(time.Time)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserRepo_RecordLogin_Call) Return(err error) *MockUserRepo_RecordLogin_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUserRepo_RecordLogin_Call) RunAndReturn(run func(ctx context.Context, id string, at time.Time) error) *MockUserRepo_RecordLogin_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Restore provides a mock function for the type MockUserRepo
+func (_mock *MockUserRepo) Restore(ctx context.Context, id string) error {
+	ret := _mock.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockUserRepo_Restore_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Restore'
+type MockUserRepo_Restore_Call struct {
+	*mock.Call
+}
+
+// Restore is a helper method to define mock.On call
+//   - ctx context.Context
+//   - id string
+func (_e *MockUserRepo_Expecter) Restore(ctx interface{}, id interface{}) *MockUserRepo_Restore_Call {
+	return &MockUserRepo_Restore_Call{Call: _e.mock.On("Restore", ctx, id)}
+}
+
+func (_c *MockUserRepo_Restore_Call) Run(run func(ctx context.Context, id string)) *MockUserRepo_Restore_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockUserRepo_Restore_Call) Return(err error) *MockUserRepo_Restore_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockUserRepo_Restore_Call) RunAndReturn(run func(ctx context.Context, id string) error) *MockUserRepo_Restore_Call {
 	_c.Call.Return(run)
 	return _c
 }
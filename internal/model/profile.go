@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"time"
+	// timezones are validated against the embedded database so hosts without zoneinfo behave the same
+	_ "time/tzdata"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+	"golang.org/x/text/language"
+)
+
+const (
+	MaxBioLength           = 500
+	MaxAvatarURLLength     = 2048
+	MaxMetadataEntries     = 20
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+var (
+	// phoneRule accepts E.164 numbers such as +66812345678
+	phoneRule       = v.Match(regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)).Error("must be an E.164 phone number")
+	avatarURLRule   = v.Match(regexp.MustCompile(`^https?://`)).Error("must be an http or https URL")
+	metadataKeyRule = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// localeRule accepts BCP 47 language tags such as en or th-TH
+var localeRule = v.By(func(value any) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+	if _, err := language.Parse(s); err != nil {
+		return errors.New("must be a BCP 47 language tag")
+	}
+	return nil
+})
+
+// timezoneRule accepts IANA time zone names such as Asia/Bangkok
+var timezoneRule = v.By(func(value any) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(s); err != nil || s == "Local" {
+		return errors.New("must be an IANA time zone")
+	}
+	return nil
+})
+
+var metadataRule = v.By(func(value any) error {
+	metadata, _ := value.(map[string]string)
+	if len(metadata) > MaxMetadataEntries {
+		return fmt.Errorf("must have at most %d entries", MaxMetadataEntries)
+	}
+	for key, val := range metadata {
+		if len(key) > MaxMetadataKeyLength || !metadataKeyRule.MatchString(key) {
+			return fmt.Errorf("key %q must be 1 to %d letters, digits, '_', '.' or '-'", key, MaxMetadataKeyLength)
+		}
+		if len(val) > MaxMetadataValueLength {
+			return fmt.Errorf("value of %q must be at most %d bytes", key, MaxMetadataValueLength)
+		}
+	}
+	return nil
+})
+
+// mergeMetadata applies patch to metadata, a nil value removes the key
+func mergeMetadata(metadata map[string]string, patch map[string]*string) map[string]string {
+	merged := maps.Clone(metadata)
+	if merged == nil {
+		merged = make(map[string]string, len(patch))
+	}
+	for key, val := range patch {
+		if val == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *val
+	}
+	return merged
+}
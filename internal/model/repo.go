@@ -1,14 +1,104 @@
 package model
 
-import "context"
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UnitOfWork runs fn in a transaction: the writes of the repositories that fn calls with the ctx it is given
+// are applied together when fn returns nil and not at all when it returns an error. fn may run more than
+// once when the transaction is retried, so it should not have effects outside the repositories.
+// A call inside fn joins the transaction that is already running.
+type UnitOfWork interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithoutTransaction runs fn as is, for stores that cannot group writes
+type WithoutTransaction struct{}
+
+func (WithoutTransaction) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type transactionKey struct{}
+
+// transaction collects what runs once the transaction of a UnitOfWork committed
+type transaction struct {
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+// NewTransactionContext marks ctx as running a transaction. UnitOfWork implementations call it for every
+// attempt and call committed once that attempt committed.
+func NewTransactionContext(ctx context.Context) (_ context.Context, committed func()) {
+	tx := &transaction{}
+	return context.WithValue(ctx, transactionKey{}, tx), func() {
+		tx.mu.Lock()
+		fns := tx.afterCommit
+		tx.mu.Unlock()
+
+		for _, fn := range fns {
+			fn()
+		}
+	}
+}
+
+// InTransaction reports whether ctx carries a transaction that has not committed yet
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(transactionKey{}).(*transaction)
+	return ok
+}
+
+// AfterCommit runs fn once the transaction carried by ctx committed, never when it is rolled back.
+// Outside of a transaction fn runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	tx, ok := ctx.Value(transactionKey{}).(*transaction)
+	if !ok {
+		fn()
+		return
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.afterCommit = append(tx.afterCommit, fn)
+}
 
 type UserRepo interface {
 	Count(ctx context.Context) (int64, error)
-	List(ctx context.Context, opts ListUserOpts) ([]User, error)
+	List(ctx context.Context, opts ListUserOpts) (*UserPage, error)
 	Create(ctx context.Context, user *User) (*User, error)
 	FindByID(ctx context.Context, id string) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	ExistsByID(ctx context.Context, id string) (bool, error)
 	Update(ctx context.Context, user *User) error
+	// Delete marks the user as deleted, deleted users are left out of every other query
 	Delete(ctx context.Context, id string) error
+	FindDeletedByEmail(ctx context.Context, email string) (*User, error)
+	Restore(ctx context.Context, id string) error
+	// ListDeleted returns up to limit users deleted before the given time, oldest first
+	ListDeleted(ctx context.Context, before time.Time, limit int) ([]User, error)
+	// Purge permanently removes a deleted user
+	Purge(ctx context.Context, id string) error
+	// RecordLogin sets when the user last signed in, it is not an update of the profile and keeps the version
+	RecordLogin(ctx context.Context, id string, at time.Time) error
+}
+
+// LoginHistoryRepo keeps the sign-in attempts of users until they expire
+type LoginHistoryRepo interface {
+	Record(ctx context.Context, event LoginEvent) error
+	// List returns the events of a user, newest first
+	List(ctx context.Context, opts ListLoginOpts) (*LoginPage, error)
+	UserDataPurger
+}
+
+// UserDataPurger removes data owned by a user when their account is purged
+type UserDataPurger interface {
+	PurgeUserData(ctx context.Context, userID string) error
+}
+
+type UserDataPurgerFunc func(ctx context.Context, userID string) error
+
+func (f UserDataPurgerFunc) PurgeUserData(ctx context.Context, userID string) error {
+	return f(ctx, userID)
 }
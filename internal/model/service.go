@@ -4,14 +4,23 @@ import "context"
 
 type AuthService interface {
 	Register(ctx context.Context, opts RegisterOpts) (*AuthResult, error)
+	// ReplayRegister issues new tokens for the account created by a registration that is retried
+	ReplayRegister(ctx context.Context, userID string) (*AuthResult, error)
 	Login(ctx context.Context, opts LoginOpts) (*AuthResult, error)
 	RefreshToken(ctx context.Context, refreshToken string) (*AuthResult, error)
+	Restore(ctx context.Context, opts LoginOpts) (*AuthResult, error)
 }
 
 type UserService interface {
-	List(ctx context.Context, opts ListUserOpts) ([]User, error)
+	List(ctx context.Context, opts ListUserOpts) (*UserPage, error)
 	Count(ctx context.Context) (int64, error)
 	Update(ctx context.Context, opts UpdateUserOpts) (*User, error)
 	Get(ctx context.Context, id string) (*User, error)
 	Delete(ctx context.Context, id string) error
+	// ConfirmEmail applies the pending email change the token was issued for
+	ConfirmEmail(ctx context.Context, token string) (*User, error)
+	// ChangeStatus moves another user to a new status on behalf of an admin
+	ChangeStatus(ctx context.Context, opts ChangeUserStatusOpts) (*User, error)
+	// ListLogins returns the login history of a user, newest first
+	ListLogins(ctx context.Context, opts ListLoginOpts) (*LoginPage, error)
 }
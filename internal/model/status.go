@@ -0,0 +1,136 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type UserStatus string
+
+const (
+	// UserStatusPending accounts exist but cannot sign in until they are activated
+	UserStatusPending UserStatus = "pending"
+	UserStatusActive  UserStatus = "active"
+	// UserStatusSuspended accounts are blocked by an admin, e.g. for abuse
+	UserStatusSuspended UserStatus = "suspended"
+	// UserStatusLocked accounts are blocked for their own protection, e.g. when they may be compromised
+	UserStatusLocked UserStatus = "locked"
+)
+
+var UserStatuses = []any{UserStatusPending, UserStatusActive, UserStatusSuspended, UserStatusLocked}
+
+// userStatusTransitions lists the statuses each status can change to
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPending:   {UserStatusActive, UserStatusSuspended},
+	UserStatusActive:    {UserStatusSuspended, UserStatusLocked},
+	UserStatusSuspended: {UserStatusActive},
+	UserStatusLocked:    {UserStatusActive},
+}
+
+const MaxStatusReasonLength = 500
+
+var (
+	ErrUserPending   = errx.M(http.StatusForbidden, "account is not activated yet")
+	ErrUserSuspended = errx.M(http.StatusForbidden, "account is suspended")
+	ErrUserLocked    = errx.M(http.StatusLocked, "account is locked")
+	ErrStatusSelf    = errx.M(http.StatusForbidden, "admins cannot change their own status")
+)
+
+func ErrStatusTransition(from, to UserStatus) error {
+	return errx.M(http.StatusConflict, fmt.Sprintf("status cannot change from %s to %s", from, to))
+}
+
+// CanTransition reports whether a user with status s can be moved to status to
+func (s UserStatus) CanTransition(to UserStatus) bool {
+	return slices.Contains(userStatusTransitions[s], to)
+}
+
+// UserStatusChange is an entry of the status history of a user
+type UserStatusChange struct {
+	From   UserStatus
+	To     UserStatus
+	Reason string
+	// ChangedBy is the ID of the admin who made the change
+	ChangedBy string
+	ChangedAt time.Time
+}
+
+type ChangeUserStatusOpts struct {
+	ID        string
+	Status    UserStatus
+	Reason    string
+	ChangedBy string
+}
+
+func (opts ChangeUserStatusOpts) Validate() error {
+	err := v.ValidateStruct(&opts,
+		v.Field(&opts.ID, v.Required),
+		v.Field(&opts.Status, v.Required, v.In(UserStatuses...)),
+		v.Field(&opts.Reason, v.Required, v.RuneLength(1, MaxStatusReasonLength)),
+		v.Field(&opts.ChangedBy, v.Required),
+	)
+	if err != nil {
+		return errx.E(http.StatusBadRequest, err)
+	}
+
+	return nil
+}
+
+// CanSignIn fails with the reason the account is not allowed to authenticate
+func (u *User) CanSignIn() error {
+	switch u.status {
+	case UserStatusPending:
+		return ErrUserPending
+	case UserStatusSuspended:
+		return ErrUserSuspended
+	case UserStatusLocked:
+		return ErrUserLocked
+	}
+
+	return nil
+}
+
+// ChangeStatus moves the user to another status along the allowed transitions and records it in the history
+func (u *User) ChangeStatus(opts ChangeUserStatusOpts, now time.Time) error {
+	if !u.status.CanTransition(opts.Status) {
+		return ErrStatusTransition(u.status, opts.Status)
+	}
+
+	u.statusHistory = append(u.statusHistory, UserStatusChange{
+		From:      u.status,
+		To:        opts.Status,
+		Reason:    opts.Reason,
+		ChangedBy: opts.ChangedBy,
+		ChangedAt: now,
+	})
+	u.status = opts.Status
+	u.change(UserFieldStatus)
+	u.change(UserFieldStatusHistory)
+	return nil
+}
+
+// StatusHistory lists the status changes of the user, oldest first
+func (u *User) StatusHistory() []UserStatusChange {
+	return slices.Clone(u.statusHistory)
+}
+
+// StatusChangedAt is when the status last changed, zero when it never did
+func (u *User) StatusChangedAt() time.Time {
+	if len(u.statusHistory) == 0 {
+		return time.Time{}
+	}
+	return u.statusHistory[len(u.statusHistory)-1].ChangedAt
+}
+
+// StatusReason explains the current status, empty when it never changed
+func (u *User) StatusReason() string {
+	if len(u.statusHistory) == 0 {
+		return ""
+	}
+	return u.statusHistory[len(u.statusHistory)-1].Reason
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserStatus_CanTransition(t *testing.T) {
+	type Testcase struct {
+		from     UserStatus
+		to       UserStatus
+		expected bool
+	}
+
+	testcases := []Testcase{
+		{from: UserStatusPending, to: UserStatusActive, expected: true},
+		{from: UserStatusPending, to: UserStatusSuspended, expected: true},
+		{from: UserStatusPending, to: UserStatusLocked, expected: false},
+		{from: UserStatusActive, to: UserStatusSuspended, expected: true},
+		{from: UserStatusActive, to: UserStatusLocked, expected: true},
+		{from: UserStatusActive, to: UserStatusPending, expected: false},
+		{from: UserStatusActive, to: UserStatusActive, expected: false},
+		{from: UserStatusSuspended, to: UserStatusActive, expected: true},
+		{from: UserStatusSuspended, to: UserStatusLocked, expected: false},
+		{from: UserStatusLocked, to: UserStatusActive, expected: true},
+		{from: UserStatusLocked, to: UserStatusSuspended, expected: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(string(tc.from)+" to "+string(tc.to), func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.from.CanTransition(tc.to))
+		})
+	}
+}
+
+func TestUser_ChangeStatus(t *testing.T) {
+	user, err := NewUser(UserOpts{
+		ID:             "123",
+		Name:           "John Doe",
+		Email:          "john@example.com",
+		HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		CreatedAt:      time.Now(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, user.CanSignIn())
+
+	suspendedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	err = user.ChangeStatus(ChangeUserStatusOpts{ID: "123", Status: UserStatusSuspended, Reason: "spam", ChangedBy: "admin"}, suspendedAt)
+	require.NoError(t, err)
+	require.Equal(t, UserStatusSuspended, user.Status())
+	require.Equal(t, "spam", user.StatusReason())
+	require.Equal(t, suspendedAt, user.StatusChangedAt())
+	require.ErrorIs(t, user.CanSignIn(), ErrUserSuspended)
+	require.Equal(t, []UserField{UserFieldStatus, UserFieldStatusHistory}, user.Changes())
+
+	err = user.ChangeStatus(ChangeUserStatusOpts{ID: "123", Status: UserStatusLocked, Reason: "leaked password", ChangedBy: "admin"}, time.Now())
+	require.Error(t, err)
+	require.Equal(t, UserStatusSuspended, user.Status())
+
+	reactivatedAt := suspendedAt.Add(time.Hour)
+	err = user.ChangeStatus(ChangeUserStatusOpts{ID: "123", Status: UserStatusActive, Reason: "appeal accepted", ChangedBy: "admin"}, reactivatedAt)
+	require.NoError(t, err)
+	require.NoError(t, user.CanSignIn())
+	require.Equal(t, []UserStatusChange{
+		{From: UserStatusActive, To: UserStatusSuspended, Reason: "spam", ChangedBy: "admin", ChangedAt: suspendedAt},
+		{From: UserStatusSuspended, To: UserStatusActive, Reason: "appeal accepted", ChangedBy: "admin", ChangedAt: reactivatedAt},
+	}, user.StatusHistory())
+}
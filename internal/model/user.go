@@ -1,7 +1,9 @@
 package model
 
 import (
+	"maps"
 	"net/http"
+	"slices"
 	"time"
 
 	errx "github.com/chai-rs/sevenhunter/pkg/error"
@@ -10,20 +12,84 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+type UserRole string
+
+const (
+	UserRoleUser  UserRole = "user"
+	UserRoleAdmin UserRole = "admin"
+)
+
+var UserRoles = []any{UserRoleUser, UserRoleAdmin}
+
+var (
+	ErrUserVersionMismatch = errx.M(http.StatusPreconditionFailed, "user has been modified, fetch it again before updating")
+	ErrEmailTaken          = errx.M(http.StatusConflict, "email address is already in use")
+	ErrEmailChangeInvalid  = errx.M(http.StatusBadRequest, "email change is invalid or has expired")
+)
+
 type User struct {
 	id             string
 	name           string
 	email          string
 	hashedPassword string
+	role           UserRole
+	status         UserStatus
 	createdAt      time.Time
+	deletedAt      time.Time
+	lastLoginAt    time.Time
+	version        int64
+	avatarURL      string
+	bio            string
+	locale         string
+	timezone       string
+	phone          string
+	metadata       map[string]string
+	// statusHistory lists the status changes, oldest first
+	statusHistory []UserStatusChange
+	// pendingEmail waits to become the email until the owner of the address confirms it
+	pendingEmail string
+	// changes lists the fields modified since the user was loaded or saved
+	changes []UserField
 }
 
+// UserField names a user field that can change after creation
+type UserField string
+
+const (
+	UserFieldName      UserField = "name"
+	UserFieldEmail     UserField = "email"
+	UserFieldAvatarURL UserField = "avatar_url"
+	UserFieldBio       UserField = "bio"
+	UserFieldLocale    UserField = "locale"
+	UserFieldTimezone  UserField = "timezone"
+	UserFieldPhone     UserField = "phone"
+	UserFieldMetadata  UserField = "metadata"
+	UserFieldStatus    UserField = "status"
+	// UserFieldStatusHistory is appended to whenever the status changes
+	UserFieldStatusHistory UserField = "status_history"
+	// UserFieldPendingEmail is the requested email address that has not been confirmed yet
+	UserFieldPendingEmail UserField = "pending_email"
+)
+
 type UserOpts struct {
 	ID             string
 	Name           string
 	Email          string
 	HashedPassword string
+	Role           UserRole
+	Status         UserStatus
 	CreatedAt      time.Time
+	DeletedAt      time.Time
+	LastLoginAt    time.Time
+	Version        int64
+	AvatarURL      string
+	Bio            string
+	Locale         string
+	Timezone       string
+	Phone          string
+	Metadata       map[string]string
+	PendingEmail   string
+	StatusHistory  []UserStatusChange
 }
 
 func NewUser(opts UserOpts) (*User, error) {
@@ -32,7 +98,31 @@ func NewUser(opts UserOpts) (*User, error) {
 		name:           opts.Name,
 		email:          opts.Email,
 		hashedPassword: opts.HashedPassword,
+		role:           opts.Role,
+		status:         opts.Status,
 		createdAt:      opts.CreatedAt,
+		deletedAt:      opts.DeletedAt,
+		lastLoginAt:    opts.LastLoginAt,
+		version:        opts.Version,
+		avatarURL:      opts.AvatarURL,
+		bio:            opts.Bio,
+		locale:         opts.Locale,
+		timezone:       opts.Timezone,
+		phone:          opts.Phone,
+		metadata:       opts.Metadata,
+		pendingEmail:   opts.PendingEmail,
+		statusHistory:  opts.StatusHistory,
+	}
+
+	// documents written before roles and statuses existed
+	if u.role == "" {
+		u.role = UserRoleUser
+	}
+	if u.status == "" {
+		u.status = UserStatusActive
+	}
+	if u.version == 0 {
+		u.version = 1
 	}
 
 	if err := u.Validate(); err != nil {
@@ -47,7 +137,17 @@ func (u *User) Validate(newUser ...bool) error {
 		v.Field(&u.name, v.Required, v.Length(2, 32)),
 		v.Field(&u.email, v.Required, v.Length(5, 200), is.Email),
 		v.Field(&u.hashedPassword, v.Required),
+		v.Field(&u.role, v.Required, v.In(UserRoles...)),
+		v.Field(&u.status, v.Required, v.In(UserStatuses...)),
 		v.Field(&u.createdAt, v.Required),
+		v.Field(&u.version, v.Required, v.Min(int64(1))),
+		v.Field(&u.avatarURL, v.Length(0, MaxAvatarURLLength), avatarURLRule, is.URL),
+		v.Field(&u.bio, v.RuneLength(0, MaxBioLength)),
+		v.Field(&u.locale, localeRule),
+		v.Field(&u.timezone, timezoneRule),
+		v.Field(&u.phone, phoneRule),
+		v.Field(&u.metadata, metadataRule),
+		v.Field(&u.pendingEmail, v.Length(5, 200), is.Email),
 	}
 
 	// not new user, validate the auto-generated fields
@@ -70,6 +170,29 @@ func (u *User) Email() string {
 	return u.email
 }
 
+// EmailCanonical identifies the mailbox of the email, it is unique among users
+func (u *User) EmailCanonical() string {
+	return CanonicalEmail(u.email)
+}
+
+// PendingEmail is the requested new email address, empty when no change is waiting for confirmation
+func (u *User) PendingEmail() string {
+	return u.pendingEmail
+}
+
+// ConfirmEmail makes the pending email the email once its owner proved they received the confirmation
+func (u *User) ConfirmEmail(email string) error {
+	if u.pendingEmail == "" || u.pendingEmail != email {
+		return ErrEmailChangeInvalid
+	}
+
+	u.email = u.pendingEmail
+	u.pendingEmail = ""
+	u.change(UserFieldEmail)
+	u.change(UserFieldPendingEmail)
+	return nil
+}
+
 func (u *User) HashedPassword() string {
 	return u.hashedPassword
 }
@@ -81,19 +204,158 @@ func (u *User) ComparePassword(password string) error {
 	return nil
 }
 
+func (u *User) Role() UserRole {
+	return u.role
+}
+
+func (u *User) Status() UserStatus {
+	return u.status
+}
+
 func (u *User) CreatedAt() time.Time {
 	return u.createdAt
 }
 
+// LastLoginAt is when the user last signed in or refreshed their tokens, zero when they never did
+func (u *User) LastLoginAt() time.Time {
+	return u.lastLoginAt
+}
+
+func (u *User) AvatarURL() string {
+	return u.avatarURL
+}
+
+func (u *User) Bio() string {
+	return u.bio
+}
+
+// Locale is a BCP 47 language tag, empty when unset
+func (u *User) Locale() string {
+	return u.locale
+}
+
+// Timezone is an IANA time zone name, empty when unset
+func (u *User) Timezone() string {
+	return u.timezone
+}
+
+// Phone is an E.164 phone number, empty when unset
+func (u *User) Phone() string {
+	return u.phone
+}
+
+// Metadata is free-form data set by clients, bounded by MaxMetadataEntries
+func (u *User) Metadata() map[string]string {
+	return maps.Clone(u.metadata)
+}
+
+// Version is incremented on every update and guards against concurrent overwrites
+func (u *User) Version() int64 {
+	return u.version
+}
+
+// Changes returns the fields modified since the user was loaded or saved
+func (u *User) Changes() []UserField {
+	return u.changes
+}
+
+// MarkSaved records the version the user was saved with and forgets the pending changes
+func (u *User) MarkSaved(version int64) {
+	u.version = version
+	u.changes = nil
+}
+
+// Clone returns a copy of the user that shares no map or slice with it
+func (u *User) Clone() *User {
+	clone := *u
+	clone.metadata = maps.Clone(u.metadata)
+	clone.statusHistory = slices.Clone(u.statusHistory)
+	clone.changes = slices.Clone(u.changes)
+	return &clone
+}
+
+func (u *User) updateString(field UserField, dst *string, value *string) {
+	if value != nil && *value != *dst {
+		*dst = *value
+		u.change(field)
+	}
+}
+
+func (u *User) change(field UserField) {
+	if !slices.Contains(u.changes, field) {
+		u.changes = append(u.changes, field)
+	}
+}
+
+// DeletedAt is when the user deleted their account, zero while the account is live
+func (u *User) DeletedAt() time.Time {
+	return u.deletedAt
+}
+
+func (u *User) IsDeleted() bool {
+	return !u.deletedAt.IsZero()
+}
+
+// Restorable reports whether a deleted account is still within the grace period at now
+func (u *User) Restorable(gracePeriod time.Duration, now time.Time) bool {
+	return u.IsDeleted() && now.Before(u.deletedAt.Add(gracePeriod))
+}
+
+// UpdateUserOpts changes the fields that are set and leaves the nil ones untouched.
+// An Email of a different mailbox is not applied right away, it becomes the pending email until it is confirmed.
 type UpdateUserOpts struct {
-	ID    string
-	Name  string
-	Email string
+	ID        string
+	Name      *string
+	Email     *string
+	AvatarURL *string
+	Bio       *string
+	Locale    *string
+	Timezone  *string
+	Phone     *string
+	// Metadata is merged into the existing metadata, a nil value removes the key
+	Metadata map[string]*string
+	// ReplaceMetadata drops the existing metadata before merging Metadata
+	ReplaceMetadata bool
+	// Versions the caller expects the user to be at, nil skips the check and an empty list matches none
+	Versions []int64
 }
 
 func (s *User) Update(opts UpdateUserOpts) error {
-	s.email = opts.Email
-	s.name = opts.Name
+	if opts.Versions != nil && !slices.Contains(opts.Versions, s.version) {
+		return ErrUserVersionMismatch
+	}
+
+	s.updateString(UserFieldName, &s.name, opts.Name)
+	if opts.Email != nil {
+		email := NormalizeEmail(*opts.Email)
+		// another spelling of the same mailbox needs no confirmation and cancels a pending change,
+		// so that an earlier confirmation link no longer switches the account
+		if CanonicalEmail(email) == s.EmailCanonical() {
+			s.updateString(UserFieldEmail, &s.email, &email)
+			none := ""
+			s.updateString(UserFieldPendingEmail, &s.pendingEmail, &none)
+		} else {
+			s.updateString(UserFieldPendingEmail, &s.pendingEmail, &email)
+		}
+	}
+	s.updateString(UserFieldAvatarURL, &s.avatarURL, opts.AvatarURL)
+	s.updateString(UserFieldBio, &s.bio, opts.Bio)
+	s.updateString(UserFieldLocale, &s.locale, opts.Locale)
+	s.updateString(UserFieldTimezone, &s.timezone, opts.Timezone)
+	s.updateString(UserFieldPhone, &s.phone, opts.Phone)
+
+	if opts.ReplaceMetadata || len(opts.Metadata) > 0 {
+		current := s.metadata
+		if opts.ReplaceMetadata {
+			current = nil
+		}
+
+		metadata := mergeMetadata(current, opts.Metadata)
+		if !maps.Equal(metadata, s.metadata) {
+			s.metadata = metadata
+			s.change(UserFieldMetadata)
+		}
+	}
 
 	if err := s.Validate(); err != nil {
 		return errx.E(http.StatusBadRequest, err)
@@ -116,9 +378,12 @@ func NewCreateUser(opts CreateUserOpts) (*User, error) {
 
 	u := User{
 		name:           opts.Name,
-		email:          opts.Email,
+		email:          NormalizeEmail(opts.Email),
 		hashedPassword: string(hashedPasswordBytes),
+		role:           UserRoleUser,
+		status:         UserStatusActive,
 		createdAt:      time.Now(),
+		version:        1,
 	}
 
 	if err := u.Validate(true); err != nil {
@@ -128,10 +393,106 @@ func NewCreateUser(opts CreateUserOpts) (*User, error) {
 	return &u, nil
 }
 
+type UserSortField string
+
+const (
+	UserSortCreatedAt UserSortField = "created_at"
+	UserSortName      UserSortField = "name"
+	UserSortEmail     UserSortField = "email"
+)
+
+var UserSortFields = []any{UserSortCreatedAt, UserSortName, UserSortEmail}
+
+// UserCursor is the position of a user within a sorted listing. Users are ordered by Sort and then by ID,
+// so Value and ID together identify a unique position.
+type UserCursor struct {
+	Sort    UserSortField `json:"s"`
+	SortAsc bool          `json:"a,omitempty"`
+	Value   string        `json:"v"`
+	ID      string        `json:"id"`
+	// Backward asks for the page that ends right before the position instead of the one starting after it
+	Backward bool `json:"b,omitempty"`
+}
+
+// NewUserCursor points at u within a listing sorted by sort
+func NewUserCursor(u *User, sort UserSortField, sortAsc bool, backward bool) *UserCursor {
+	return &UserCursor{
+		Sort:     sort,
+		SortAsc:  sortAsc,
+		Value:    u.SortValue(sort),
+		ID:       u.ID(),
+		Backward: backward,
+	}
+}
+
+// SortValue returns the value of the sort field as stored in a cursor
+func (u *User) SortValue(sort UserSortField) string {
+	switch sort {
+	case UserSortName:
+		return u.name
+	case UserSortEmail:
+		return u.email
+	default:
+		return u.createdAt.UTC().Format(time.RFC3339Nano)
+	}
+}
+
 type ListUserOpts struct {
-	Cursor  string
+	// Cursor continues a previous listing, its sort order takes precedence over Sort and SortAsc
+	Cursor  *UserCursor
 	Limit   int
+	Sort    UserSortField
 	SortAsc bool
+
+	// NamePrefix and EmailPrefix match the beginning of the field, case-sensitively
+	NamePrefix  string
+	EmailPrefix string
+	// Search matches a case-insensitive substring of the name or the email
+	Search      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Role        UserRole
+	Status      UserStatus
+}
+
+func (opts ListUserOpts) Validate() error {
+	err := v.ValidateStruct(&opts,
+		v.Field(&opts.NamePrefix, v.Length(0, 32)),
+		v.Field(&opts.EmailPrefix, v.Length(0, 200)),
+		v.Field(&opts.Search, v.Length(0, 200)),
+		v.Field(&opts.CreatedTo, v.When(!opts.CreatedFrom.IsZero() && !opts.CreatedTo.IsZero(), v.Min(opts.CreatedFrom))),
+		v.Field(&opts.Role, v.In(UserRoles...)),
+		v.Field(&opts.Status, v.In(UserStatuses...)),
+		v.Field(&opts.Sort, v.In(UserSortFields...)),
+	)
+	if err != nil {
+		return errx.E(http.StatusBadRequest, err)
+	}
+
+	if opts.Cursor != nil {
+		cursor := *opts.Cursor
+		err := v.ValidateStruct(&cursor,
+			v.Field(&cursor.Sort, v.Required, v.In(UserSortFields...)),
+			v.Field(&cursor.ID, v.Required),
+			v.Field(&cursor.Value, v.When(cursor.Sort == UserSortCreatedAt, v.Date(time.RFC3339Nano))),
+		)
+		if err != nil {
+			return errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+	}
+
+	return nil
+}
+
+// GetSort returns the sort field and direction, taken from the cursor when there is one
+func (opts *ListUserOpts) GetSort() (UserSortField, bool) {
+	if opts.Cursor != nil {
+		return opts.Cursor.Sort, opts.Cursor.SortAsc
+	}
+	if opts.Sort == "" {
+		return UserSortCreatedAt, opts.SortAsc
+	}
+	return opts.Sort, opts.SortAsc
 }
 
 func (opts *ListUserOpts) GetLimit() int {
@@ -143,3 +504,13 @@ func (opts *ListUserOpts) GetLimit() int {
 	}
 	return opts.Limit
 }
+
+type UserPage struct {
+	Users []User
+	// Next points after the last user, nil when there are no more users
+	Next *UserCursor
+	// Prev points before the first user, nil on the first page
+	Prev *UserCursor
+	// HasMore reports whether there are users after this page
+	HasMore bool
+}
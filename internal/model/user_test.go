@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUser_UpdateVersions(t *testing.T) {
+	type Testcase struct {
+		name     string
+		versions []int64
+		err      error
+	}
+
+	testcases := []Testcase{
+		{name: "nil skips the check", versions: nil},
+		{name: "current version", versions: []int64{3, 1}},
+		{name: "other versions", versions: []int64{2, 3}, err: ErrUserVersionMismatch},
+		{name: "empty matches none", versions: []int64{}, err: ErrUserVersionMismatch},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			user, err := NewUser(UserOpts{
+				ID:             "123",
+				Name:           "John Doe",
+				Email:          "john@example.com",
+				HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+				CreatedAt:      time.Now(),
+			})
+			require.NoError(t, err)
+
+			name := "Jane Doe"
+			err = user.Update(UpdateUserOpts{ID: "123", Name: &name, Versions: tc.versions})
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				require.Equal(t, "John Doe", user.Name())
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, name, user.Name())
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryLoginHistoryRepo keeps login events in process with the same behavior as LoginHistoryRepo,
+// expired events are skipped when listing and dropped when new ones are recorded
+type MemoryLoginHistoryRepo struct {
+	mu     sync.Mutex
+	events []loginEventMongo
+}
+
+func NewMemoryLoginHistoryRepo() *MemoryLoginHistoryRepo {
+	return &MemoryLoginHistoryRepo{}
+}
+
+var _ model.LoginHistoryRepo = (*MemoryLoginHistoryRepo)(nil)
+
+func (r *MemoryLoginHistoryRepo) Record(ctx context.Context, event model.LoginEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remember(ctx)
+
+	now := time.Now()
+	r.events = slices.DeleteFunc(r.events, func(e loginEventMongo) bool {
+		return !e.ExpiresAt.After(now)
+	})
+
+	e := newLoginEventMongo(event)
+	e.ID = primitive.NewObjectID()
+	e.At = storedTime(e.At)
+	e.ExpiresAt = storedTime(e.ExpiresAt)
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *MemoryLoginHistoryRepo) List(_ context.Context, opts model.ListLoginOpts) (*model.LoginPage, error) {
+	var position *loginEventMongo
+	if opts.Cursor != nil {
+		objID, err := primitive.ObjectIDFromHex(opts.Cursor.ID)
+		if err != nil {
+			return nil, errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		position = &loginEventMongo{ID: objID, At: storedTime(opts.Cursor.At)}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	events := make([]loginEventMongo, 0)
+	for _, e := range r.events {
+		if e.UserID != opts.UserID || !e.ExpiresAt.After(now) {
+			continue
+		}
+		if position != nil && compareLoginEvents(&e, position) <= 0 {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	slices.SortFunc(events, func(a, b loginEventMongo) int {
+		return compareLoginEvents(&a, &b)
+	})
+
+	limit := opts.GetLimit()
+	events = events[:min(len(events), limit+1)]
+	return newLoginPage(lo.Map(events, func(e loginEventMongo, _ int) model.LoginEvent { return e.toModel() }), limit), nil
+}
+
+// compareLoginEvents orders events newest first, ties are broken by id
+func compareLoginEvents(a, b *loginEventMongo) int {
+	if order := b.At.Compare(a.At); order != 0 {
+		return order
+	}
+	return strings.Compare(b.ID.Hex(), a.ID.Hex())
+}
+
+func (r *MemoryLoginHistoryRepo) PurgeUserData(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remember(ctx)
+
+	r.events = slices.DeleteFunc(r.events, func(e loginEventMongo) bool {
+		return e.UserID == userID
+	})
+	return nil
+}
+
+// remember puts the events back as they are now if a MemoryUnitOfWork transaction rolls back,
+// writes made meanwhile outside the transaction are lost with it. The caller holds the lock.
+func (r *MemoryLoginHistoryRepo) remember(ctx context.Context) {
+	events := slices.Clone(r.events)
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.events = events
+	})
+}
@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const loginHistoryCollection = "login_history"
+
+type loginEventMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	Method    model.LoginMethod  `bson:"method"`
+	Success   bool               `bson:"success"`
+	Reason    string             `bson:"reason,omitempty"`
+	IP        string             `bson:"ip,omitempty"`
+	UserAgent string             `bson:"user_agent,omitempty"`
+	At        time.Time          `bson:"at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+func newLoginEventMongo(event model.LoginEvent) loginEventMongo {
+	return loginEventMongo{
+		UserID:    event.UserID,
+		Method:    event.Method,
+		Success:   event.Success,
+		Reason:    event.Reason,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		At:        event.At,
+		ExpiresAt: event.ExpiresAt,
+	}
+}
+
+func (e *loginEventMongo) toModel() model.LoginEvent {
+	return model.LoginEvent{
+		ID:        e.ID.Hex(),
+		UserID:    e.UserID,
+		Method:    e.Method,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		At:        e.At,
+		ExpiresAt: e.ExpiresAt,
+	}
+}
+
+// LoginHistoryRepo stores login events, a TTL index on expires_at removes them once they expire
+type LoginHistoryRepo struct {
+	collection *mongo.Collection
+}
+
+func NewLoginHistoryRepo(db *mongo.Database) *LoginHistoryRepo {
+	return &LoginHistoryRepo{
+		collection: db.Collection(loginHistoryCollection),
+	}
+}
+
+var _ model.LoginHistoryRepo = (*LoginHistoryRepo)(nil)
+
+func (r *LoginHistoryRepo) Record(ctx context.Context, event model.LoginEvent) (err error) {
+	ctx, end := instrument(ctx, loginHistoryCollection, "Record")
+	defer end(&err)
+
+	_, err = r.collection.InsertOne(ctx, newLoginEventMongo(event))
+	if err != nil {
+		return errx.Mongo(err)
+	}
+
+	return nil
+}
+
+func (r *LoginHistoryRepo) List(ctx context.Context, opts model.ListLoginOpts) (_ *model.LoginPage, err error) {
+	ctx, end := instrument(ctx, loginHistoryCollection, "List")
+	defer end(&err)
+
+	// the TTL monitor runs about once a minute, events it has not removed yet are skipped
+	filter := bson.M{"user_id": opts.UserID, "expires_at": bson.M{"$gt": time.Now()}}
+	if opts.Cursor != nil {
+		objID, err := primitive.ObjectIDFromHex(opts.Cursor.ID)
+		if err != nil {
+			return nil, errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+
+		filter["$or"] = bson.A{
+			bson.M{"at": bson.M{"$lt": opts.Cursor.At}},
+			bson.M{"at": opts.Cursor.At, "_id": bson.M{"$lt": objID}},
+		}
+	}
+
+	limit := opts.GetLimit()
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := r.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, errx.Mongo(err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []loginEventMongo
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, errx.Mongo(err)
+	}
+
+	return newLoginPage(lo.Map(events, func(e loginEventMongo, _ int) model.LoginEvent { return e.toModel() }), limit), nil
+}
+
+// newLoginPage builds the page from up to limit+1 events, newest first
+func newLoginPage(events []model.LoginEvent, limit int) *model.LoginPage {
+	page := &model.LoginPage{
+		Events:  make([]model.LoginEvent, 0, min(len(events), limit)),
+		HasMore: len(events) > limit,
+	}
+	page.Events = append(page.Events, events[:min(len(events), limit)]...)
+
+	if page.HasMore {
+		last := page.Events[len(page.Events)-1]
+		page.Next = &model.LoginCursor{At: last.At, ID: last.ID}
+	}
+
+	return page
+}
+
+func (r *LoginHistoryRepo) PurgeUserData(ctx context.Context, userID string) (err error) {
+	ctx, end := instrument(ctx, loginHistoryCollection, "PurgeUserData")
+	defer end(&err)
+
+	_, err = r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return errx.Mongo(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+)
+
+func TestLoginHistoryRepo(t *testing.T) {
+	repotest.RunLoginHistoryRepo(t, func(t *testing.T) model.LoginHistoryRepo {
+		return NewLoginHistoryRepo(newTestMongoDatabase(t))
+	})
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type loginEventPostgres struct {
+	ID        string            `db:"id"`
+	UserID    string            `db:"user_id"`
+	Method    model.LoginMethod `db:"method"`
+	Success   bool              `db:"success"`
+	Reason    string            `db:"reason"`
+	IP        string            `db:"ip"`
+	UserAgent string            `db:"user_agent"`
+	At        time.Time         `db:"at"`
+	ExpiresAt time.Time         `db:"expires_at"`
+}
+
+func (e *loginEventPostgres) toModel() model.LoginEvent {
+	return model.LoginEvent{
+		ID:        e.ID,
+		UserID:    e.UserID,
+		Method:    e.Method,
+		Success:   e.Success,
+		Reason:    e.Reason,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		At:        e.At.UTC(),
+		ExpiresAt: e.ExpiresAt.UTC(),
+	}
+}
+
+// LoginHistoryRepoPostgres stores login events in Postgres. There is no TTL index, so expired events
+// are skipped when listing and a user's expired events are removed when a new one is recorded.
+type LoginHistoryRepoPostgres struct {
+	pool *pgxpool.Pool
+}
+
+func NewLoginHistoryRepoPostgres(pool *pgxpool.Pool) *LoginHistoryRepoPostgres {
+	return &LoginHistoryRepoPostgres{
+		pool: pool,
+	}
+}
+
+var _ model.LoginHistoryRepo = (*LoginHistoryRepoPostgres)(nil)
+
+// conn runs statements in the transaction of ctx when there is one
+func (r *LoginHistoryRepoPostgres) conn(ctx context.Context) postgresConn {
+	return postgresConnFrom(ctx, r.pool)
+}
+
+func (r *LoginHistoryRepoPostgres) Record(ctx context.Context, event model.LoginEvent) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, loginHistoryCollection, "Record")
+	defer end(&err)
+
+	batch := &pgx.Batch{}
+	batch.Queue("DELETE FROM login_history WHERE user_id = $1 AND expires_at <= now()", event.UserID)
+	batch.Queue(`INSERT INTO login_history (id, user_id, method, success, reason, ip, user_agent, at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		primitive.NewObjectID().Hex(), event.UserID, string(event.Method), event.Success, event.Reason,
+		event.IP, event.UserAgent, storedTime(event.At), storedTime(event.ExpiresAt),
+	)
+
+	if err := r.conn(ctx).SendBatch(ctx, batch).Close(); err != nil {
+		return errx.Postgres(err)
+	}
+
+	return nil
+}
+
+func (r *LoginHistoryRepoPostgres) List(ctx context.Context, opts model.ListLoginOpts) (_ *model.LoginPage, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, loginHistoryCollection, "List")
+	defer end(&err)
+
+	var args sqlArgs
+	query := "SELECT id, user_id, method, success, reason, ip, user_agent, at, expires_at FROM login_history" +
+		" WHERE user_id = " + args.add(opts.UserID) + " AND expires_at > now()"
+	if opts.Cursor != nil {
+		objID, err := primitive.ObjectIDFromHex(opts.Cursor.ID)
+		if err != nil {
+			return nil, errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		query += " AND (at, id) < (" + args.add(storedTime(opts.Cursor.At)) + ", " + args.add(objID.Hex()) + ")"
+	}
+
+	limit := opts.GetLimit()
+	query += " ORDER BY at DESC, id DESC LIMIT " + args.add(limit+1)
+
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[loginEventPostgres])
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	return newLoginPage(lo.Map(events, func(e loginEventPostgres, _ int) model.LoginEvent { return e.toModel() }), limit), nil
+}
+
+func (r *LoginHistoryRepoPostgres) PurgeUserData(ctx context.Context, userID string) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, loginHistoryCollection, "PurgeUserData")
+	defer end(&err)
+
+	_, err = r.conn(ctx).Exec(ctx, "DELETE FROM login_history WHERE user_id = $1", userID)
+	if err != nil {
+		return errx.Postgres(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginHistoryRepoSQLite stores login events in SQLite. There is no TTL index, so expired events
+// are skipped when listing and a user's expired events are removed when a new one is recorded.
+type LoginHistoryRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewLoginHistoryRepoSQLite(db *sql.DB) *LoginHistoryRepoSQLite {
+	return &LoginHistoryRepoSQLite{
+		db: db,
+	}
+}
+
+var _ model.LoginHistoryRepo = (*LoginHistoryRepoSQLite)(nil)
+
+// conn runs statements in the transaction of ctx when there is one
+func (r *LoginHistoryRepoSQLite) conn(ctx context.Context) sqliteConn {
+	return sqliteConnFrom(ctx, r.db)
+}
+
+func (r *LoginHistoryRepoSQLite) Record(ctx context.Context, event model.LoginEvent) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, loginHistoryCollection, "Record")
+	defer end(&err)
+
+	// expired events are removed with the insert, inside the caller's transaction when there is one
+	return NewUnitOfWorkSQLite(r.db).WithTransaction(ctx, func(ctx context.Context) error {
+		_, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM login_history WHERE user_id = $1 AND expires_at <= $2",
+			event.UserID, unixMilli(time.Now()))
+		if err != nil {
+			return errx.SQLite(err)
+		}
+
+		_, err = r.conn(ctx).ExecContext(ctx, `INSERT INTO login_history (id, user_id, method, success, reason, ip, user_agent, at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			primitive.NewObjectID().Hex(), event.UserID, string(event.Method), event.Success, event.Reason,
+			event.IP, event.UserAgent, unixMilli(event.At), unixMilli(event.ExpiresAt),
+		)
+		if err != nil {
+			return errx.SQLite(err)
+		}
+
+		return nil
+	})
+}
+
+func (r *LoginHistoryRepoSQLite) List(ctx context.Context, opts model.ListLoginOpts) (_ *model.LoginPage, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, loginHistoryCollection, "List")
+	defer end(&err)
+
+	var args sqlArgs
+	query := "SELECT id, user_id, method, success, reason, ip, user_agent, at, expires_at FROM login_history" +
+		" WHERE user_id = " + args.add(opts.UserID) + " AND expires_at > " + args.add(unixMilli(time.Now()))
+	if opts.Cursor != nil {
+		objID, err := primitive.ObjectIDFromHex(opts.Cursor.ID)
+		if err != nil {
+			return nil, errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		query += " AND (at, id) < (" + args.add(unixMilli(opts.Cursor.At)) + ", " + args.add(objID.Hex()) + ")"
+	}
+
+	limit := opts.GetLimit()
+	query += " ORDER BY at DESC, id DESC LIMIT " + args.add(limit+1)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errx.SQLite(err)
+	}
+	defer rows.Close()
+
+	events := make([]model.LoginEvent, 0)
+	for rows.Next() {
+		var (
+			event         model.LoginEvent
+			method        string
+			at, expiresAt int64
+		)
+		err := rows.Scan(&event.ID, &event.UserID, &method, &event.Success, &event.Reason, &event.IP,
+			&event.UserAgent, &at, &expiresAt)
+		if err != nil {
+			return nil, errx.SQLite(err)
+		}
+
+		event.Method = model.LoginMethod(method)
+		event.At = time.UnixMilli(at).UTC()
+		event.ExpiresAt = time.UnixMilli(expiresAt).UTC()
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errx.SQLite(err)
+	}
+
+	return newLoginPage(events, limit), nil
+}
+
+func (r *LoginHistoryRepoSQLite) PurgeUserData(ctx context.Context, userID string) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, loginHistoryCollection, "PurgeUserData")
+	defer end(&err)
+
+	_, err = r.conn(ctx).ExecContext(ctx, "DELETE FROM login_history WHERE user_id = $1", userID)
+	if err != nil {
+		return errx.SQLite(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/pkg/metrics"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	tracer = tracing.Tracer("github.com/chai-rs/sevenhunter/internal/repo")
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "mongo",
+		Name:      "operation_duration_seconds",
+		Help:      "Mongo operation latency by collection and repository method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"collection", "operation"})
+
+	sqlDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "sql",
+		Name:      "operation_duration_seconds",
+		Help:      "SQL operation latency by database system, table and repository method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"system", "table", "operation"})
+
+	userCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "user_cache",
+		Name:      "lookups_total",
+		Help:      "User cache lookups by repository method and result, hit or miss.",
+	}, []string{"operation", "result"})
+
+	userCacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "user_cache",
+		Name:      "invalidations_total",
+		Help:      "Users dropped from the cache by source, write for this replica and change_stream for the others.",
+	}, []string{"source"})
+)
+
+// instrument starts a span for a repository method and returns a function that ends it and records the latency,
+// use with named results as `ctx, end := instrument(ctx, collection, "Method"); defer end(&err)`
+func instrument(ctx context.Context, collection, operation string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, collection+"."+operation)
+	span.SetAttributes(
+		attribute.String("db.system", "mongodb"),
+		attribute.String("db.collection.name", collection),
+	)
+
+	return ctx, func(err *error) {
+		mongoDuration.WithLabelValues(collection, operation).Observe(time.Since(start).Seconds())
+		tracing.End(span, err)
+	}
+}
+
+// instrumentSQL is instrument for the SQL repositories, system is the db.system of the database such as postgresql
+func instrumentSQL(ctx context.Context, system, table, operation string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, table+"."+operation)
+	span.SetAttributes(
+		attribute.String("db.system", system),
+		attribute.String("db.collection.name", table),
+	)
+
+	return ctx, func(err *error) {
+		sqlDuration.WithLabelValues(system, table, operation).Observe(time.Since(start).Seconds())
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+
+	"github.com/chai-rs/sevenhunter/pkg/postgres"
+	"github.com/chai-rs/sevenhunter/pkg/sqlite"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	//go:embed migrations/postgres/*.sql
+	postgresMigrations embed.FS
+
+	//go:embed migrations/sqlite/*.sql
+	sqliteMigrations embed.FS
+)
+
+// MigratePostgres creates or updates the schema of the Postgres repositories
+func MigratePostgres(ctx context.Context, pool *pgxpool.Pool) error {
+	migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return err
+	}
+
+	return postgres.Migrate(ctx, pool, migrations)
+}
+
+// MigrateSQLite creates or updates the schema of the SQLite repositories
+func MigrateSQLite(ctx context.Context, db *sql.DB) error {
+	migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+	if err != nil {
+		return err
+	}
+
+	return sqlite.Migrate(ctx, db, migrations)
+}
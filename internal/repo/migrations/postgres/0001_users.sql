@@ -0,0 +1,34 @@
+-- Users, mirroring the users collection. Ids are ObjectID hex strings so cursors and links
+-- work the same whichever driver stores them. Text that is sorted or filtered by prefix uses the
+-- "C" collation to compare bytes like Mongo does.
+CREATE TABLE users (
+    id              TEXT PRIMARY KEY,
+    name            TEXT COLLATE "C" NOT NULL,
+    email           TEXT COLLATE "C" NOT NULL,
+    email_canonical TEXT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    role            TEXT NOT NULL DEFAULT 'user',
+    status          TEXT NOT NULL DEFAULT 'active',
+    created_at      TIMESTAMPTZ NOT NULL,
+    deleted_at      TIMESTAMPTZ,
+    last_login_at   TIMESTAMPTZ,
+    version         BIGINT NOT NULL DEFAULT 1,
+    avatar_url      TEXT NOT NULL DEFAULT '',
+    bio             TEXT NOT NULL DEFAULT '',
+    locale          TEXT NOT NULL DEFAULT '',
+    timezone        TEXT NOT NULL DEFAULT '',
+    phone           TEXT NOT NULL DEFAULT '',
+    metadata        JSONB,
+    pending_email   TEXT NOT NULL DEFAULT '',
+    status_history  JSONB NOT NULL DEFAULT '[]'
+);
+
+-- the canonical email stays unique among deleted users so they can be restored
+CREATE UNIQUE INDEX users_email_key ON users (email);
+CREATE UNIQUE INDEX users_email_canonical_key ON users (email_canonical);
+
+CREATE INDEX users_created_at_id_idx ON users (created_at, id);
+CREATE INDEX users_name_id_idx ON users (name, id);
+CREATE INDEX users_email_id_idx ON users (email, id);
+CREATE INDEX users_role_status_id_idx ON users (role, status, id);
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
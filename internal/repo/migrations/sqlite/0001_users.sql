@@ -0,0 +1,33 @@
+-- Users, mirroring the users collection. Ids are ObjectID hex strings and times are Unix milliseconds,
+-- the precision Mongo stores, so they sort and compare as integers.
+CREATE TABLE users (
+    id              TEXT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    email           TEXT NOT NULL,
+    email_canonical TEXT NOT NULL,
+    hashed_password TEXT NOT NULL,
+    role            TEXT NOT NULL DEFAULT 'user',
+    status          TEXT NOT NULL DEFAULT 'active',
+    created_at      INTEGER NOT NULL,
+    deleted_at      INTEGER,
+    last_login_at   INTEGER,
+    version         INTEGER NOT NULL DEFAULT 1,
+    avatar_url      TEXT NOT NULL DEFAULT '',
+    bio             TEXT NOT NULL DEFAULT '',
+    locale          TEXT NOT NULL DEFAULT '',
+    timezone        TEXT NOT NULL DEFAULT '',
+    phone           TEXT NOT NULL DEFAULT '',
+    metadata        TEXT,
+    pending_email   TEXT NOT NULL DEFAULT '',
+    status_history  TEXT NOT NULL DEFAULT '[]'
+);
+
+-- the canonical email stays unique among deleted users so they can be restored
+CREATE UNIQUE INDEX users_email_key ON users (email);
+CREATE UNIQUE INDEX users_email_canonical_key ON users (email_canonical);
+
+CREATE INDEX users_created_at_id_idx ON users (created_at, id);
+CREATE INDEX users_name_id_idx ON users (name, id);
+CREATE INDEX users_email_id_idx ON users (email, id);
+CREATE INDEX users_role_status_id_idx ON users (role, status, id);
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Login history, expired events are skipped when listing and removed when the user logs in again
+CREATE TABLE login_history (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    method     TEXT NOT NULL,
+    success    INTEGER NOT NULL,
+    reason     TEXT NOT NULL DEFAULT '',
+    ip         TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    at         INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX login_history_user_id_at_id_idx ON login_history (user_id, at DESC, id DESC);
+CREATE INDEX login_history_expires_at_idx ON login_history (expires_at);
@@ -0,0 +1,79 @@
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+// NewLoginHistoryRepoFn returns an empty repository, it is called once per test
+type NewLoginHistoryRepoFn func(t *testing.T) model.LoginHistoryRepo
+
+// RunLoginHistoryRepo checks that a model.LoginHistoryRepo implementation behaves like the Mongo one
+func RunLoginHistoryRepo(t *testing.T, newRepo NewLoginHistoryRepoFn) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo model.LoginHistoryRepo)
+	}{
+		{"list", testListLogins},
+		{"purge user data", testPurgeLogins},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo(t))
+		})
+	}
+}
+
+// recordLogins records three events of userID a minute apart, an expired one and one of another user
+func recordLogins(t *testing.T, repo model.LoginHistoryRepo, userID string, now time.Time) {
+	t.Helper()
+	ctx := context.Background()
+
+	for i := range 3 {
+		require.NoError(t, repo.Record(ctx, model.LoginEvent{
+			UserID:    userID,
+			Method:    model.LoginMethodPassword,
+			Success:   true,
+			At:        now.Add(time.Duration(i) * time.Minute),
+			ExpiresAt: now.Add(time.Hour),
+		}))
+	}
+	require.NoError(t, repo.Record(ctx, model.LoginEvent{UserID: userID, At: now, ExpiresAt: now.Add(-time.Second)}))
+	require.NoError(t, repo.Record(ctx, model.LoginEvent{UserID: "other", At: now, ExpiresAt: now.Add(time.Hour)}))
+}
+
+func testListLogins(t *testing.T, repo model.LoginHistoryRepo) {
+	ctx := context.Background()
+	recordLogins(t, repo, "123", time.Now())
+
+	first, err := repo.List(ctx, model.ListLoginOpts{UserID: "123", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first.Events, 2)
+	require.True(t, first.HasMore)
+	require.True(t, first.Events[0].At.After(first.Events[1].At))
+
+	// the expired event and the one of the other user are left out
+	second, err := repo.List(ctx, model.ListLoginOpts{UserID: "123", Limit: 2, Cursor: first.Next})
+	require.NoError(t, err)
+	require.Len(t, second.Events, 1)
+	require.False(t, second.HasMore)
+}
+
+func testPurgeLogins(t *testing.T, repo model.LoginHistoryRepo) {
+	ctx := context.Background()
+	recordLogins(t, repo, "123", time.Now())
+
+	require.NoError(t, repo.PurgeUserData(ctx, "123"))
+	page, err := repo.List(ctx, model.ListLoginOpts{UserID: "123"})
+	require.NoError(t, err)
+	require.Empty(t, page.Events)
+
+	others, err := repo.List(ctx, model.ListLoginOpts{UserID: "other"})
+	require.NoError(t, err)
+	require.Len(t, others.Events, 1)
+}
@@ -0,0 +1,157 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+// UnitOfWorkStores are empty repositories of one database and the unit of work of that database
+type UnitOfWorkStores struct {
+	UnitOfWork   model.UnitOfWork
+	UserRepo     model.UserRepo
+	LoginHistory model.LoginHistoryRepo
+}
+
+// NewUnitOfWorkFn returns empty stores, it is called once per test
+type NewUnitOfWorkFn func(t *testing.T) UnitOfWorkStores
+
+var errRollback = errors.New("roll back")
+
+// RunUnitOfWork checks that the repositories take part in the transactions of a model.UnitOfWork
+func RunUnitOfWork(t *testing.T, newStores NewUnitOfWorkFn) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, stores UnitOfWorkStores)
+	}{
+		{"commit", testCommit},
+		{"rollback", testRollback},
+		{"nested", testNestedTransaction},
+		{"after_commit", testAfterCommit},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newStores(t))
+		})
+	}
+}
+
+func testCommit(t *testing.T, stores UnitOfWorkStores) {
+	ctx := context.Background()
+
+	var created *model.User
+	err := stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = stores.UserRepo.Create(ctx, newUser(t, "John Doe", "john@example.com", time.Now()))
+		if err != nil {
+			return err
+		}
+
+		// the transaction sees its own writes
+		_, err = stores.UserRepo.FindByID(ctx, created.ID())
+		return err
+	})
+	require.NoError(t, err)
+
+	found, err := stores.UserRepo.FindByID(ctx, created.ID())
+	require.NoError(t, err)
+	require.Equal(t, "john@example.com", found.Email())
+}
+
+func testRollback(t *testing.T, stores UnitOfWorkStores) {
+	ctx := context.Background()
+	existing := create(t, stores.UserRepo, "John Doe", "john@example.com", time.Now())
+
+	err := stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := stores.UserRepo.Create(ctx, newUser(t, "Jane Doe", "jane@example.com", time.Now())); err != nil {
+			return err
+		}
+		if err := stores.UserRepo.Delete(ctx, existing.ID()); err != nil {
+			return err
+		}
+		err := stores.LoginHistory.Record(ctx, model.LoginEvent{
+			UserID:    existing.ID(),
+			Method:    model.LoginMethodPassword,
+			Success:   true,
+			At:        time.Now(),
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	_, err = stores.UserRepo.FindByEmail(ctx, "jane@example.com")
+	requireCode(t, err, http.StatusNotFound)
+
+	exists, err := stores.UserRepo.ExistsByID(ctx, existing.ID())
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	page, err := stores.LoginHistory.List(ctx, model.ListLoginOpts{UserID: existing.ID()})
+	require.NoError(t, err)
+	require.Empty(t, page.Events)
+}
+
+func testNestedTransaction(t *testing.T, stores UnitOfWorkStores) {
+	ctx := context.Background()
+
+	err := stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		// an operation that runs its own transaction joins the one of its caller
+		err := stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+			_, err := stores.UserRepo.Create(ctx, newUser(t, "John Doe", "john@example.com", time.Now()))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	_, err = stores.UserRepo.FindByEmail(ctx, "john@example.com")
+	requireCode(t, err, http.StatusNotFound)
+}
+
+func testAfterCommit(t *testing.T, stores UnitOfWorkStores) {
+	ctx := context.Background()
+	require.False(t, model.InTransaction(ctx))
+
+	var ran []string
+	err := stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		require.True(t, model.InTransaction(ctx))
+		model.AfterCommit(ctx, func() { ran = append(ran, "outer") })
+
+		// hooks of a nested transaction wait for the one it joined
+		err := stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+			model.AfterCommit(ctx, func() { ran = append(ran, "nested") })
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		require.Empty(t, ran)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"outer", "nested"}, ran)
+
+	ran = nil
+	err = stores.UnitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		model.AfterCommit(ctx, func() { ran = append(ran, "rolled back") })
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	require.Empty(t, ran)
+}
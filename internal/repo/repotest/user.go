@@ -0,0 +1,451 @@
+// Package repotest holds the behavior every repository implementation has to share,
+// so the in-memory stores used in tests cannot drift from the real ones
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// NewUserRepoFn returns an empty repository, it is called once per test
+type NewUserRepoFn func(t *testing.T) model.UserRepo
+
+// unknownUserID is a valid id that no repository ever hands out
+const unknownUserID = "000000000000000000000000"
+
+// RunUserRepo checks that a model.UserRepo implementation behaves like the Mongo one
+func RunUserRepo(t *testing.T, newRepo NewUserRepoFn) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo model.UserRepo)
+	}{
+		{"create and find", testCreateAndFind},
+		{"duplicate email", testDuplicateEmail},
+		{"not found", testNotFound},
+		{"update", testUpdate},
+		{"delete restore and purge", testDeleteRestorePurge},
+		{"record login", testRecordLogin},
+		{"list", testList},
+		{"list filters", testListFilters},
+		{"concurrent creates", testConcurrentCreates},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newRepo(t))
+		})
+	}
+}
+
+func newUser(t *testing.T, name, email string, createdAt time.Time) *model.User {
+	t.Helper()
+
+	user, err := model.NewUser(model.UserOpts{
+		Name:           name,
+		Email:          email,
+		HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		CreatedAt:      createdAt,
+	})
+	require.NoError(t, err)
+	return user
+}
+
+func create(t *testing.T, repo model.UserRepo, name, email string, createdAt time.Time) *model.User {
+	t.Helper()
+
+	user, err := repo.Create(context.Background(), newUser(t, name, email, createdAt))
+	require.NoError(t, err)
+	return user
+}
+
+// requireCode checks that err is an errx error with the given status code
+func requireCode(t *testing.T, err error, code int) {
+	t.Helper()
+
+	var appErr *errx.Error
+	require.True(t, errors.As(err, &appErr), "expected an errx error, got %v", err)
+	require.Equal(t, code, appErr.Code, appErr.Message)
+}
+
+// storedTime is how dates come back from every store, rounded to the millisecond in UTC
+func storedTime(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond).UTC()
+}
+
+func testCreateAndFind(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	createdAt := time.Now()
+
+	created := create(t, repo, "John Doe", "John.Doe@example.com", createdAt)
+	require.Len(t, created.ID(), 24)
+	require.Equal(t, "John.Doe@example.com", created.Email())
+
+	found, err := repo.FindByID(ctx, created.ID())
+	require.NoError(t, err)
+	require.Equal(t, created.ID(), found.ID())
+	require.Equal(t, "John Doe", found.Name())
+	require.Equal(t, "John.Doe@example.com", found.Email())
+	require.Equal(t, model.UserRoleUser, found.Role())
+	require.Equal(t, model.UserStatusActive, found.Status())
+	require.EqualValues(t, 1, found.Version())
+	require.Equal(t, storedTime(createdAt), found.CreatedAt())
+	require.False(t, found.IsDeleted())
+	require.Equal(t, created.HashedPassword(), found.HashedPassword())
+
+	// users are matched by their canonical email
+	found, err = repo.FindByEmail(ctx, "john.doe@EXAMPLE.com")
+	require.NoError(t, err)
+	require.Equal(t, created.ID(), found.ID())
+
+	exists, err := repo.ExistsByID(ctx, created.ID())
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	create(t, repo, "Jane Doe", "jane@example.com", createdAt)
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+}
+
+func testDuplicateEmail(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+
+	create(t, repo, "John Doe", "john@example.com", time.Now())
+	jane := create(t, repo, "Jane Doe", "jane@example.com", time.Now())
+
+	_, err := repo.Create(ctx, newUser(t, "John Again", "JOHN@example.com", time.Now()))
+	require.ErrorIs(t, err, model.ErrEmailTaken)
+	requireCode(t, err, http.StatusConflict)
+
+	require.NoError(t, jane.Update(model.UpdateUserOpts{ID: jane.ID(), Email: lo.ToPtr("john@example.com")}))
+	require.NoError(t, jane.ConfirmEmail("john@example.com"))
+	require.ErrorIs(t, repo.Update(ctx, jane), model.ErrEmailTaken)
+
+	// a failed update leaves the user as it was
+	found, err := repo.FindByID(ctx, jane.ID())
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", found.Email())
+	require.EqualValues(t, 1, found.Version())
+}
+
+func testNotFound(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+
+	_, err := repo.FindByID(ctx, unknownUserID)
+	requireCode(t, err, http.StatusNotFound)
+
+	_, err = repo.FindByID(ctx, "not-an-id")
+	requireCode(t, err, http.StatusBadRequest)
+
+	_, err = repo.FindByEmail(ctx, "nobody@example.com")
+	requireCode(t, err, http.StatusNotFound)
+
+	_, err = repo.FindDeletedByEmail(ctx, "nobody@example.com")
+	requireCode(t, err, http.StatusNotFound)
+
+	exists, err := repo.ExistsByID(ctx, unknownUserID)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	requireCode(t, repo.Restore(ctx, unknownUserID), http.StatusNotFound)
+
+	// deleting, purging or recording a login of a missing user does nothing
+	require.NoError(t, repo.Delete(ctx, unknownUserID))
+	require.NoError(t, repo.Purge(ctx, unknownUserID))
+	require.NoError(t, repo.RecordLogin(ctx, unknownUserID, time.Now()))
+
+	user := create(t, repo, "John Doe", "john@example.com", time.Now())
+	require.NoError(t, repo.Delete(ctx, user.ID()))
+	require.NoError(t, user.Update(model.UpdateUserOpts{ID: user.ID(), Name: lo.ToPtr("Johnny")}))
+	requireCode(t, repo.Update(ctx, user), http.StatusNotFound)
+}
+
+func testUpdate(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	created := create(t, repo, "John Doe", "john@example.com", time.Now())
+
+	stale, err := repo.FindByID(ctx, created.ID())
+	require.NoError(t, err)
+
+	user, err := repo.FindByID(ctx, created.ID())
+	require.NoError(t, err)
+	require.NoError(t, user.Update(model.UpdateUserOpts{
+		ID:       user.ID(),
+		Name:     lo.ToPtr("Johnny"),
+		Bio:      lo.ToPtr("hello"),
+		Locale:   lo.ToPtr("th-TH"),
+		Metadata: map[string]*string{"team": lo.ToPtr("blue")},
+	}))
+	require.NoError(t, repo.Update(ctx, user))
+	require.EqualValues(t, 2, user.Version())
+	require.Empty(t, user.Changes())
+
+	found, err := repo.FindByID(ctx, created.ID())
+	require.NoError(t, err)
+	require.Equal(t, "Johnny", found.Name())
+	require.Equal(t, "hello", found.Bio())
+	require.Equal(t, "th-TH", found.Locale())
+	require.Equal(t, map[string]string{"team": "blue"}, found.Metadata())
+	require.EqualValues(t, 2, found.Version())
+
+	// saving without changes is a no-op
+	require.NoError(t, repo.Update(ctx, found))
+	require.EqualValues(t, 2, found.Version())
+
+	require.NoError(t, stale.Update(model.UpdateUserOpts{ID: stale.ID(), Name: lo.ToPtr("Stale")}))
+	require.ErrorIs(t, repo.Update(ctx, stale), model.ErrUserVersionMismatch)
+
+	// cleared fields come back empty
+	require.NoError(t, found.Update(model.UpdateUserOpts{ID: found.ID(), Bio: lo.ToPtr(""), ReplaceMetadata: true}))
+	require.NoError(t, repo.Update(ctx, found))
+
+	found, err = repo.FindByID(ctx, created.ID())
+	require.NoError(t, err)
+	require.Empty(t, found.Bio())
+	require.Empty(t, found.Metadata())
+	require.EqualValues(t, 3, found.Version())
+}
+
+func testDeleteRestorePurge(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	user := create(t, repo, "John Doe", "john@example.com", time.Now())
+	other := create(t, repo, "Jane Doe", "jane@example.com", time.Now())
+
+	require.NoError(t, repo.Delete(ctx, user.ID()))
+	_, err := repo.FindByID(ctx, user.ID())
+	requireCode(t, err, http.StatusNotFound)
+	_, err = repo.FindByEmail(ctx, "john@example.com")
+	requireCode(t, err, http.StatusNotFound)
+
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	deleted, err := repo.FindDeletedByEmail(ctx, "JOHN@example.com")
+	require.NoError(t, err)
+	require.Equal(t, user.ID(), deleted.ID())
+	require.True(t, deleted.IsDeleted())
+
+	// the address stays taken while the account can be restored
+	_, err = repo.Create(ctx, newUser(t, "John Again", "john@example.com", time.Now()))
+	require.ErrorIs(t, err, model.ErrEmailTaken)
+
+	require.NoError(t, repo.Restore(ctx, user.ID()))
+	requireCode(t, repo.Restore(ctx, user.ID()), http.StatusNotFound)
+	_, err = repo.FindByID(ctx, user.ID())
+	require.NoError(t, err)
+
+	// purging only removes deleted users
+	require.NoError(t, repo.Purge(ctx, other.ID()))
+	_, err = repo.FindByID(ctx, other.ID())
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(ctx, user.ID()))
+	// deletion times are stored to the millisecond
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, repo.Delete(ctx, other.ID()))
+
+	users, err := repo.ListDeleted(ctx, time.Now().Add(time.Minute), 1)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, user.ID(), users[0].ID())
+
+	users, err = repo.ListDeleted(ctx, time.Now().Add(-time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, users)
+
+	require.NoError(t, repo.Purge(ctx, user.ID()))
+	_, err = repo.FindDeletedByEmail(ctx, "john@example.com")
+	requireCode(t, err, http.StatusNotFound)
+	create(t, repo, "John Again", "john@example.com", time.Now())
+}
+
+func testRecordLogin(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	user := create(t, repo, "John Doe", "john@example.com", time.Now())
+
+	at := time.Now().Add(time.Minute)
+	require.NoError(t, repo.RecordLogin(ctx, user.ID(), at))
+	// an older login finishing late does not move it back
+	require.NoError(t, repo.RecordLogin(ctx, user.ID(), at.Add(-time.Hour)))
+
+	found, err := repo.FindByID(ctx, user.ID())
+	require.NoError(t, err)
+	require.Equal(t, storedTime(at), found.LastLoginAt())
+	require.EqualValues(t, 1, found.Version())
+}
+
+func userNames(page *model.UserPage) []string {
+	return lo.Map(page.Users, func(u model.User, _ int) string { return u.Name() })
+}
+
+func testList(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	start := time.Now()
+
+	// names sort in the opposite order of creation
+	for i := range 5 {
+		create(t, repo, fmt.Sprintf("User %d", 4-i), fmt.Sprintf("user%d@example.com", i), start.Add(time.Duration(i)*time.Second))
+	}
+	// deleted users are never listed
+	deleted := create(t, repo, "User 9", "deleted@example.com", start)
+	require.NoError(t, repo.Delete(ctx, deleted.ID()))
+
+	byCreation := []string{"User 4", "User 3", "User 2", "User 1", "User 0"}
+	byName := lo.Reverse(append([]string(nil), byCreation...))
+
+	for _, sort := range []struct {
+		field     model.UserSortField
+		ascending []string
+	}{
+		{model.UserSortCreatedAt, byCreation},
+		{model.UserSortName, byName},
+	} {
+		for _, sortAsc := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s sort_asc=%t", sort.field, sortAsc), func(t *testing.T) {
+				expected := sort.ascending
+				if !sortAsc {
+					expected = lo.Reverse(append([]string(nil), expected...))
+				}
+
+				first, err := repo.List(ctx, model.ListUserOpts{Limit: 2, Sort: sort.field, SortAsc: sortAsc})
+				require.NoError(t, err)
+				require.Equal(t, expected[:2], userNames(first))
+				require.True(t, first.HasMore)
+				require.Nil(t, first.Prev)
+
+				second, err := repo.List(ctx, model.ListUserOpts{Limit: 2, Cursor: first.Next})
+				require.NoError(t, err)
+				require.Equal(t, expected[2:4], userNames(second))
+				require.True(t, second.HasMore)
+				require.NotNil(t, second.Prev)
+
+				last, err := repo.List(ctx, model.ListUserOpts{Limit: 2, Cursor: second.Next})
+				require.NoError(t, err)
+				require.Equal(t, expected[4:], userNames(last))
+				require.False(t, last.HasMore)
+				require.Nil(t, last.Next)
+
+				back, err := repo.List(ctx, model.ListUserOpts{Limit: 2, Cursor: last.Prev})
+				require.NoError(t, err)
+				require.Equal(t, expected[2:4], userNames(back))
+				require.True(t, back.HasMore)
+
+				back, err = repo.List(ctx, model.ListUserOpts{Limit: 2, Cursor: back.Prev})
+				require.NoError(t, err)
+				require.Equal(t, expected[:2], userNames(back))
+				require.Nil(t, back.Prev)
+			})
+		}
+	}
+
+	t.Run("ties are broken by id", func(t *testing.T) {
+		twin := create(t, repo, "User 2", "twin@example.com", start)
+
+		page, err := repo.List(ctx, model.ListUserOpts{Limit: 10, Sort: model.UserSortName, SortAsc: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"User 0", "User 1", "User 2", "User 2", "User 3", "User 4"}, userNames(page))
+		// ObjectIDs grow over time, so the twin created last comes last
+		require.Equal(t, twin.ID(), page.Users[3].ID())
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := repo.List(ctx, model.ListUserOpts{Cursor: &model.UserCursor{Sort: model.UserSortName, ID: "not-an-id"}})
+		requireCode(t, err, http.StatusBadRequest)
+	})
+}
+
+func testListFilters(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+
+	create(t, repo, "Alice Smith", "alice@example.com", start)
+	create(t, repo, "Bob Stone", "bob.s@example.com", start.Add(time.Minute))
+	carol := create(t, repo, "Carol Smith", "carol@example.com", start.Add(2*time.Minute))
+
+	require.NoError(t, carol.ChangeStatus(model.ChangeUserStatusOpts{
+		ID:        carol.ID(),
+		Status:    model.UserStatusSuspended,
+		Reason:    "spam",
+		ChangedBy: unknownUserID,
+	}, time.Now()))
+	require.NoError(t, repo.Update(ctx, carol))
+
+	tests := []struct {
+		name     string
+		opts     model.ListUserOpts
+		expected []string
+	}{
+		{"name prefix", model.ListUserOpts{NamePrefix: "Bo"}, []string{"Bob Stone"}},
+		{"name prefix is case-sensitive", model.ListUserOpts{NamePrefix: "bo"}, []string{}},
+		{"email prefix", model.ListUserOpts{EmailPrefix: "carol@"}, []string{"Carol Smith"}},
+		{"search name or email", model.ListUserOpts{Search: "SMITH"}, []string{"Alice Smith", "Carol Smith"}},
+		{"search email", model.ListUserOpts{Search: "B.S@"}, []string{"Bob Stone"}},
+		{"created from", model.ListUserOpts{CreatedFrom: start.Add(time.Minute)}, []string{"Bob Stone", "Carol Smith"}},
+		{"created to", model.ListUserOpts{CreatedTo: start.Add(time.Minute)}, []string{"Alice Smith", "Bob Stone"}},
+		{"role", model.ListUserOpts{Role: model.UserRoleUser}, []string{"Alice Smith", "Bob Stone", "Carol Smith"}},
+		{"admin role", model.ListUserOpts{Role: model.UserRoleAdmin}, []string{}},
+		{"status", model.ListUserOpts{Status: model.UserStatusSuspended}, []string{"Carol Smith"}},
+		{"active status", model.ListUserOpts{Status: model.UserStatusActive}, []string{"Alice Smith", "Bob Stone"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.SortAsc = true
+			page, err := repo.List(ctx, tc.opts)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, userNames(page))
+		})
+	}
+}
+
+func testConcurrentCreates(t *testing.T, repo model.UserRepo) {
+	ctx := context.Background()
+	const workers = 20
+
+	users := make([]*model.User, 0, workers*2)
+	for i := range workers {
+		users = append(users, newUser(t, "Distinct User", fmt.Sprintf("user%d@example.com", i), time.Now()))
+		// as many users race for the same address, only one of them can get it
+		users = append(users, newUser(t, "Shared User", "shared@example.com", time.Now()))
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(users))
+	for _, user := range users {
+		wg.Go(func() {
+			_, err := repo.Create(ctx, user)
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	var created, taken int
+	for err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, model.ErrEmailTaken):
+			taken++
+		default:
+			require.NoError(t, err)
+		}
+	}
+	require.Equal(t, workers+1, created)
+	require.Equal(t, workers-1, taken)
+
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, workers+1, count)
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"github.com/chai-rs/sevenhunter/pkg/mongo/schema"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UserSchema is the users collection UserRepo relies on. The migrations build it, a migration that
+// changes its indexes or validator updates this spec too, TestMigrations checks that both agree.
+var UserSchema = schema.Collection{
+	Name: userCollection,
+	Validator: bson.M{"$jsonSchema": bson.M{
+		"bsonType": "object",
+		"required": bson.A{"_id", "email", "name", "hashed_password", "created_at"},
+		"properties": bson.D{
+			{Key: "_id", Value: bson.M{"bsonType": "objectId", "description": "auto-generated unique identifier"}},
+			{Key: "email", Value: bson.M{"bsonType": "string", "description": "must be a string and is required"}},
+			{Key: "hashed_password", Value: bson.M{"bsonType": "string", "description": "must be a string and is required"}},
+			{Key: "name", Value: bson.M{"bsonType": "string", "description": "must be a string if provided"}},
+			{Key: "created_at", Value: bson.M{"bsonType": "date", "description": "must be a date and is required"}},
+			{Key: "avatar_url", Value: bson.M{"bsonType": "string", "maxLength": 2048, "description": "http or https URL of the avatar if provided"}},
+			{Key: "bio", Value: bson.M{"bsonType": "string", "description": "free text of at most 500 characters if provided"}},
+			{Key: "locale", Value: bson.M{"bsonType": "string", "description": "BCP 47 language tag if provided"}},
+			{Key: "timezone", Value: bson.M{"bsonType": "string", "description": "IANA time zone name if provided"}},
+			{Key: "phone", Value: bson.M{"bsonType": "string", "pattern": `^\+[1-9][0-9]{6,14}$`, "description": "E.164 phone number if provided"}},
+			{Key: "metadata", Value: bson.M{
+				"bsonType":             "object",
+				"maxProperties":        20,
+				"additionalProperties": bson.M{"bsonType": "string", "maxLength": 500},
+				"description":          "at most 20 string entries if provided",
+			}},
+		},
+	}},
+	Indexes: []schema.Index{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Unique: true},
+		{Keys: bson.D{{Key: "email_canonical", Value: 1}}, Unique: true},
+		{Keys: bson.D{{Key: "name", Value: 1}}, Sparse: true},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}},
+		{
+			Keys:          bson.D{{Key: "deleted_at", Value: 1}},
+			PartialFilter: bson.M{"deleted_at": bson.M{"$type": "date"}},
+		},
+	},
+}
+
+// LoginHistorySchema is the login_history collection LoginHistoryRepo relies on
+var LoginHistorySchema = schema.Collection{
+	Name: loginHistoryCollection,
+	Indexes: []schema.Index{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfterSeconds: lo.ToPtr(int32(0))},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "at", Value: -1}, {Key: "_id", Value: -1}}},
+	},
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// statusChangeSQL is a status change in the JSON status_history column
+type statusChangeSQL struct {
+	From      model.UserStatus `json:"from"`
+	To        model.UserStatus `json:"to"`
+	Reason    string           `json:"reason"`
+	ChangedBy string           `json:"changed_by"`
+	ChangedAt time.Time        `json:"changed_at"`
+}
+
+func newStatusHistorySQL(history []model.UserStatusChange) []statusChangeSQL {
+	return lo.Map(history, func(item model.UserStatusChange, _ int) statusChangeSQL {
+		item.ChangedAt = storedTime(item.ChangedAt)
+		return statusChangeSQL(item)
+	})
+}
+
+func statusHistorySQL(history []statusChangeSQL) []model.UserStatusChange {
+	return lo.Map(history, func(item statusChangeSQL, _ int) model.UserStatusChange {
+		item.ChangedAt = item.ChangedAt.UTC()
+		return model.UserStatusChange(item)
+	})
+}
+
+// sqlUserID validates an id the way the Mongo repositories do and returns its canonical form,
+// the SQL repositories keep ObjectID hex ids so cursors and links work the same with every driver
+func sqlUserID(id string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", ErrInvalidUserID(err)
+	}
+	return objID.Hex(), nil
+}
+
+// sqlArgs collects query arguments, add returns the $n placeholder of the argument
+type sqlArgs []any
+
+func (a *sqlArgs) add(value any) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// likeEscaper escapes the LIKE wildcards with a backslash, the default escape character of Postgres
+// and the one the SQLite queries declare with ESCAPE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
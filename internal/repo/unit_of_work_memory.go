@@ -0,0 +1,60 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+)
+
+type memoryTxKey struct{}
+
+// memoryTx collects how to undo the writes of the memory repositories
+type memoryTx struct {
+	mu   sync.Mutex
+	undo []func()
+}
+
+// onRollback registers how to undo a write when ctx carries a MemoryUnitOfWork transaction
+func onRollback(ctx context.Context, undo func()) {
+	tx, ok := ctx.Value(memoryTxKey{}).(*memoryTx)
+	if !ok {
+		return
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.undo = append(tx.undo, undo)
+}
+
+// MemoryUnitOfWork gives the memory repositories atomic transactions for tests and local development.
+// Writes are undone in reverse order when fn fails, they are not isolated from concurrent readers.
+type MemoryUnitOfWork struct{}
+
+func NewMemoryUnitOfWork() *MemoryUnitOfWork {
+	return &MemoryUnitOfWork{}
+}
+
+var _ model.UnitOfWork = (*MemoryUnitOfWork)(nil)
+
+func (u *MemoryUnitOfWork) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(memoryTxKey{}).(*memoryTx); ok {
+		return fn(ctx)
+	}
+
+	tx := &memoryTx{}
+	txCtx, committed := model.NewTransactionContext(context.WithValue(ctx, memoryTxKey{}, tx))
+	if err := fn(txCtx); err != nil {
+		tx.mu.Lock()
+		defer tx.mu.Unlock()
+
+		for _, undo := range slices.Backward(tx.undo) {
+			undo()
+		}
+		return err
+	}
+
+	committed()
+	return nil
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// UnitOfWork runs transactions on Mongo sessions, the driver picks the session up from the ctx handed to fn
+// so the Mongo repositories take part without knowing about it. Transactions need a replica set or a sharded cluster.
+type UnitOfWork struct {
+	client *mongo.Client
+}
+
+func NewUnitOfWork(client *mongo.Client) *UnitOfWork {
+	return &UnitOfWork{
+		client: client,
+	}
+}
+
+var _ model.UnitOfWork = (*UnitOfWork)(nil)
+
+// WithTransaction retries fn on transient errors and retries the commit when its outcome is unknown
+func (u *UnitOfWork) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, "mongo.WithTransaction")
+	defer tracing.End(span, &err)
+
+	session, err := u.client.StartSession()
+	if err != nil {
+		return errx.Mongo(err)
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	// the error of fn is returned as is, only those of the transaction itself are mapped
+	var (
+		fnErr     error
+		committed func()
+	)
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+		// a retried attempt starts without the hooks of the one that failed
+		var txCtx context.Context
+		txCtx, committed = model.NewTransactionContext(ctx)
+		fnErr = fn(txCtx)
+		return nil, fnErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return errx.Mongo(err)
+	}
+
+	committed()
+	return nil
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresTxKey is keyed by pool so a transaction is only used by the repositories of its own database
+type postgresTxKey struct {
+	pool *pgxpool.Pool
+}
+
+// postgresConn runs the statements of the Postgres repositories, *pgxpool.Pool and pgx.Tx both are one
+type postgresConn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, batch *pgx.Batch) pgx.BatchResults
+}
+
+// postgresConnFrom returns the transaction running in ctx, or pool outside of one
+func postgresConnFrom(ctx context.Context, pool *pgxpool.Pool) postgresConn {
+	if tx, ok := ctx.Value(postgresTxKey{pool}).(pgx.Tx); ok {
+		return tx
+	}
+	return pool
+}
+
+// UnitOfWorkPostgres runs transactions on a connection of the pool
+type UnitOfWorkPostgres struct {
+	pool *pgxpool.Pool
+}
+
+func NewUnitOfWorkPostgres(pool *pgxpool.Pool) *UnitOfWorkPostgres {
+	return &UnitOfWorkPostgres{
+		pool: pool,
+	}
+}
+
+var _ model.UnitOfWork = (*UnitOfWorkPostgres)(nil)
+
+func (u *UnitOfWorkPostgres) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	key := postgresTxKey{u.pool}
+	if _, ok := ctx.Value(key).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, "postgres.WithTransaction")
+	defer tracing.End(span, &err)
+
+	tx, err := u.pool.Begin(ctx)
+	if err != nil {
+		return errx.Postgres(err)
+	}
+	defer func() { _ = tx.Rollback(context.WithoutCancel(ctx)) }()
+
+	txCtx, committed := model.NewTransactionContext(context.WithValue(ctx, key, tx))
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errx.Postgres(err)
+	}
+
+	committed()
+	return nil
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+)
+
+// sqliteTxKey is keyed by database so a transaction is only used by the repositories of its own database
+type sqliteTxKey struct {
+	db *sql.DB
+}
+
+// sqliteConn runs the statements of the SQLite repositories, *sql.DB and *sql.Tx both are one
+type sqliteConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqliteConnFrom returns the transaction running in ctx, or db outside of one
+func sqliteConnFrom(ctx context.Context, db *sql.DB) sqliteConn {
+	if tx, ok := ctx.Value(sqliteTxKey{db}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// UnitOfWorkSQLite runs transactions on the SQLite database. There is a single connection, so other
+// requests wait for the transaction and fn must only reach the database through the repositories.
+type UnitOfWorkSQLite struct {
+	db *sql.DB
+}
+
+func NewUnitOfWorkSQLite(db *sql.DB) *UnitOfWorkSQLite {
+	return &UnitOfWorkSQLite{
+		db: db,
+	}
+}
+
+var _ model.UnitOfWork = (*UnitOfWorkSQLite)(nil)
+
+func (u *UnitOfWorkSQLite) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	key := sqliteTxKey{u.db}
+	if _, ok := ctx.Value(key).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, "sqlite.WithTransaction")
+	defer tracing.End(span, &err)
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errx.SQLite(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	txCtx, committed := model.NewTransactionContext(context.WithValue(ctx, key, tx))
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errx.SQLite(err)
+	}
+
+	committed()
+	return nil
+}
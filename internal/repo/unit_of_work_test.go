@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+	mongox "github.com/chai-rs/sevenhunter/pkg/mongo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUnitOfWork(t *testing.T) {
+	repotest.RunUnitOfWork(t, func(t *testing.T) repotest.UnitOfWorkStores {
+		return repotest.UnitOfWorkStores{
+			UnitOfWork:   NewMemoryUnitOfWork(),
+			UserRepo:     NewMemoryUserRepo(),
+			LoginHistory: NewMemoryLoginHistoryRepo(),
+		}
+	})
+}
+
+func TestUnitOfWorkSQLite(t *testing.T) {
+	repotest.RunUnitOfWork(t, func(t *testing.T) repotest.UnitOfWorkStores {
+		db := newTestSQLiteDB(t)
+		return repotest.UnitOfWorkStores{
+			UnitOfWork:   NewUnitOfWorkSQLite(db),
+			UserRepo:     NewUserRepoSQLite(db),
+			LoginHistory: NewLoginHistoryRepoSQLite(db),
+		}
+	})
+}
+
+func TestUnitOfWorkPostgres(t *testing.T) {
+	repotest.RunUnitOfWork(t, func(t *testing.T) repotest.UnitOfWorkStores {
+		pool := newTestPostgresPool(t)
+		return repotest.UnitOfWorkStores{
+			UnitOfWork:   NewUnitOfWorkPostgres(pool),
+			UserRepo:     NewUserRepoPostgres(pool),
+			LoginHistory: NewLoginHistoryRepoPostgres(pool),
+		}
+	})
+}
+
+func TestUnitOfWork(t *testing.T) {
+	repotest.RunUnitOfWork(t, func(t *testing.T) repotest.UnitOfWorkStores {
+		ctx := context.Background()
+		db := newTestMongoDatabase(t)
+
+		ok, err := mongox.SupportsTransactions(ctx, db.Client())
+		require.NoError(t, err)
+		if !ok {
+			t.Skip("MONGO_TEST_URI does not point at a replica set")
+		}
+
+		// older servers cannot create collections inside a transaction
+		require.NoError(t, db.CreateCollection(ctx, userCollection))
+		require.NoError(t, db.CreateCollection(ctx, loginHistoryCollection))
+
+		return repotest.UnitOfWorkStores{
+			UnitOfWork:   NewUnitOfWork(db.Client()),
+			UserRepo:     NewUserRepo(db),
+			LoginHistory: NewLoginHistoryRepo(db),
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/pkg/cache"
+)
+
+const (
+	invalidatedByWrite        = "write"
+	invalidatedByChangeStream = "change_stream"
+)
+
+// cachedUser is a user looked up by id, user is nil when only ExistsByID was asked
+type cachedUser struct {
+	user   *model.User
+	exists bool
+}
+
+type CachedUserRepoOpts struct {
+	Repo model.UserRepo
+	// Size is the number of users kept
+	Size int
+	// TTL bounds how long a change made by another replica can go unnoticed without WatchUserChanges
+	TTL time.Duration
+}
+
+// CachedUserRepo serves FindByID and ExistsByID from memory, so authenticating a request and loading
+// its user does not reach the database every time. Writes through it drop the user from the cache,
+// writes by other replicas only do with WatchUserChanges, otherwise the entry lives until its TTL.
+// Inside a transaction it goes straight to the repository, and writes drop the user again once the
+// transaction committed since readers outside of it may have cached the old user in the meantime.
+type CachedUserRepo struct {
+	repo  model.UserRepo
+	users *cache.LRU[string, cachedUser]
+}
+
+func NewCachedUserRepo(opts CachedUserRepoOpts) *CachedUserRepo {
+	return &CachedUserRepo{
+		repo:  opts.Repo,
+		users: cache.NewLRU[string, cachedUser](opts.Size, opts.TTL),
+	}
+}
+
+var _ model.UserRepo = (*CachedUserRepo)(nil)
+
+func (r *CachedUserRepo) FindByID(ctx context.Context, id string) (*model.User, error) {
+	// the transaction may see its own writes that nobody else can yet
+	if model.InTransaction(ctx) {
+		return r.repo.FindByID(ctx, id)
+	}
+
+	if cached, ok := r.users.Get(id); ok && cached.user != nil {
+		userCacheLookups.WithLabelValues("FindByID", "hit").Inc()
+		return cached.user.Clone(), nil
+	}
+	userCacheLookups.WithLabelValues("FindByID", "miss").Inc()
+
+	generation := r.users.Generation(id)
+	user, err := r.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.users.SetIfUnchanged(id, generation, cachedUser{user: user.Clone(), exists: true})
+	return user, nil
+}
+
+func (r *CachedUserRepo) ExistsByID(ctx context.Context, id string) (bool, error) {
+	if model.InTransaction(ctx) {
+		return r.repo.ExistsByID(ctx, id)
+	}
+
+	if cached, ok := r.users.Get(id); ok {
+		userCacheLookups.WithLabelValues("ExistsByID", "hit").Inc()
+		return cached.exists, nil
+	}
+	userCacheLookups.WithLabelValues("ExistsByID", "miss").Inc()
+
+	generation := r.users.Generation(id)
+	exists, err := r.repo.ExistsByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	r.users.SetIfUnchanged(id, generation, cachedUser{exists: exists})
+	return exists, nil
+}
+
+// Invalidate drops a user changed elsewhere from the cache
+func (r *CachedUserRepo) Invalidate(id string) {
+	r.invalidate(id, invalidatedByChangeStream)
+}
+
+// Clear drops every user, when changes may have been missed
+func (r *CachedUserRepo) Clear() {
+	r.users.Clear()
+}
+
+func (r *CachedUserRepo) invalidate(id, source string) {
+	r.users.Delete(id)
+	userCacheInvalidations.WithLabelValues(source).Inc()
+}
+
+// invalidateWritten runs after the write whether it failed or not, a failed write such as a version
+// mismatch may mean the cached user is already stale. In a transaction it runs again after the commit.
+func (r *CachedUserRepo) invalidateWritten(ctx context.Context, id string) {
+	r.invalidate(id, invalidatedByWrite)
+	if model.InTransaction(ctx) {
+		model.AfterCommit(ctx, func() { r.invalidate(id, invalidatedByWrite) })
+	}
+}
+
+func (r *CachedUserRepo) Update(ctx context.Context, user *model.User) error {
+	defer r.invalidateWritten(ctx, user.ID())
+	return r.repo.Update(ctx, user)
+}
+
+func (r *CachedUserRepo) Delete(ctx context.Context, id string) error {
+	defer r.invalidateWritten(ctx, id)
+	return r.repo.Delete(ctx, id)
+}
+
+func (r *CachedUserRepo) Restore(ctx context.Context, id string) error {
+	defer r.invalidateWritten(ctx, id)
+	return r.repo.Restore(ctx, id)
+}
+
+func (r *CachedUserRepo) Purge(ctx context.Context, id string) error {
+	defer r.invalidateWritten(ctx, id)
+	return r.repo.Purge(ctx, id)
+}
+
+func (r *CachedUserRepo) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	defer r.invalidateWritten(ctx, id)
+	return r.repo.RecordLogin(ctx, id, at)
+}
+
+func (r *CachedUserRepo) Count(ctx context.Context) (int64, error) {
+	return r.repo.Count(ctx)
+}
+
+func (r *CachedUserRepo) List(ctx context.Context, opts model.ListUserOpts) (*model.UserPage, error) {
+	return r.repo.List(ctx, opts)
+}
+
+func (r *CachedUserRepo) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	return r.repo.Create(ctx, user)
+}
+
+func (r *CachedUserRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.repo.FindByEmail(ctx, email)
+}
+
+func (r *CachedUserRepo) FindDeletedByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.repo.FindDeletedByEmail(ctx, email)
+}
+
+func (r *CachedUserRepo) ListDeleted(ctx context.Context, before time.Time, limit int) ([]model.User, error) {
+	return r.repo.ListDeleted(ctx, before, limit)
+}
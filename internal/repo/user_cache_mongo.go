@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxWatchBackoff = 30 * time.Second
+
+// userChangePipeline keeps the events that can make a cached user stale
+var userChangePipeline = mongo.Pipeline{
+	{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"update", "replace", "delete"}}}}},
+	{{Key: "$project", Value: bson.M{"documentKey": 1}}},
+}
+
+type userChangeEvent struct {
+	DocumentKey struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+}
+
+// WatchUserChanges drops the users other replicas change from the cache, it needs Mongo to run as a replica set.
+// The stream is reopened when it fails and the cache is cleared, since changes may have been missed meanwhile.
+// It returns once the first stream is open and stop ends it.
+func WatchUserChanges(db *mongo.Database, cache *CachedUserRepo) (stop func() error, err error) {
+	collection := db.Collection(userCollection)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := collection.Watch(ctx, userChangePipeline)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		backoff := time.Second
+		for {
+			watchUserChanges(ctx, stream, cache)
+			if ctx.Err() != nil {
+				return
+			}
+
+			logx.Warn().Err(stream.Err()).Msg("user change stream failed, clearing the user cache")
+			cache.Clear()
+
+			opts := options.ChangeStream()
+			if token := stream.ResumeToken(); token != nil {
+				opts.SetStartAfter(token)
+			}
+			_ = stream.Close(context.Background())
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+
+				stream, err = collection.Watch(ctx, userChangePipeline, opts)
+				if err == nil {
+					backoff = time.Second
+					break
+				}
+
+				logx.Warn().Err(err).Dur("retry_in", backoff).Msg("failed to reopen the user change stream")
+				backoff = min(backoff*2, maxWatchBackoff)
+				// the token may have left the oplog, start from now instead
+				opts = options.ChangeStream()
+			}
+		}
+	}()
+
+	return func() error {
+		cancel()
+		<-done
+		err := stream.Close(context.Background())
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}, nil
+}
+
+// watchUserChanges invalidates the changed users until the stream fails or ctx is done
+func watchUserChanges(ctx context.Context, stream *mongo.ChangeStream, cache *CachedUserRepo) {
+	for stream.Next(ctx) {
+		var event userChangeEvent
+		if err := stream.Decode(&event); err != nil {
+			logx.Warn().Err(err).Msg("failed to decode user change event, clearing the user cache")
+			cache.Clear()
+			continue
+		}
+
+		cache.Invalidate(event.DocumentKey.ID.Hex())
+	}
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedUserRepo(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) model.UserRepo {
+		return NewCachedUserRepo(CachedUserRepoOpts{Repo: NewMemoryUserRepo(), Size: 100, TTL: time.Minute})
+	})
+}
+
+func TestCachedUserRepo_Caching(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryUserRepo()
+	r := NewCachedUserRepo(CachedUserRepoOpts{Repo: inner, Size: 100, TTL: time.Minute})
+
+	user, err := model.NewUser(model.UserOpts{
+		Name:           "John Doe",
+		Email:          "john@example.com",
+		HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		CreatedAt:      time.Now(),
+	})
+	require.NoError(t, err)
+	created, err := r.Create(ctx, user)
+	require.NoError(t, err)
+
+	// rename the user behind the cache's back
+	rename := func(name string) {
+		t.Helper()
+		u, err := inner.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.NoError(t, u.Update(model.UpdateUserOpts{ID: u.ID(), Name: lo.ToPtr(name)}))
+		require.NoError(t, inner.Update(ctx, u))
+	}
+
+	t.Run("FindByID is served from the cache", func(t *testing.T) {
+		found, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.Equal(t, "John Doe", found.Name())
+
+		rename("Johnny")
+		found, err = r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.Equal(t, "John Doe", found.Name())
+	})
+
+	t.Run("callers do not share the cached user", func(t *testing.T) {
+		found, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.NoError(t, found.Update(model.UpdateUserOpts{ID: found.ID(), Name: lo.ToPtr("Changed")}))
+
+		again, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.Equal(t, "John Doe", again.Name())
+		require.Empty(t, again.Changes())
+	})
+
+	t.Run("writes invalidate the user", func(t *testing.T) {
+		require.NoError(t, r.RecordLogin(ctx, created.ID(), time.Now()))
+
+		found, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.Equal(t, "Johnny", found.Name())
+	})
+
+	t.Run("ExistsByID is cached until the user is deleted", func(t *testing.T) {
+		exists, err := r.ExistsByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		require.NoError(t, r.Delete(ctx, created.ID()))
+		exists, err = r.ExistsByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		require.NoError(t, r.Restore(ctx, created.ID()))
+		exists, err = r.ExistsByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.True(t, exists)
+	})
+
+	t.Run("Invalidate drops a user changed by another replica", func(t *testing.T) {
+		_, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+
+		rename("Jack")
+		r.Invalidate(created.ID())
+		found, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.Equal(t, "Jack", found.Name())
+	})
+
+	t.Run("transactions bypass the cache and invalidate it once committed", func(t *testing.T) {
+		_, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+
+		err = NewMemoryUnitOfWork().WithTransaction(ctx, func(txCtx context.Context) error {
+			rename("Jill")
+			found, err := r.FindByID(txCtx, created.ID())
+			require.NoError(t, err)
+			require.Equal(t, "Jill", found.Name())
+
+			require.NoError(t, r.RecordLogin(txCtx, created.ID(), time.Now()))
+
+			// a reader outside the transaction caches the user before the commit
+			_, err = r.FindByID(ctx, created.ID())
+			require.NoError(t, err)
+			rename("Jim")
+			return nil
+		})
+		require.NoError(t, err)
+
+		found, err := r.FindByID(ctx, created.ID())
+		require.NoError(t, err)
+		require.Equal(t, "Jim", found.Name())
+	})
+}
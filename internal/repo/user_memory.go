@@ -0,0 +1,510 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errNotFound is the error of the Mongo repositories for a missing document, so callers cannot tell the stores apart
+var errNotFound = errx.Mongo(mongo.ErrNoDocuments)
+
+// MemoryUserRepo keeps users in process with the same behavior as UserRepo, for tests and local development.
+// Users are lost on restart and are not shared between replicas.
+type MemoryUserRepo struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]userMongo
+}
+
+func NewMemoryUserRepo() *MemoryUserRepo {
+	return &MemoryUserRepo{
+		users: make(map[primitive.ObjectID]userMongo),
+	}
+}
+
+var _ model.UserRepo = (*MemoryUserRepo)(nil)
+
+// storedTime rounds t the way Mongo stores dates, to the millisecond in UTC
+func storedTime(t time.Time) time.Time {
+	return t.Truncate(time.Millisecond).UTC()
+}
+
+// clone copies u so the stored user never shares its map, slice or pointers with callers
+func (u userMongo) clone() userMongo {
+	u.Metadata = maps.Clone(u.Metadata)
+	u.StatusHistory = slices.Clone(u.StatusHistory)
+	if u.DeletedAt != nil {
+		u.DeletedAt = lo.ToPtr(*u.DeletedAt)
+	}
+	if u.LastLoginAt != nil {
+		u.LastLoginAt = lo.ToPtr(*u.LastLoginAt)
+	}
+	return u
+}
+
+// stored returns the copy of u that is kept, with dates rounded like Mongo does
+func (u userMongo) stored() userMongo {
+	u = u.clone()
+	u.CreatedAt = storedTime(u.CreatedAt)
+	for i := range u.StatusHistory {
+		u.StatusHistory[i].ChangedAt = storedTime(u.StatusHistory[i].ChangedAt)
+	}
+	return u
+}
+
+// put stores u, inside a MemoryUnitOfWork transaction the previous user is put back on rollback.
+// The caller holds the write lock.
+func (r *MemoryUserRepo) put(ctx context.Context, id primitive.ObjectID, u userMongo) {
+	r.remember(ctx, id)
+	r.users[id] = u
+}
+
+func (r *MemoryUserRepo) remove(ctx context.Context, id primitive.ObjectID) {
+	r.remember(ctx, id)
+	delete(r.users, id)
+}
+
+func (r *MemoryUserRepo) remember(ctx context.Context, id primitive.ObjectID) {
+	previous, existed := r.users[id]
+	previous = previous.clone()
+	onRollback(ctx, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if existed {
+			r.users[id] = previous
+		} else {
+			delete(r.users, id)
+		}
+	})
+}
+
+func (r *MemoryUserRepo) Count(_ context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, u := range r.users {
+		if u.DeletedAt == nil {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *MemoryUserRepo) List(_ context.Context, opts model.ListUserOpts) (*model.UserPage, error) {
+	sort, sortAsc := opts.GetSort()
+	backward := opts.Cursor != nil && opts.Cursor.Backward
+	// a backward page is read in reverse order starting from the cursor, then flipped
+	ascending := sortAsc != backward
+
+	var position *userMongo
+	if opts.Cursor != nil {
+		var err error
+		if position, err = userCursorPosition(opts.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]userMongo, 0, len(r.users))
+	for _, u := range r.users {
+		if !matchesListUserOpts(&u, opts) {
+			continue
+		}
+		if position != nil && !isAfter(&u, position, sort, ascending) {
+			continue
+		}
+		users = append(users, u.clone())
+	}
+
+	slices.SortFunc(users, func(a, b userMongo) int {
+		if ascending {
+			return compareUsers(&a, &b, sort)
+		}
+		return compareUsers(&b, &a, sort)
+	})
+
+	// keep one extra user to know whether there is another page
+	page, err := toUserModels(users[:min(len(users), opts.GetLimit()+1)])
+	if err != nil {
+		return nil, err
+	}
+
+	return newUserPage(page, opts), nil
+}
+
+// userCursorPosition returns a user that sorts exactly at the cursor position
+func userCursorPosition(c *model.UserCursor) (*userMongo, error) {
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, ErrInvalidUserID(err)
+	}
+
+	position := &userMongo{ID: id}
+	switch c.Sort {
+	case model.UserSortName:
+		position.Name = c.Value
+	case model.UserSortEmail:
+		position.Email = c.Value
+	default:
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		position.CreatedAt = storedTime(createdAt)
+	}
+
+	return position, nil
+}
+
+// compareUsers orders users by the sort field, ties are broken by id
+func compareUsers(a, b *userMongo, sort model.UserSortField) int {
+	var order int
+	switch sort {
+	case model.UserSortName:
+		order = strings.Compare(a.Name, b.Name)
+	case model.UserSortEmail:
+		order = strings.Compare(a.Email, b.Email)
+	default:
+		order = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	if order != 0 {
+		return order
+	}
+	return strings.Compare(a.ID.Hex(), b.ID.Hex())
+}
+
+// isAfter reports whether u comes strictly after the position when reading in the given order
+func isAfter(u, position *userMongo, sort model.UserSortField, ascending bool) bool {
+	order := compareUsers(u, position, sort)
+	if ascending {
+		return order > 0
+	}
+	return order < 0
+}
+
+// matchesListUserOpts applies the same filters as listUserFilters
+func matchesListUserOpts(u *userMongo, opts model.ListUserOpts) bool {
+	if u.DeletedAt != nil {
+		return false
+	}
+	if !strings.HasPrefix(u.Name, opts.NamePrefix) || !strings.HasPrefix(u.Email, opts.EmailPrefix) {
+		return false
+	}
+	if opts.Search != "" {
+		search := strings.ToLower(opts.Search)
+		if !strings.Contains(strings.ToLower(u.Name), search) && !strings.Contains(strings.ToLower(u.Email), search) {
+			return false
+		}
+	}
+	if !opts.CreatedFrom.IsZero() && u.CreatedAt.Before(storedTime(opts.CreatedFrom)) {
+		return false
+	}
+	if !opts.CreatedTo.IsZero() && u.CreatedAt.After(storedTime(opts.CreatedTo)) {
+		return false
+	}
+	if opts.Role != "" && cmp.Or(u.Role, model.UserRoleUser) != opts.Role {
+		return false
+	}
+	if opts.Status != "" && cmp.Or(u.Status, model.UserStatusActive) != opts.Status {
+		return false
+	}
+	return true
+}
+
+func (r *MemoryUserRepo) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the canonical email is unique among deleted users too, like the unique index
+	if r.emailTaken(user.EmailCanonical(), primitive.NilObjectID) {
+		return nil, model.ErrEmailTaken
+	}
+
+	u := newUserMongo(user)
+	u.ID = primitive.NewObjectID()
+	r.put(ctx, u.ID, u.stored())
+
+	return u.toModel()
+}
+
+// emailTaken reports whether a user other than except already uses the canonical email
+func (r *MemoryUserRepo) emailTaken(canonical string, except primitive.ObjectID) bool {
+	for id, u := range r.users {
+		if id != except && u.EmailCanonical == canonical {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns a copy of the user with the given id that is deleted or not
+func (r *MemoryUserRepo) find(id string, deleted bool) (*userMongo, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidUserID(err)
+	}
+
+	u, ok := r.users[objID]
+	if !ok || (u.DeletedAt != nil) != deleted {
+		return nil, errNotFound
+	}
+
+	u = u.clone()
+	return &u, nil
+}
+
+// findByEmail returns a copy of the user with the canonical form of email that is deleted or not
+func (r *MemoryUserRepo) findByEmail(email string, deleted bool) (*userMongo, error) {
+	canonical := model.CanonicalEmail(email)
+	for _, u := range r.users {
+		if u.EmailCanonical == canonical && (u.DeletedAt != nil) == deleted {
+			u = u.clone()
+			return &u, nil
+		}
+	}
+
+	return nil, errNotFound
+}
+
+func (r *MemoryUserRepo) FindByID(_ context.Context, id string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, err := r.find(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.toModel()
+}
+
+func (r *MemoryUserRepo) ExistsByID(_ context.Context, id string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, err := r.find(id, false)
+	if errors.Is(err, errNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *MemoryUserRepo) FindByEmail(_ context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, err := r.findByEmail(email, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.toModel()
+}
+
+func (r *MemoryUserRepo) Update(ctx context.Context, user *model.User) error {
+	objID, err := primitive.ObjectIDFromHex(user.ID())
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
+	changes := user.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[objID]
+	if !ok || stored.DeletedAt != nil {
+		return errNotFound
+	}
+	// documents written before versions existed start at version 1
+	if cmp.Or(stored.Version, 1) != user.Version() {
+		return model.ErrUserVersionMismatch
+	}
+
+	u := stored.clone()
+	for _, field := range changes {
+		if err := setUserField(&u, user, field); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(changes, model.UserFieldEmail) {
+		u.EmailCanonical = user.EmailCanonical()
+		if r.emailTaken(u.EmailCanonical, objID) {
+			return model.ErrEmailTaken
+		}
+	}
+
+	u.Version = user.Version() + 1
+	r.put(ctx, objID, u.stored())
+
+	user.MarkSaved(user.Version() + 1)
+	return nil
+}
+
+// setUserField copies a changed field of the user, it mirrors userFieldValue
+func setUserField(u *userMongo, user *model.User, field model.UserField) error {
+	switch field {
+	case model.UserFieldName:
+		u.Name = user.Name()
+	case model.UserFieldEmail:
+		u.Email = user.Email()
+	case model.UserFieldAvatarURL:
+		u.AvatarURL = user.AvatarURL()
+	case model.UserFieldBio:
+		u.Bio = user.Bio()
+	case model.UserFieldLocale:
+		u.Locale = user.Locale()
+	case model.UserFieldTimezone:
+		u.Timezone = user.Timezone()
+	case model.UserFieldPhone:
+		u.Phone = user.Phone()
+	case model.UserFieldMetadata:
+		// an empty map is removed from the document
+		u.Metadata = nil
+		if len(user.Metadata()) > 0 {
+			u.Metadata = user.Metadata()
+		}
+	case model.UserFieldStatus:
+		u.Status = user.Status()
+	case model.UserFieldStatusHistory:
+		u.StatusHistory = newStatusHistoryMongo(user.StatusHistory())
+	case model.UserFieldPendingEmail:
+		u.PendingEmail = user.PendingEmail()
+	default:
+		_, _, err := userFieldValue(user, field)
+		return err
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepo) Delete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// like the Mongo update, deleting a missing or deleted user does nothing
+	if u, ok := r.users[objID]; ok && u.DeletedAt == nil {
+		u.DeletedAt = lo.ToPtr(storedTime(time.Now()))
+		r.put(ctx, objID, u)
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepo) FindDeletedByEmail(_ context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, err := r.findByEmail(email, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.toModel()
+}
+
+func (r *MemoryUserRepo) Restore(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, err := r.find(id, true)
+	if err != nil {
+		return err
+	}
+
+	u.DeletedAt = nil
+	r.put(ctx, u.ID, *u)
+	return nil
+}
+
+func (r *MemoryUserRepo) ListDeleted(_ context.Context, before time.Time, limit int) ([]model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	before = storedTime(before)
+	deleted := make([]userMongo, 0)
+	for _, u := range r.users {
+		if u.DeletedAt != nil && u.DeletedAt.Before(before) {
+			deleted = append(deleted, u.clone())
+		}
+	}
+
+	slices.SortFunc(deleted, func(a, b userMongo) int {
+		return cmp.Or(a.DeletedAt.Compare(*b.DeletedAt), strings.Compare(a.ID.Hex(), b.ID.Hex()))
+	})
+	// a zero limit lists every user, like a Mongo find without a limit
+	if limit > 0 {
+		deleted = deleted[:min(len(deleted), limit)]
+	}
+
+	return toUserModels(deleted)
+}
+
+func (r *MemoryUserRepo) Purge(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.users[objID]; ok && u.DeletedAt != nil {
+		r.remove(ctx, objID)
+	}
+
+	return nil
+}
+
+func (r *MemoryUserRepo) RecordLogin(ctx context.Context, id string, at time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	u, ok := r.users[objID]
+	if !ok || u.DeletedAt != nil {
+		return nil
+	}
+
+	at = storedTime(at)
+	if u.LastLoginAt == nil || at.After(*u.LastLoginAt) {
+		u.LastLoginAt = &at
+		r.put(ctx, objID, u)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+)
+
+func TestMemoryUserRepo(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) model.UserRepo {
+		return NewMemoryUserRepo()
+	})
+}
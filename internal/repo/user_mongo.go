@@ -2,11 +2,15 @@ package repo
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/chai-rs/sevenhunter/internal/model"
 	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/samber/lo"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -18,11 +22,67 @@ var (
 )
 
 type userMongo struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty"`
-	Name           string             `bson:"name"`
-	Email          string             `bson:"email"`
-	HashedPassword string             `bson:"hashed_password"`
-	CreatedAt      time.Time          `bson:"created_at"`
+	ID             primitive.ObjectID  `bson:"_id,omitempty"`
+	Name           string              `bson:"name"`
+	Email          string              `bson:"email"`
+	EmailCanonical string              `bson:"email_canonical"`
+	HashedPassword string              `bson:"hashed_password"`
+	Role           model.UserRole      `bson:"role,omitempty"`
+	Status         model.UserStatus    `bson:"status,omitempty"`
+	CreatedAt      time.Time           `bson:"created_at"`
+	DeletedAt      *time.Time          `bson:"deleted_at,omitempty"`
+	LastLoginAt    *time.Time          `bson:"last_login_at,omitempty"`
+	Version        int64               `bson:"version,omitempty"`
+	AvatarURL      string              `bson:"avatar_url,omitempty"`
+	Bio            string              `bson:"bio,omitempty"`
+	Locale         string              `bson:"locale,omitempty"`
+	Timezone       string              `bson:"timezone,omitempty"`
+	Phone          string              `bson:"phone,omitempty"`
+	Metadata       map[string]string   `bson:"metadata,omitempty"`
+	PendingEmail   string              `bson:"pending_email,omitempty"`
+	StatusHistory  []statusChangeMongo `bson:"status_history,omitempty"`
+}
+
+type statusChangeMongo struct {
+	From      model.UserStatus `bson:"from"`
+	To        model.UserStatus `bson:"to"`
+	Reason    string           `bson:"reason"`
+	ChangedBy string           `bson:"changed_by"`
+	ChangedAt time.Time        `bson:"changed_at"`
+}
+
+func newStatusHistoryMongo(history []model.UserStatusChange) []statusChangeMongo {
+	return lo.Map(history, func(item model.UserStatusChange, _ int) statusChangeMongo {
+		return statusChangeMongo(item)
+	})
+}
+
+func (u *userMongo) statusHistory() []model.UserStatusChange {
+	return lo.Map(u.StatusHistory, func(item statusChangeMongo, _ int) model.UserStatusChange {
+		return model.UserStatusChange(item)
+	})
+}
+
+// newUserMongo maps a new user to its document, the id is assigned when it is inserted
+func newUserMongo(user *model.User) userMongo {
+	return userMongo{
+		Name:           user.Name(),
+		Email:          user.Email(),
+		EmailCanonical: user.EmailCanonical(),
+		HashedPassword: user.HashedPassword(),
+		Role:           user.Role(),
+		Status:         user.Status(),
+		CreatedAt:      user.CreatedAt(),
+		Version:        user.Version(),
+		AvatarURL:      user.AvatarURL(),
+		Bio:            user.Bio(),
+		Locale:         user.Locale(),
+		Timezone:       user.Timezone(),
+		Phone:          user.Phone(),
+		Metadata:       user.Metadata(),
+		PendingEmail:   user.PendingEmail(),
+		StatusHistory:  newStatusHistoryMongo(user.StatusHistory()),
+	}
 }
 
 func (u *userMongo) toModel() (*model.User, error) {
@@ -31,25 +91,49 @@ func (u *userMongo) toModel() (*model.User, error) {
 		Name:           u.Name,
 		Email:          u.Email,
 		HashedPassword: u.HashedPassword,
+		Role:           u.Role,
+		Status:         u.Status,
 		CreatedAt:      u.CreatedAt,
+		DeletedAt:      lo.FromPtr(u.DeletedAt),
+		LastLoginAt:    lo.FromPtr(u.LastLoginAt),
+		Version:        u.Version,
+		AvatarURL:      u.AvatarURL,
+		Bio:            u.Bio,
+		Locale:         u.Locale,
+		Timezone:       u.Timezone,
+		Phone:          u.Phone,
+		Metadata:       u.Metadata,
+		PendingEmail:   u.PendingEmail,
+		StatusHistory:  u.statusHistory(),
 	})
 }
 
+const userCollection = "users"
+
+// notDeleted matches the users that have not been deleted, a missing deleted_at also matches null
+var notDeleted = bson.M{"deleted_at": nil}
+
+func deletedBefore(t time.Time) bson.M {
+	return bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": t}}
+}
+
 type UserRepo struct {
 	collection *mongo.Collection
 }
 
 func NewUserRepo(db *mongo.Database) *UserRepo {
-	collectionName := "users"
 	return &UserRepo{
-		collection: db.Collection(collectionName),
+		collection: db.Collection(userCollection),
 	}
 }
 
 var _ model.UserRepo = (*UserRepo)(nil)
 
-func (r *UserRepo) Count(ctx context.Context) (int64, error) {
-	count, err := r.collection.CountDocuments(ctx, bson.M{})
+func (r *UserRepo) Count(ctx context.Context) (_ int64, err error) {
+	ctx, end := instrument(ctx, userCollection, "Count")
+	defer end(&err)
+
+	count, err := r.collection.CountDocuments(ctx, notDeleted)
 	if err != nil {
 		return 0, errx.Mongo(err)
 	}
@@ -57,30 +141,37 @@ func (r *UserRepo) Count(ctx context.Context) (int64, error) {
 	return count, nil
 }
 
-func (r *UserRepo) List(ctx context.Context, opts model.ListUserOpts) ([]model.User, error) {
-	filter := bson.M{}
-	if opts.Cursor != "" {
-		cursorID, err := primitive.ObjectIDFromHex(opts.Cursor)
-		if err != nil {
-			return nil, ErrInvalidUserID(err)
-		}
+func (r *UserRepo) List(ctx context.Context, opts model.ListUserOpts) (_ *model.UserPage, err error) {
+	ctx, end := instrument(ctx, userCollection, "List")
+	defer end(&err)
 
-		if opts.SortAsc {
-			filter["_id"] = bson.M{"$gt": cursorID}
-		} else {
-			filter["_id"] = bson.M{"$lt": cursorID}
+	sort, sortAsc := opts.GetSort()
+	backward := opts.Cursor != nil && opts.Cursor.Backward
+	// a backward page is read in reverse order starting from the cursor, then flipped
+	ascending := sortAsc != backward
+
+	filters := listUserFilters(opts)
+	if opts.Cursor != nil {
+		after, err := userCursorFilter(opts.Cursor, ascending)
+		if err != nil {
+			return nil, err
 		}
+		filters = append(filters, after)
 	}
 
-	findOpts := options.Find()
-	findOpts.SetLimit(int64(opts.GetLimit()))
+	filter := bson.M{"$and": filters}
 
-	if opts.SortAsc {
-		findOpts.SetSort(bson.D{{Key: "_id", Value: 1}})
-	} else {
-		findOpts.SetSort(bson.D{{Key: "_id", Value: -1}})
+	direction := -1
+	if ascending {
+		direction = 1
 	}
 
+	// fetch one extra document to know whether there is another page
+	limit := opts.GetLimit()
+	findOpts := options.Find()
+	findOpts.SetLimit(int64(limit + 1))
+	findOpts.SetSort(bson.D{{Key: string(sort), Value: direction}, {Key: "_id", Value: direction}})
+
 	cursor, err := r.collection.Find(ctx, filter, findOpts)
 	if err != nil {
 		return nil, errx.Mongo(err)
@@ -92,6 +183,15 @@ func (r *UserRepo) List(ctx context.Context, opts model.ListUserOpts) ([]model.U
 		return nil, errx.Mongo(err)
 	}
 
+	users, err := toUserModels(mongoUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUserPage(users, opts), nil
+}
+
+func toUserModels(mongoUsers []userMongo) ([]model.User, error) {
 	users := make([]model.User, 0, len(mongoUsers))
 	for _, mu := range mongoUsers {
 		user, err := mu.toModel()
@@ -104,15 +204,122 @@ func (r *UserRepo) List(ctx context.Context, opts model.ListUserOpts) ([]model.U
 	return users, nil
 }
 
-func (r *UserRepo) Create(ctx context.Context, user *model.User) (*model.User, error) {
-	u := userMongo{
-		Name:           user.Name(),
-		Email:          user.Email(),
-		HashedPassword: user.HashedPassword(),
-		CreatedAt:      user.CreatedAt(),
+// newUserPage builds the page from up to limit+1 users read in listing order, backward pages are read in reverse
+func newUserPage(users []model.User, opts model.ListUserOpts) *model.UserPage {
+	sort, sortAsc := opts.GetSort()
+	backward := opts.Cursor != nil && opts.Cursor.Backward
+	limit := opts.GetLimit()
+
+	more := len(users) > limit
+	if more {
+		users = users[:limit]
+	}
+	if backward {
+		slices.Reverse(users)
+	}
+
+	page := &model.UserPage{Users: users}
+	if len(page.Users) == 0 {
+		return page
+	}
+
+	first, last := &page.Users[0], &page.Users[len(page.Users)-1]
+	// coming back from a later page means there is always a next one, and coming from an
+	// earlier page means there is always a previous one
+	page.HasMore = more || backward
+	if page.HasMore {
+		page.Next = model.NewUserCursor(last, sort, sortAsc, false)
+	}
+	if opts.Cursor != nil && (more || !backward) {
+		page.Prev = model.NewUserCursor(first, sort, sortAsc, true)
+	}
+
+	return page
+}
+
+// userCursorFilter matches the users strictly after the cursor position when reading in the given order
+func userCursorFilter(c *model.UserCursor, ascending bool) (bson.M, error) {
+	id, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, ErrInvalidUserID(err)
 	}
 
+	var value any = c.Value
+	if c.Sort == model.UserSortCreatedAt {
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		value = createdAt
+	}
+
+	op := "$lt"
+	if ascending {
+		op = "$gt"
+	}
+
+	field := string(c.Sort)
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, "_id": bson.M{op: id}},
+	}}, nil
+}
+
+func listUserFilters(opts model.ListUserOpts) []bson.M {
+	filters := []bson.M{notDeleted}
+	if opts.NamePrefix != "" {
+		filters = append(filters, bson.M{"name": bson.M{"$regex": "^" + regexp.QuoteMeta(opts.NamePrefix)}})
+	}
+	if opts.EmailPrefix != "" {
+		filters = append(filters, bson.M{"email": bson.M{"$regex": "^" + regexp.QuoteMeta(opts.EmailPrefix)}})
+	}
+	if opts.Search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(opts.Search), Options: "i"}
+		filters = append(filters, bson.M{"$or": bson.A{
+			bson.M{"name": pattern},
+			bson.M{"email": pattern},
+		}})
+	}
+	if !opts.CreatedFrom.IsZero() {
+		filters = append(filters, bson.M{"created_at": bson.M{"$gte": opts.CreatedFrom}})
+	}
+	if !opts.CreatedTo.IsZero() {
+		filters = append(filters, bson.M{"created_at": bson.M{"$lte": opts.CreatedTo}})
+	}
+	if opts.Role != "" {
+		filters = append(filters, roleFilter(opts.Role))
+	}
+	if opts.Status != "" {
+		filters = append(filters, statusFilter(opts.Status))
+	}
+	return filters
+}
+
+// roleFilter also matches documents written before roles existed, which default to UserRoleUser
+func roleFilter(role model.UserRole) bson.M {
+	if role == model.UserRoleUser {
+		return bson.M{"role": bson.M{"$in": bson.A{role, nil}}}
+	}
+	return bson.M{"role": role}
+}
+
+// statusFilter also matches documents written before statuses existed, which default to UserStatusActive
+func statusFilter(status model.UserStatus) bson.M {
+	if status == model.UserStatusActive {
+		return bson.M{"status": bson.M{"$in": bson.A{status, nil}}}
+	}
+	return bson.M{"status": status}
+}
+
+func (r *UserRepo) Create(ctx context.Context, user *model.User) (_ *model.User, err error) {
+	ctx, end := instrument(ctx, userCollection, "Create")
+	defer end(&err)
+
+	u := newUserMongo(user)
 	result, err := r.collection.InsertOne(ctx, u)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, model.ErrEmailTaken
+	}
 	if err != nil {
 		return nil, errx.Mongo(err)
 	}
@@ -121,7 +328,10 @@ func (r *UserRepo) Create(ctx context.Context, user *model.User) (*model.User, e
 	return u.toModel()
 }
 
-func (r *UserRepo) FindByID(ctx context.Context, id string) (*model.User, error) {
+func (r *UserRepo) FindByID(ctx context.Context, id string) (_ *model.User, err error) {
+	ctx, end := instrument(ctx, userCollection, "FindByID")
+	defer end(&err)
+
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, ErrInvalidUserID(err)
@@ -130,7 +340,8 @@ func (r *UserRepo) FindByID(ctx context.Context, id string) (*model.User, error)
 	var (
 		u      userMongo
 		filter = bson.M{
-			"_id": objID,
+			"_id":        objID,
+			"deleted_at": nil,
 		}
 	)
 
@@ -142,14 +353,18 @@ func (r *UserRepo) FindByID(ctx context.Context, id string) (*model.User, error)
 	return u.toModel()
 }
 
-func (r *UserRepo) ExistsByID(ctx context.Context, id string) (bool, error) {
+func (r *UserRepo) ExistsByID(ctx context.Context, id string) (_ bool, err error) {
+	ctx, end := instrument(ctx, userCollection, "ExistsByID")
+	defer end(&err)
+
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return false, ErrInvalidUserID(err)
 	}
 
 	filter := bson.M{
-		"_id": objID,
+		"_id":        objID,
+		"deleted_at": nil,
 	}
 
 	count, err := r.collection.CountDocuments(ctx, filter)
@@ -160,14 +375,18 @@ func (r *UserRepo) ExistsByID(ctx context.Context, id string) (bool, error) {
 	return count > 0, nil
 }
 
-func (r *UserRepo) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+func (r *UserRepo) FindByEmail(ctx context.Context, email string) (_ *model.User, err error) {
+	ctx, end := instrument(ctx, userCollection, "FindByEmail")
+	defer end(&err)
+
 	var (
 		u      userMongo
 		filter = bson.M{
-			"email": email,
+			"email_canonical": model.CanonicalEmail(email),
+			"deleted_at":      nil,
 		}
 	)
-	err := r.collection.FindOne(ctx, filter).Decode(&u)
+	err = r.collection.FindOne(ctx, filter).Decode(&u)
 	if err != nil {
 		return nil, errx.Mongo(err)
 	}
@@ -175,40 +394,237 @@ func (r *UserRepo) FindByEmail(ctx context.Context, email string) (*model.User,
 	return u.toModel()
 }
 
-func (r *UserRepo) Update(ctx context.Context, user *model.User) error {
+func (r *UserRepo) Update(ctx context.Context, user *model.User) (err error) {
+	ctx, end := instrument(ctx, userCollection, "Update")
+	defer end(&err)
+
 	objID, err := primitive.ObjectIDFromHex(user.ID())
 	if err != nil {
 		return ErrInvalidUserID(err)
 	}
 
+	changes := user.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	set := bson.M{
+		"version": user.Version() + 1,
+	}
+	unset := bson.M{}
+	for _, field := range changes {
+		key, value, err := userFieldValue(user, field)
+		if err != nil {
+			return err
+		}
+
+		// cleared optional fields are removed like they are left out on create
+		if isEmptyField(value) {
+			unset[key] = ""
+			continue
+		}
+		set[key] = value
+	}
+	if slices.Contains(changes, model.UserFieldEmail) {
+		set["email_canonical"] = user.EmailCanonical()
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	filter := bson.M{
+		"_id":        objID,
+		"deleted_at": nil,
+		"version":    versionFilter(user.Version()),
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if mongo.IsDuplicateKeyError(err) && slices.Contains(changes, model.UserFieldEmail) {
+		return model.ErrEmailTaken
+	}
+	if err != nil {
+		return errx.Mongo(err)
+	}
+
+	if result.MatchedCount == 0 {
+		// tell a concurrent update apart from a user that is gone
+		exist, err := r.ExistsByID(ctx, user.ID())
+		if err != nil {
+			return err
+		}
+		if exist {
+			return model.ErrUserVersionMismatch
+		}
+		return errx.Mongo(mongo.ErrNoDocuments)
+	}
+
+	user.MarkSaved(user.Version() + 1)
+	return nil
+}
+
+func isEmptyField(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case map[string]string:
+		return len(v) == 0
+	}
+	return false
+}
+
+// userFieldValue maps a changed field to its document key and value
+func userFieldValue(user *model.User, field model.UserField) (string, any, error) {
+	switch field {
+	case model.UserFieldName:
+		return "name", user.Name(), nil
+	case model.UserFieldEmail:
+		return "email", user.Email(), nil
+	case model.UserFieldAvatarURL:
+		return "avatar_url", user.AvatarURL(), nil
+	case model.UserFieldBio:
+		return "bio", user.Bio(), nil
+	case model.UserFieldLocale:
+		return "locale", user.Locale(), nil
+	case model.UserFieldTimezone:
+		return "timezone", user.Timezone(), nil
+	case model.UserFieldPhone:
+		return "phone", user.Phone(), nil
+	case model.UserFieldMetadata:
+		return "metadata", user.Metadata(), nil
+	case model.UserFieldStatus:
+		return "status", user.Status(), nil
+	case model.UserFieldStatusHistory:
+		return "status_history", newStatusHistoryMongo(user.StatusHistory()), nil
+	case model.UserFieldPendingEmail:
+		return "pending_email", user.PendingEmail(), nil
+	}
+
+	return "", nil, errx.E(http.StatusInternalServerError, fmt.Errorf("unmapped user field %q", field))
+}
+
+// versionFilter also matches documents written before versions existed, which start at version 1
+func versionFilter(version int64) any {
+	if version == 1 {
+		return bson.M{"$in": bson.A{version, nil}}
+	}
+	return version
+}
+
+func (r *UserRepo) Delete(ctx context.Context, id string) (err error) {
+	ctx, end := instrument(ctx, userCollection, "Delete")
+	defer end(&err)
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
+	filter := bson.M{
+		"_id":        objID,
+		"deleted_at": nil,
+	}
+
 	update := bson.M{
 		"$set": bson.M{
-			"name":            user.Name(),
-			"email":           user.Email(),
-			"hashed_password": user.HashedPassword(),
+			"deleted_at": time.Now(),
 		},
 	}
 
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errx.Mongo(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepo) FindDeletedByEmail(ctx context.Context, email string) (_ *model.User, err error) {
+	ctx, end := instrument(ctx, userCollection, "FindDeletedByEmail")
+	defer end(&err)
+
+	var (
+		u      userMongo
+		filter = bson.M{
+			"email_canonical": model.CanonicalEmail(email),
+			"deleted_at":      bson.M{"$ne": nil},
+		}
+	)
+	err = r.collection.FindOne(ctx, filter).Decode(&u)
+	if err != nil {
+		return nil, errx.Mongo(err)
+	}
+
+	return u.toModel()
+}
+
+func (r *UserRepo) Restore(ctx context.Context, id string) (err error) {
+	ctx, end := instrument(ctx, userCollection, "Restore")
+	defer end(&err)
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
 	filter := bson.M{
-		"_id": objID,
+		"_id":        objID,
+		"deleted_at": bson.M{"$ne": nil},
 	}
 
-	_, err = r.collection.UpdateOne(ctx, filter, update)
+	update := bson.M{
+		"$unset": bson.M{
+			"deleted_at": "",
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return errx.Mongo(err)
 	}
 
+	if result.MatchedCount == 0 {
+		return errx.Mongo(mongo.ErrNoDocuments)
+	}
+
 	return nil
 }
 
-func (r *UserRepo) Delete(ctx context.Context, id string) error {
+func (r *UserRepo) ListDeleted(ctx context.Context, before time.Time, limit int) (_ []model.User, err error) {
+	ctx, end := instrument(ctx, userCollection, "ListDeleted")
+	defer end(&err)
+
+	findOpts := options.Find().
+		SetSort(bson.D{{Key: "deleted_at", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, deletedBefore(before), findOpts)
+	if err != nil {
+		return nil, errx.Mongo(err)
+	}
+	defer cursor.Close(ctx)
+
+	var mongoUsers []userMongo
+	if err := cursor.All(ctx, &mongoUsers); err != nil {
+		return nil, errx.Mongo(err)
+	}
+
+	return toUserModels(mongoUsers)
+}
+
+func (r *UserRepo) Purge(ctx context.Context, id string) (err error) {
+	ctx, end := instrument(ctx, userCollection, "Purge")
+	defer end(&err)
+
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return ErrInvalidUserID(err)
 	}
 
 	filter := bson.M{
-		"_id": objID,
+		"_id":        objID,
+		"deleted_at": bson.M{"$ne": nil},
 	}
 
 	_, err = r.collection.DeleteOne(ctx, filter)
@@ -218,3 +634,30 @@ func (r *UserRepo) Delete(ctx context.Context, id string) error {
 
 	return nil
 }
+
+func (r *UserRepo) RecordLogin(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, end := instrument(ctx, userCollection, "RecordLogin")
+	defer end(&err)
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidUserID(err)
+	}
+
+	filter := bson.M{
+		"_id":        objID,
+		"deleted_at": nil,
+	}
+
+	// $max keeps the latest time when concurrent logins finish out of order
+	update := bson.M{
+		"$max": bson.M{"last_login_at": at},
+	}
+
+	_, err = r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return errx.Mongo(err)
+	}
+
+	return nil
+}
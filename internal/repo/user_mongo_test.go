@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestMongoDatabase returns an empty database that is dropped after the test,
+// the test is skipped unless MONGO_TEST_URI points at a server that can be written to
+func newTestMongoDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	require.NoError(t, client.Ping(ctx, nil))
+
+	db := client.Database("sevenhunter_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return db
+}
+
+func TestUserRepo(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) model.UserRepo {
+		db := newTestMongoDatabase(t)
+
+		// the unique indexes the migrations create
+		_, err := db.Collection(userCollection).Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "email_canonical", Value: 1}}, Options: options.Index().SetUnique(true)},
+		})
+		require.NoError(t, err)
+
+		return NewUserRepo(db)
+	})
+}
@@ -0,0 +1,491 @@
+package repo
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postgresSystem is the db.system of the Postgres repositories
+const postgresSystem = "postgresql"
+
+const userColumns = `id, name, email, email_canonical, hashed_password, role, status, created_at, deleted_at,
+	last_login_at, version, avatar_url, bio, locale, timezone, phone, metadata, pending_email, status_history`
+
+type userPostgres struct {
+	ID             string            `db:"id"`
+	Name           string            `db:"name"`
+	Email          string            `db:"email"`
+	EmailCanonical string            `db:"email_canonical"`
+	HashedPassword string            `db:"hashed_password"`
+	Role           string            `db:"role"`
+	Status         string            `db:"status"`
+	CreatedAt      time.Time         `db:"created_at"`
+	DeletedAt      *time.Time        `db:"deleted_at"`
+	LastLoginAt    *time.Time        `db:"last_login_at"`
+	Version        int64             `db:"version"`
+	AvatarURL      string            `db:"avatar_url"`
+	Bio            string            `db:"bio"`
+	Locale         string            `db:"locale"`
+	Timezone       string            `db:"timezone"`
+	Phone          string            `db:"phone"`
+	Metadata       map[string]string `db:"metadata"`
+	PendingEmail   string            `db:"pending_email"`
+	StatusHistory  []statusChangeSQL `db:"status_history"`
+}
+
+func (u *userPostgres) toModel() (*model.User, error) {
+	return model.NewUser(model.UserOpts{
+		ID:             u.ID,
+		Name:           u.Name,
+		Email:          u.Email,
+		HashedPassword: u.HashedPassword,
+		Role:           model.UserRole(u.Role),
+		Status:         model.UserStatus(u.Status),
+		CreatedAt:      u.CreatedAt.UTC(),
+		DeletedAt:      utcTime(u.DeletedAt),
+		LastLoginAt:    utcTime(u.LastLoginAt),
+		Version:        u.Version,
+		AvatarURL:      u.AvatarURL,
+		Bio:            u.Bio,
+		Locale:         u.Locale,
+		Timezone:       u.Timezone,
+		Phone:          u.Phone,
+		Metadata:       u.Metadata,
+		PendingEmail:   u.PendingEmail,
+		StatusHistory:  statusHistorySQL(u.StatusHistory),
+	})
+}
+
+// utcTime returns the time in UTC, or the zero time for NULL
+func utcTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// metadataValue stores an empty map as NULL, like it is left out of the Mongo document
+func metadataValue(metadata map[string]string) any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// UserRepoPostgres stores users in Postgres with the same behavior as UserRepo, the schema is
+// created by the migrations in migrations/postgres
+type UserRepoPostgres struct {
+	pool *pgxpool.Pool
+}
+
+func NewUserRepoPostgres(pool *pgxpool.Pool) *UserRepoPostgres {
+	return &UserRepoPostgres{
+		pool: pool,
+	}
+}
+
+var _ model.UserRepo = (*UserRepoPostgres)(nil)
+
+// conn runs statements in the transaction of ctx when there is one
+func (r *UserRepoPostgres) conn(ctx context.Context) postgresConn {
+	return postgresConnFrom(ctx, r.pool)
+}
+
+func (r *UserRepoPostgres) Count(ctx context.Context) (_ int64, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "Count")
+	defer end(&err)
+
+	var count int64
+	err = r.conn(ctx).QueryRow(ctx, "SELECT count(*) FROM users WHERE deleted_at IS NULL").Scan(&count)
+	if err != nil {
+		return 0, errx.Postgres(err)
+	}
+
+	return count, nil
+}
+
+func (r *UserRepoPostgres) List(ctx context.Context, opts model.ListUserOpts) (_ *model.UserPage, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "List")
+	defer end(&err)
+
+	sort, sortAsc := opts.GetSort()
+	backward := opts.Cursor != nil && opts.Cursor.Backward
+	// a backward page is read in reverse order starting from the cursor, then flipped
+	ascending := sortAsc != backward
+
+	var args sqlArgs
+	conditions := listUserConditions(opts, &args)
+	column := userSortColumn(sort)
+	if opts.Cursor != nil {
+		after, err := userCursorCondition(opts.Cursor, ascending, &args)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, after)
+	}
+
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
+
+	// fetch one extra row to know whether there is another page
+	query := "SELECT " + userColumns + " FROM users WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY " + column + " " + direction + ", id " + direction +
+		" LIMIT " + args.add(opts.GetLimit()+1)
+
+	users, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUserPage(users, opts), nil
+}
+
+// userSortColumn maps the sort field to its column, so only known columns end up in the query
+func userSortColumn(sort model.UserSortField) string {
+	switch sort {
+	case model.UserSortName:
+		return "name"
+	case model.UserSortEmail:
+		return "email"
+	}
+	return "created_at"
+}
+
+// userCursorCondition matches the rows strictly after the cursor position when reading in the given order
+func userCursorCondition(c *model.UserCursor, ascending bool, args *sqlArgs) (string, error) {
+	id, err := sqlUserID(c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var value any = c.Value
+	if c.Sort == model.UserSortCreatedAt {
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return "", errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		value = createdAt
+	}
+
+	op := "<"
+	if ascending {
+		op = ">"
+	}
+
+	return "(" + userSortColumn(c.Sort) + ", id) " + op + " (" + args.add(value) + ", " + args.add(id) + ")", nil
+}
+
+// listUserConditions applies the same filters as listUserFilters
+func listUserConditions(opts model.ListUserOpts, args *sqlArgs) []string {
+	conditions := []string{"deleted_at IS NULL"}
+	if opts.NamePrefix != "" {
+		conditions = append(conditions, "name LIKE "+args.add(likeEscaper.Replace(opts.NamePrefix)+"%"))
+	}
+	if opts.EmailPrefix != "" {
+		conditions = append(conditions, "email LIKE "+args.add(likeEscaper.Replace(opts.EmailPrefix)+"%"))
+	}
+	if opts.Search != "" {
+		pattern := args.add("%" + likeEscaper.Replace(opts.Search) + "%")
+		conditions = append(conditions, "(name ILIKE "+pattern+" OR email ILIKE "+pattern+")")
+	}
+	if !opts.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= "+args.add(storedTime(opts.CreatedFrom)))
+	}
+	if !opts.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at <= "+args.add(storedTime(opts.CreatedTo)))
+	}
+	if opts.Role != "" {
+		conditions = append(conditions, "role = "+args.add(string(opts.Role)))
+	}
+	if opts.Status != "" {
+		conditions = append(conditions, "status = "+args.add(string(opts.Status)))
+	}
+	return conditions
+}
+
+// query reads the users returned by a query selecting userColumns
+func (r *UserRepoPostgres) query(ctx context.Context, query string, args ...any) ([]model.User, error) {
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	rowUsers, err := pgx.CollectRows(rows, pgx.RowToStructByName[userPostgres])
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	users := make([]model.User, 0, len(rowUsers))
+	for _, u := range rowUsers {
+		user, err := u.toModel()
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, nil
+}
+
+// findOne reads the single user matching the condition
+func (r *UserRepoPostgres) findOne(ctx context.Context, condition string, args ...any) (*model.User, error) {
+	rows, err := r.conn(ctx).Query(ctx, "SELECT "+userColumns+" FROM users WHERE "+condition, args...)
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	u, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[userPostgres])
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	return u.toModel()
+}
+
+func (r *UserRepoPostgres) Create(ctx context.Context, user *model.User) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "Create")
+	defer end(&err)
+
+	u := userPostgres{
+		ID:             primitive.NewObjectID().Hex(),
+		Name:           user.Name(),
+		Email:          user.Email(),
+		EmailCanonical: user.EmailCanonical(),
+		HashedPassword: user.HashedPassword(),
+		Role:           string(user.Role()),
+		Status:         string(user.Status()),
+		CreatedAt:      storedTime(user.CreatedAt()),
+		Version:        user.Version(),
+		AvatarURL:      user.AvatarURL(),
+		Bio:            user.Bio(),
+		Locale:         user.Locale(),
+		Timezone:       user.Timezone(),
+		Phone:          user.Phone(),
+		Metadata:       user.Metadata(),
+		PendingEmail:   user.PendingEmail(),
+		StatusHistory:  newStatusHistorySQL(user.StatusHistory()),
+	}
+
+	_, err = r.conn(ctx).Exec(ctx, `INSERT INTO users (id, name, email, email_canonical, hashed_password, role, status,
+		created_at, version, avatar_url, bio, locale, timezone, phone, metadata, pending_email, status_history)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		u.ID, u.Name, u.Email, u.EmailCanonical, u.HashedPassword, u.Role, u.Status,
+		u.CreatedAt, u.Version, u.AvatarURL, u.Bio, u.Locale, u.Timezone, u.Phone, metadataValue(u.Metadata),
+		u.PendingEmail, u.StatusHistory,
+	)
+	if errx.IsPostgresUniqueViolation(err) {
+		return nil, model.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, errx.Postgres(err)
+	}
+
+	return u.toModel()
+}
+
+func (r *UserRepoPostgres) FindByID(ctx context.Context, id string) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "FindByID")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findOne(ctx, "id = $1 AND deleted_at IS NULL", id)
+}
+
+func (r *UserRepoPostgres) ExistsByID(ctx context.Context, id string) (_ bool, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "ExistsByID")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return false, err
+	}
+
+	var exist bool
+	err = r.conn(ctx).QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exist)
+	if err != nil {
+		return false, errx.Postgres(err)
+	}
+
+	return exist, nil
+}
+
+func (r *UserRepoPostgres) FindByEmail(ctx context.Context, email string) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "FindByEmail")
+	defer end(&err)
+
+	return r.findOne(ctx, "email_canonical = $1 AND deleted_at IS NULL", model.CanonicalEmail(email))
+}
+
+func (r *UserRepoPostgres) Update(ctx context.Context, user *model.User) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "Update")
+	defer end(&err)
+
+	id, err := sqlUserID(user.ID())
+	if err != nil {
+		return err
+	}
+
+	changes := user.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var args sqlArgs
+	set := []string{"version = " + args.add(user.Version()+1)}
+	for _, field := range changes {
+		column, value, err := userFieldValue(user, field)
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case model.UserFieldMetadata:
+			value = metadataValue(user.Metadata())
+		case model.UserFieldStatusHistory:
+			value = newStatusHistorySQL(user.StatusHistory())
+		case model.UserFieldStatus:
+			value = string(user.Status())
+		}
+		set = append(set, column+" = "+args.add(value))
+	}
+	if slices.Contains(changes, model.UserFieldEmail) {
+		set = append(set, "email_canonical = "+args.add(user.EmailCanonical()))
+	}
+
+	query := "UPDATE users SET " + strings.Join(set, ", ") +
+		" WHERE id = " + args.add(id) + " AND deleted_at IS NULL AND version = " + args.add(user.Version())
+
+	result, err := r.conn(ctx).Exec(ctx, query, args...)
+	if errx.IsPostgresUniqueViolation(err) && slices.Contains(changes, model.UserFieldEmail) {
+		return model.ErrEmailTaken
+	}
+	if err != nil {
+		return errx.Postgres(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		// tell a concurrent update apart from a user that is gone
+		exist, err := r.ExistsByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return model.ErrUserVersionMismatch
+		}
+		return errx.Postgres(pgx.ErrNoRows)
+	}
+
+	user.MarkSaved(user.Version() + 1)
+	return nil
+}
+
+func (r *UserRepoPostgres) Delete(ctx context.Context, id string) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "Delete")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).Exec(ctx, "UPDATE users SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL", id, storedTime(time.Now()))
+	if err != nil {
+		return errx.Postgres(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepoPostgres) FindDeletedByEmail(ctx context.Context, email string) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "FindDeletedByEmail")
+	defer end(&err)
+
+	return r.findOne(ctx, "email_canonical = $1 AND deleted_at IS NOT NULL", model.CanonicalEmail(email))
+}
+
+func (r *UserRepoPostgres) Restore(ctx context.Context, id string) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "Restore")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.conn(ctx).Exec(ctx, "UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return errx.Postgres(err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return errx.Postgres(pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *UserRepoPostgres) ListDeleted(ctx context.Context, before time.Time, limit int) (_ []model.User, err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "ListDeleted")
+	defer end(&err)
+
+	// LIMIT NULL lists every user, like a Mongo find without a limit
+	var rowLimit *int
+	if limit > 0 {
+		rowLimit = &limit
+	}
+
+	return r.query(ctx, "SELECT "+userColumns+` FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		ORDER BY deleted_at, id LIMIT $2`, storedTime(before), rowLimit)
+}
+
+func (r *UserRepoPostgres) Purge(ctx context.Context, id string) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "Purge")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).Exec(ctx, "DELETE FROM users WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return errx.Postgres(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepoPostgres) RecordLogin(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, end := instrumentSQL(ctx, postgresSystem, userCollection, "RecordLogin")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	// GREATEST ignores NULL and keeps the latest time when concurrent logins finish out of order
+	_, err = r.conn(ctx).Exec(ctx, `UPDATE users SET last_login_at = GREATEST(last_login_at, $2)
+		WHERE id = $1 AND deleted_at IS NULL`, id, storedTime(at))
+	if err != nil {
+		return errx.Postgres(err)
+	}
+
+	return nil
+}
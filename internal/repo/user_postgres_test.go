@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestPostgresPool returns a pool on an empty, migrated schema that is dropped after the test,
+// the test is skipped unless POSTGRES_TEST_URL points at a server where schemas can be created
+func newTestPostgresPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL is not set")
+	}
+
+	ctx := context.Background()
+	schema := pgx.Identifier{"sevenhunter_test_" + primitive.NewObjectID().Hex()}.Sanitize()
+
+	conf, err := pgxpool.ParseConfig(url)
+	require.NoError(t, err)
+	conf.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, conf)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, "CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = pool.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		pool.Close()
+	})
+
+	require.NoError(t, MigratePostgres(ctx, pool))
+	return pool
+}
+
+func TestUserRepoPostgres(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) model.UserRepo {
+		return NewUserRepoPostgres(newTestPostgresPool(t))
+	})
+}
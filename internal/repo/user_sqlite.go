@@ -0,0 +1,481 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sqliteSystem is the db.system of the SQLite repositories
+const sqliteSystem = "sqlite"
+
+// unixMilli is how the SQLite repositories store times, the millisecond precision of Mongo
+func unixMilli(t time.Time) int64 {
+	return storedTime(t).UnixMilli()
+}
+
+func fromUnixMilli(ms sql.NullInt64) time.Time {
+	if !ms.Valid {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms.Int64).UTC()
+}
+
+// metadataJSON stores an empty map as NULL, like it is left out of the Mongo document
+func metadataJSON(metadata map[string]string) (any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, errx.E(http.StatusInternalServerError, err)
+	}
+	return string(data), nil
+}
+
+func statusHistoryJSON(history []model.UserStatusChange) (string, error) {
+	data, err := json.Marshal(newStatusHistorySQL(history))
+	if err != nil {
+		return "", errx.E(http.StatusInternalServerError, err)
+	}
+	return string(data), nil
+}
+
+// scanUserSQLite reads a row selecting userColumns
+func scanUserSQLite(row interface{ Scan(dest ...any) error }) (*model.User, error) {
+	var (
+		opts                   model.UserOpts
+		role, status           string
+		createdAt              int64
+		deletedAt, lastLoginAt sql.NullInt64
+		metadata               sql.NullString
+		statusHistory          string
+		emailCanonical         string
+	)
+
+	err := row.Scan(&opts.ID, &opts.Name, &opts.Email, &emailCanonical, &opts.HashedPassword, &role, &status,
+		&createdAt, &deletedAt, &lastLoginAt, &opts.Version, &opts.AvatarURL, &opts.Bio, &opts.Locale,
+		&opts.Timezone, &opts.Phone, &metadata, &opts.PendingEmail, &statusHistory)
+	if err != nil {
+		return nil, errx.SQLite(err)
+	}
+
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &opts.Metadata); err != nil {
+			return nil, errx.E(http.StatusInternalServerError, err)
+		}
+	}
+
+	var history []statusChangeSQL
+	if err := json.Unmarshal([]byte(statusHistory), &history); err != nil {
+		return nil, errx.E(http.StatusInternalServerError, err)
+	}
+
+	opts.Role = model.UserRole(role)
+	opts.Status = model.UserStatus(status)
+	opts.CreatedAt = fromUnixMilli(sql.NullInt64{Int64: createdAt, Valid: true})
+	opts.DeletedAt = fromUnixMilli(deletedAt)
+	opts.LastLoginAt = fromUnixMilli(lastLoginAt)
+	opts.StatusHistory = statusHistorySQL(history)
+	return model.NewUser(opts)
+}
+
+// UserRepoSQLite stores users in an SQLite file with the same behavior as UserRepo, for single node
+// deployments. The schema is created by the migrations in migrations/sqlite.
+type UserRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewUserRepoSQLite(db *sql.DB) *UserRepoSQLite {
+	return &UserRepoSQLite{
+		db: db,
+	}
+}
+
+var _ model.UserRepo = (*UserRepoSQLite)(nil)
+
+// conn runs statements in the transaction of ctx when there is one
+func (r *UserRepoSQLite) conn(ctx context.Context) sqliteConn {
+	return sqliteConnFrom(ctx, r.db)
+}
+
+func (r *UserRepoSQLite) Count(ctx context.Context) (_ int64, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "Count")
+	defer end(&err)
+
+	var count int64
+	err = r.conn(ctx).QueryRowContext(ctx, "SELECT count(*) FROM users WHERE deleted_at IS NULL").Scan(&count)
+	if err != nil {
+		return 0, errx.SQLite(err)
+	}
+
+	return count, nil
+}
+
+func (r *UserRepoSQLite) List(ctx context.Context, opts model.ListUserOpts) (_ *model.UserPage, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "List")
+	defer end(&err)
+
+	sort, sortAsc := opts.GetSort()
+	backward := opts.Cursor != nil && opts.Cursor.Backward
+	// a backward page is read in reverse order starting from the cursor, then flipped
+	ascending := sortAsc != backward
+
+	var args sqlArgs
+	conditions := listUserConditionsSQLite(opts, &args)
+	if opts.Cursor != nil {
+		after, err := userCursorConditionSQLite(opts.Cursor, ascending, &args)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, after)
+	}
+
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
+
+	// fetch one extra row to know whether there is another page
+	column := userSortColumn(sort)
+	query := "SELECT " + userColumns + " FROM users WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY " + column + " " + direction + ", id " + direction +
+		" LIMIT " + args.add(opts.GetLimit()+1)
+
+	users, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUserPage(users, opts), nil
+}
+
+// userCursorConditionSQLite matches the rows strictly after the cursor position when reading in the given order
+func userCursorConditionSQLite(c *model.UserCursor, ascending bool, args *sqlArgs) (string, error) {
+	id, err := sqlUserID(c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var value any = c.Value
+	if c.Sort == model.UserSortCreatedAt {
+		createdAt, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return "", errx.E(http.StatusBadRequest, err, "invalid cursor")
+		}
+		value = unixMilli(createdAt)
+	}
+
+	op := "<"
+	if ascending {
+		op = ">"
+	}
+
+	return "(" + userSortColumn(c.Sort) + ", id) " + op + " (" + args.add(value) + ", " + args.add(id) + ")", nil
+}
+
+// listUserConditionsSQLite applies the same filters as listUserFilters. LIKE ignores ASCII case in SQLite,
+// so prefixes are compared with substr to stay case sensitive like the Mongo regex.
+func listUserConditionsSQLite(opts model.ListUserOpts, args *sqlArgs) []string {
+	conditions := []string{"deleted_at IS NULL"}
+	if opts.NamePrefix != "" {
+		prefix := args.add(opts.NamePrefix)
+		conditions = append(conditions, "substr(name, 1, length("+prefix+")) = "+prefix)
+	}
+	if opts.EmailPrefix != "" {
+		prefix := args.add(opts.EmailPrefix)
+		conditions = append(conditions, "substr(email, 1, length("+prefix+")) = "+prefix)
+	}
+	if opts.Search != "" {
+		pattern := args.add("%" + likeEscaper.Replace(opts.Search) + "%")
+		conditions = append(conditions, "(name LIKE "+pattern+` ESCAPE '\' OR email LIKE `+pattern+` ESCAPE '\')`)
+	}
+	if !opts.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= "+args.add(unixMilli(opts.CreatedFrom)))
+	}
+	if !opts.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at <= "+args.add(unixMilli(opts.CreatedTo)))
+	}
+	if opts.Role != "" {
+		conditions = append(conditions, "role = "+args.add(string(opts.Role)))
+	}
+	if opts.Status != "" {
+		conditions = append(conditions, "status = "+args.add(string(opts.Status)))
+	}
+	return conditions
+}
+
+// query reads the users returned by a query selecting userColumns
+func (r *UserRepoSQLite) query(ctx context.Context, query string, args ...any) ([]model.User, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errx.SQLite(err)
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0)
+	for rows.Next() {
+		user, err := scanUserSQLite(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errx.SQLite(err)
+	}
+
+	return users, nil
+}
+
+// findOne reads the single user matching the condition
+func (r *UserRepoSQLite) findOne(ctx context.Context, condition string, args ...any) (*model.User, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE "+condition, args...)
+	return scanUserSQLite(row)
+}
+
+func (r *UserRepoSQLite) Create(ctx context.Context, user *model.User) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "Create")
+	defer end(&err)
+
+	metadata, err := metadataJSON(user.Metadata())
+	if err != nil {
+		return nil, err
+	}
+	history, err := statusHistoryJSON(user.StatusHistory())
+	if err != nil {
+		return nil, err
+	}
+
+	id := primitive.NewObjectID().Hex()
+	_, err = r.conn(ctx).ExecContext(ctx, `INSERT INTO users (id, name, email, email_canonical, hashed_password, role, status,
+		created_at, version, avatar_url, bio, locale, timezone, phone, metadata, pending_email, status_history)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		id, user.Name(), user.Email(), user.EmailCanonical(), user.HashedPassword(), string(user.Role()),
+		string(user.Status()), unixMilli(user.CreatedAt()), user.Version(), user.AvatarURL(), user.Bio(),
+		user.Locale(), user.Timezone(), user.Phone(), metadata, user.PendingEmail(), history,
+	)
+	if errx.IsSQLiteUniqueViolation(err) {
+		return nil, model.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, errx.SQLite(err)
+	}
+
+	return r.findOne(ctx, "id = $1", id)
+}
+
+func (r *UserRepoSQLite) FindByID(ctx context.Context, id string) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "FindByID")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.findOne(ctx, "id = $1 AND deleted_at IS NULL", id)
+}
+
+func (r *UserRepoSQLite) ExistsByID(ctx context.Context, id string) (_ bool, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "ExistsByID")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return false, err
+	}
+
+	var exist bool
+	err = r.conn(ctx).QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exist)
+	if err != nil {
+		return false, errx.SQLite(err)
+	}
+
+	return exist, nil
+}
+
+func (r *UserRepoSQLite) FindByEmail(ctx context.Context, email string) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "FindByEmail")
+	defer end(&err)
+
+	return r.findOne(ctx, "email_canonical = $1 AND deleted_at IS NULL", model.CanonicalEmail(email))
+}
+
+func (r *UserRepoSQLite) Update(ctx context.Context, user *model.User) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "Update")
+	defer end(&err)
+
+	id, err := sqlUserID(user.ID())
+	if err != nil {
+		return err
+	}
+
+	changes := user.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var args sqlArgs
+	set := []string{"version = " + args.add(user.Version()+1)}
+	for _, field := range changes {
+		column, value, err := userFieldValue(user, field)
+		if err != nil {
+			return err
+		}
+
+		switch field {
+		case model.UserFieldMetadata:
+			value, err = metadataJSON(user.Metadata())
+		case model.UserFieldStatusHistory:
+			value, err = statusHistoryJSON(user.StatusHistory())
+		case model.UserFieldStatus:
+			value = string(user.Status())
+		}
+		if err != nil {
+			return err
+		}
+		set = append(set, column+" = "+args.add(value))
+	}
+	if slices.Contains(changes, model.UserFieldEmail) {
+		set = append(set, "email_canonical = "+args.add(user.EmailCanonical()))
+	}
+
+	query := "UPDATE users SET " + strings.Join(set, ", ") +
+		" WHERE id = " + args.add(id) + " AND deleted_at IS NULL AND version = " + args.add(user.Version())
+
+	result, err := r.conn(ctx).ExecContext(ctx, query, args...)
+	if errx.IsSQLiteUniqueViolation(err) && slices.Contains(changes, model.UserFieldEmail) {
+		return model.ErrEmailTaken
+	}
+	if err != nil {
+		return errx.SQLite(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errx.SQLite(err)
+	}
+	if affected == 0 {
+		// tell a concurrent update apart from a user that is gone
+		exist, err := r.ExistsByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return model.ErrUserVersionMismatch
+		}
+		return errx.SQLite(sql.ErrNoRows)
+	}
+
+	user.MarkSaved(user.Version() + 1)
+	return nil
+}
+
+func (r *UserRepoSQLite) Delete(ctx context.Context, id string) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "Delete")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).ExecContext(ctx, "UPDATE users SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL", id, unixMilli(time.Now()))
+	if err != nil {
+		return errx.SQLite(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepoSQLite) FindDeletedByEmail(ctx context.Context, email string) (_ *model.User, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "FindDeletedByEmail")
+	defer end(&err)
+
+	return r.findOne(ctx, "email_canonical = $1 AND deleted_at IS NOT NULL", model.CanonicalEmail(email))
+}
+
+func (r *UserRepoSQLite) Restore(ctx context.Context, id string) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "Restore")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.conn(ctx).ExecContext(ctx, "UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return errx.SQLite(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return errx.SQLite(err)
+	}
+	if affected == 0 {
+		return errx.SQLite(sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *UserRepoSQLite) ListDeleted(ctx context.Context, before time.Time, limit int) (_ []model.User, err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "ListDeleted")
+	defer end(&err)
+
+	// a negative LIMIT lists every user, like a Mongo find without a limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return r.query(ctx, "SELECT "+userColumns+` FROM users
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		ORDER BY deleted_at, id LIMIT $2`, unixMilli(before), limit)
+}
+
+func (r *UserRepoSQLite) Purge(ctx context.Context, id string) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "Purge")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return errx.SQLite(err)
+	}
+
+	return nil
+}
+
+func (r *UserRepoSQLite) RecordLogin(ctx context.Context, id string, at time.Time) (err error) {
+	ctx, end := instrumentSQL(ctx, sqliteSystem, userCollection, "RecordLogin")
+	defer end(&err)
+
+	id, err = sqlUserID(id)
+	if err != nil {
+		return err
+	}
+
+	// max keeps the latest time when concurrent logins finish out of order
+	_, err = r.conn(ctx).ExecContext(ctx, `UPDATE users SET last_login_at = max(coalesce(last_login_at, 0), $2)
+		WHERE id = $1 AND deleted_at IS NULL`, id, unixMilli(at))
+	if err != nil {
+		return errx.SQLite(err)
+	}
+
+	return nil
+}
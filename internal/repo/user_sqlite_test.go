@@ -0,0 +1,34 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/repo/repotest"
+	"github.com/chai-rs/sevenhunter/pkg/sqlite"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSQLiteDB returns a migrated database in a file that is removed after the test
+func newTestSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, filepath.Join(t.TempDir(), "sevenhunter.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, MigrateSQLite(ctx, db))
+	// migrating again is a no-op
+	require.NoError(t, MigrateSQLite(ctx, db))
+	return db
+}
+
+func TestUserRepoSQLite(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) model.UserRepo {
+		return NewUserRepoSQLite(newTestSQLiteDB(t))
+	})
+}
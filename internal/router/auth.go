@@ -1,29 +1,46 @@
 package router
 
 import (
+	"time"
+
 	"github.com/chai-rs/sevenhunter/internal/handler"
-	"github.com/chai-rs/sevenhunter/internal/repo"
+	"github.com/chai-rs/sevenhunter/internal/middleware"
+	"github.com/chai-rs/sevenhunter/internal/model"
 	"github.com/chai-rs/sevenhunter/internal/service"
 	"github.com/chai-rs/sevenhunter/pkg/jwt"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type BindAuthOpts struct {
-	DB           *mongo.Database
-	TokenManager *jwt.TokenManager
+	UserRepo            model.UserRepo
+	LoginHistory        model.LoginHistoryRepo
+	UnitOfWork          model.UnitOfWork
+	Events              model.EventPublisher
+	TokenManager        *jwt.TokenManager
+	DeletionGracePeriod time.Duration
+	// LoginHistoryRetention is how long login history entries are kept
+	LoginHistoryRetention time.Duration
+	RateLimit             middleware.RateLimitOpts
+	Idempotency           middleware.IdempotencyOpts
 }
 
 func BindAuth(group fiber.Router, opts BindAuthOpts) {
 	hdl := handler.NewAuthHandler(handler.AuthHandlerOpts{
 		Service: service.NewAuthService(&service.AuthServiceOpts{
-			TokenManager: opts.TokenManager,
-			UserRepo:     repo.NewUserRepo(opts.DB),
+			TokenManager:          opts.TokenManager,
+			UserRepo:              opts.UserRepo,
+			LoginHistory:          opts.LoginHistory,
+			UnitOfWork:            opts.UnitOfWork,
+			Events:                opts.Events,
+			DeletionGracePeriod:   opts.DeletionGracePeriod,
+			LoginHistoryRetention: opts.LoginHistoryRetention,
 		}),
 	})
 
 	router := group.Group("/auth")
+	router.Use(middleware.RateLimit(opts.RateLimit))
 	router.Post("/login", hdl.Login)
-	router.Post("/register", hdl.Register)
+	router.Post("/register", middleware.Idempotency(opts.Idempotency), hdl.Register)
 	router.Post("/refresh", hdl.RefreshToken)
+	router.Post("/restore", hdl.Restore)
 }
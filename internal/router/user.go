@@ -1,33 +1,82 @@
 package router
 
 import (
+	"time"
+
 	"github.com/chai-rs/sevenhunter/internal/handler"
 	"github.com/chai-rs/sevenhunter/internal/middleware"
-	"github.com/chai-rs/sevenhunter/internal/repo"
+	"github.com/chai-rs/sevenhunter/internal/model"
 	"github.com/chai-rs/sevenhunter/internal/service"
+	"github.com/chai-rs/sevenhunter/pkg/blob"
+	"github.com/chai-rs/sevenhunter/pkg/cursor"
 	"github.com/chai-rs/sevenhunter/pkg/jwt"
+	"github.com/chai-rs/sevenhunter/pkg/mail"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type BindUserOpts struct {
-	DB           *mongo.Database
+	UserRepo     model.UserRepo
+	LoginHistory model.LoginHistoryRepo
+	UnitOfWork   model.UnitOfWork
+	Events       model.EventPublisher
 	TokenManager *jwt.TokenManager
+	Cursors      *cursor.Codec
+	Blobs        blob.Store
+	// AvatarURLPrefix is the public URL of the avatar route
+	AvatarURLPrefix string
+	AvatarMaxBytes  int64
+	Mailer          mail.Mailer
+	EmailChangeTTL  time.Duration
+	EmailConfirmURL string
+	RateLimit       middleware.RateLimitOpts
 }
 
 func BindUser(group fiber.Router, opts BindUserOpts) {
-	userRepo := repo.NewUserRepo(opts.DB)
+	userRepo := opts.UserRepo
 	hdl := handler.NewUserHandler(handler.UserHandlerOpts{
 		Service: service.NewUserService(service.UserServiceOpts{
-			UserRepo: userRepo,
+			UserRepo:        userRepo,
+			LoginHistory:    opts.LoginHistory,
+			UnitOfWork:      opts.UnitOfWork,
+			Events:          opts.Events,
+			TokenManager:    opts.TokenManager,
+			Mailer:          opts.Mailer,
+			EmailChangeTTL:  opts.EmailChangeTTL,
+			EmailConfirmURL: opts.EmailConfirmURL,
+		}),
+		Cursors: opts.Cursors,
+	})
+
+	avatarHdl := handler.NewAvatarHandler(handler.AvatarHandlerOpts{
+		Service: service.NewAvatarService(service.AvatarServiceOpts{
+			UserRepo:  userRepo,
+			Blobs:     opts.Blobs,
+			URLPrefix: opts.AvatarURLPrefix,
+			MaxBytes:  opts.AvatarMaxBytes,
 		}),
 	})
 
+	// avatars are public so they can be used directly as image sources
+	group.Get("/avatars/:user_id", avatarHdl.Get)
+	// registered ahead of the /users middlewares, the link is opened from the new mailbox without a session
+	group.Post("/users/email/confirm", middleware.RateLimit(opts.RateLimit), hdl.ConfirmEmail)
+
 	router := group.Group("/users")
 	router.Use(middleware.Auth(opts.TokenManager, userRepo))
+	router.Use(middleware.RateLimit(opts.RateLimit))
 	router.Get("", hdl.List)
 	router.Get("/count", hdl.Count)
 	router.Get("/profile", hdl.Get)
 	router.Put("/profile", hdl.Update)
+	router.Patch("/profile", hdl.Patch)
+	router.Get("/profile/logins", hdl.Logins)
+	router.Put("/profile/avatar", avatarHdl.Upload)
 	router.Delete("/profile", hdl.Delete)
+
+	admin := group.Group("/admin/users")
+	admin.Use(middleware.Auth(opts.TokenManager, userRepo))
+	admin.Use(middleware.RequireRole(model.UserRoleAdmin))
+	admin.Use(middleware.RateLimit(opts.RateLimit))
+	admin.Get("/:user_id/status", hdl.GetStatus)
+	admin.Put("/:user_id/status", hdl.ChangeStatus)
 }
@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"github.com/chai-rs/sevenhunter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "scheduler",
+		Name:      "job_runs_total",
+		Help:      "Total number of scheduled job runs by job.",
+	}, []string{"job"})
+
+	jobFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "scheduler",
+		Name:      "job_failures_total",
+		Help:      "Total number of failed scheduled job runs by job.",
+	}, []string{"job"})
+
+	usersPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "users_purged_total",
+		Help:      "Total number of deleted users purged after the grace period.",
+	})
+
+	userCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "users",
+		Help:      "Current number of registered users.",
+	})
+)
@@ -21,11 +21,18 @@ func NewUserCountScheduler(opts UserCountSchedulerOpts) *UserCountScheduler {
 	}
 }
 
+const userCountJob = "user_count"
+
 func (s *UserCountScheduler) Run(ctx context.Context) {
+	jobRuns.WithLabelValues(userCountJob).Inc()
+
 	count, err := s.userRepo.Count(ctx)
 	if err != nil {
+		jobFailures.WithLabelValues(userCountJob).Inc()
 		logx.Error().Err(err).Msg("failed to get user count")
+		return
 	}
 
+	userCount.Set(float64(count))
 	logx.Info().Int64("user_count", count).Msg("current user count")
 }
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+)
+
+type UserPurgeScheduler struct {
+	userRepo    model.UserRepo
+	purgers     []model.UserDataPurger
+	gracePeriod time.Duration
+	batchSize   int
+	now         func() time.Time
+}
+
+type UserPurgeSchedulerOpts struct {
+	UserRepo model.UserRepo
+	// Purgers remove the data owned by a user before the user itself is removed
+	Purgers     []model.UserDataPurger
+	GracePeriod time.Duration
+	BatchSize   int
+}
+
+func NewUserPurgeScheduler(opts UserPurgeSchedulerOpts) *UserPurgeScheduler {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &UserPurgeScheduler{
+		userRepo:    opts.UserRepo,
+		purgers:     opts.Purgers,
+		gracePeriod: opts.GracePeriod,
+		batchSize:   batchSize,
+		now:         time.Now,
+	}
+}
+
+const userPurgeJob = "user_purge"
+
+func (s *UserPurgeScheduler) Run(ctx context.Context) {
+	jobRuns.WithLabelValues(userPurgeJob).Inc()
+
+	purged, err := s.purge(ctx)
+	if err != nil {
+		jobFailures.WithLabelValues(userPurgeJob).Inc()
+		logx.Error().Err(err).Int("purged", purged).Msg("failed to purge deleted users")
+		return
+	}
+
+	if purged > 0 {
+		logx.Info().Int("purged", purged).Msg("purged deleted users")
+	}
+}
+
+func (s *UserPurgeScheduler) purge(ctx context.Context) (int, error) {
+	before := s.now().Add(-s.gracePeriod)
+
+	purged := 0
+	for {
+		users, err := s.userRepo.ListDeleted(ctx, before, s.batchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, user := range users {
+			if err := s.purgeUser(ctx, user.ID()); err != nil {
+				return purged, err
+			}
+			purged++
+			usersPurged.Inc()
+		}
+
+		if len(users) < s.batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// purgeUser removes the owned data first so a failure leaves the user in place to be retried on the next run
+func (s *UserPurgeScheduler) purgeUser(ctx context.Context, userID string) error {
+	for _, purger := range s.purgers {
+		if err := purger.PurgeUserData(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	return s.userRepo.Purge(ctx, userID)
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/model/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func createDeletedUser(t *testing.T, id string, deletedAt time.Time) model.User {
+	user, err := model.NewUser(model.UserOpts{
+		ID:             id,
+		Name:           "John Doe",
+		Email:          "john@example.com",
+		HashedPassword: "$2a$10$abcdefghijklmnopqrstuuuuuuuuuuuuuuuuuuuuuuuuuuuuuuuuu",
+		CreatedAt:      deletedAt.Add(-time.Hour),
+		DeletedAt:      deletedAt,
+	})
+	require.NoError(t, err)
+	return *user
+}
+
+func TestUserPurgeScheduler_Purge(t *testing.T) {
+	now := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	gracePeriod := 30 * 24 * time.Hour
+	before := now.Add(-gracePeriod)
+
+	type Testcase struct {
+		name     string
+		arrange  func(t *testing.T, repo *mocks.MockUserRepo, purged *[]string)
+		failData bool
+		expected int
+		purged   []string
+		isError  bool
+	}
+
+	testcases := []Testcase{
+		{
+			name: "nothing to purge",
+			arrange: func(t *testing.T, repo *mocks.MockUserRepo, purged *[]string) {
+				repo.EXPECT().ListDeleted(mock.Anything, before, 2).Return([]model.User{}, nil)
+			},
+			expected: 0,
+		},
+		{
+			name: "purges users and their data in batches",
+			arrange: func(t *testing.T, repo *mocks.MockUserRepo, purged *[]string) {
+				repo.EXPECT().ListDeleted(mock.Anything, before, 2).Return([]model.User{
+					createDeletedUser(t, "1", before.Add(-2*time.Hour)),
+					createDeletedUser(t, "2", before.Add(-time.Hour)),
+				}, nil).Once()
+				repo.EXPECT().ListDeleted(mock.Anything, before, 2).Return([]model.User{
+					createDeletedUser(t, "3", before.Add(-time.Minute)),
+				}, nil).Once()
+				repo.EXPECT().Purge(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, id string) error {
+					*purged = append(*purged, "user:"+id)
+					return nil
+				}).Times(3)
+			},
+			expected: 3,
+			purged:   []string{"data:1", "user:1", "data:2", "user:2", "data:3", "user:3"},
+		},
+		{
+			name:     "keeps the user when its data cannot be purged",
+			failData: true,
+			arrange: func(t *testing.T, repo *mocks.MockUserRepo, purged *[]string) {
+				repo.EXPECT().ListDeleted(mock.Anything, before, 2).Return([]model.User{
+					createDeletedUser(t, "1", before.Add(-time.Hour)),
+				}, nil)
+			},
+			expected: 0,
+			purged:   []string{"data:1"},
+			isError:  true,
+		},
+		{
+			name: "fails with repository error",
+			arrange: func(t *testing.T, repo *mocks.MockUserRepo, purged *[]string) {
+				repo.EXPECT().ListDeleted(mock.Anything, before, 2).Return(nil, errors.New("database connection error"))
+			},
+			expected: 0,
+			isError:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var purged []string
+			repo := mocks.NewMockUserRepo(t)
+			tc.arrange(t, repo, &purged)
+
+			s := NewUserPurgeScheduler(UserPurgeSchedulerOpts{
+				UserRepo: repo,
+				Purgers: []model.UserDataPurger{
+					model.UserDataPurgerFunc(func(_ context.Context, userID string) error {
+						purged = append(purged, "data:"+userID)
+						if tc.failData {
+							return errors.New("store unavailable")
+						}
+						return nil
+					}),
+				},
+				GracePeriod: gracePeriod,
+				BatchSize:   2,
+			})
+			s.now = func() time.Time { return now }
+
+			count, err := s.purge(context.Background())
+			if tc.isError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expected, count)
+			require.Equal(t, tc.purged, purged)
+		})
+	}
+}
@@ -9,55 +9,162 @@ import (
 	errx "github.com/chai-rs/sevenhunter/pkg/error"
 	jwtx "github.com/chai-rs/sevenhunter/pkg/jwt"
 	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	ErrRestoreExpired = errx.M(http.StatusGone, "account can no longer be restored")
+)
+
+const DefaultLoginHistoryRetention = 90 * 24 * time.Hour
+
 type AuthService struct {
-	tokenManager *jwtx.TokenManager
-	userRepo     model.UserRepo
+	tokenManager          *jwtx.TokenManager
+	userRepo              model.UserRepo
+	loginHistory          model.LoginHistoryRepo
+	unitOfWork            model.UnitOfWork
+	events                model.EventPublisher
+	deletionGracePeriod   time.Duration
+	loginHistoryRetention time.Duration
 }
 
 type AuthServiceOpts struct {
 	TokenManager *jwtx.TokenManager
 	UserRepo     model.UserRepo
+	LoginHistory model.LoginHistoryRepo
+	// UnitOfWork makes the writes of an operation atomic, model.WithoutTransaction when nil
+	UnitOfWork model.UnitOfWork
+	// Events receives the domain events of the operations, model.NoEvents when nil
+	Events model.EventPublisher
+	// DeletionGracePeriod is how long a deleted account can be restored before it is purged
+	DeletionGracePeriod time.Duration
+	// LoginHistoryRetention is how long login events are kept, DefaultLoginHistoryRetention when zero
+	LoginHistoryRetention time.Duration
 }
 
 func NewAuthService(opts *AuthServiceOpts) *AuthService {
+	loginHistoryRetention := opts.LoginHistoryRetention
+	if loginHistoryRetention <= 0 {
+		loginHistoryRetention = DefaultLoginHistoryRetention
+	}
+
+	unitOfWork := opts.UnitOfWork
+	if unitOfWork == nil {
+		unitOfWork = model.WithoutTransaction{}
+	}
+
+	events := opts.Events
+	if events == nil {
+		events = model.NoEvents{}
+	}
+
 	return &AuthService{
-		tokenManager: opts.TokenManager,
-		userRepo:     opts.UserRepo,
+		tokenManager:          opts.TokenManager,
+		userRepo:              opts.UserRepo,
+		loginHistory:          opts.LoginHistory,
+		unitOfWork:            unitOfWork,
+		events:                events,
+		deletionGracePeriod:   opts.DeletionGracePeriod,
+		loginHistoryRetention: loginHistoryRetention,
 	}
 }
 
 var _ model.AuthService = (*AuthService)(nil)
 
-func (s *AuthService) Register(ctx context.Context, opts model.RegisterOpts) (*model.AuthResult, error) {
+func (s *AuthService) Register(ctx context.Context, opts model.RegisterOpts) (_ *model.AuthResult, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Register")
+	defer tracing.End(span, &err)
+
 	exist, _ := s.userRepo.FindByEmail(ctx, opts.Email)
 	if exist != nil {
 		logx.Error().Msg("user have already exists")
 		return nil, errx.M(http.StatusBadRequest, "user have already exists")
 	}
 
+	_, hashSpan := tracer.Start(ctx, "bcrypt.GenerateFromPassword")
 	newUser, err := model.NewCreateUser(model.CreateUserOpts(opts))
+	hashSpan.End()
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to create user option")
 		return nil, err
 	}
 
-	user, err := s.userRepo.Create(ctx, newUser)
+	// the user is only kept together with its first login and when the tokens could be issued
+	var result *model.AuthResult
+	err = s.unitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.Create(ctx, newUser)
+		if err != nil {
+			logx.Error().Err(err).Msg("failed to create new user")
+			return err
+		}
+
+		if err := s.loginHistory.Record(ctx, s.newLoginEvent(ctx, user, model.LoginMethodRegister, "", user.CreatedAt())); err != nil {
+			logx.Error().Err(err).Msgf("failed to record the first login of the user with id: %s", user.ID())
+			return err
+		}
+
+		atk, err := s.generateAccessToken(ctx, user)
+		if err != nil {
+			logx.Error().Err(err).Msg("failed to generate access token")
+			return err
+		}
+
+		rtk, err := s.generateRefreshToken(ctx, user)
+		if err != nil {
+			logx.Error().Err(err).Msg("failed to generate refresh token")
+			return err
+		}
+
+		result = &model.AuthResult{
+			AccessToken:  atk,
+			RefreshToken: rtk,
+			User:         user,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(ctx, model.UserRegistered{User: result.User.Clone(), At: result.User.CreatedAt()})
+	client := model.ClientInfoFrom(ctx)
+	s.events.Publish(ctx, model.UserLoggedIn{
+		UserID:    result.User.ID(),
+		Method:    model.LoginMethodRegister,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		At:        result.User.CreatedAt(),
+	})
+	return result, nil
+}
+
+func (s *AuthService) ReplayRegister(ctx context.Context, userID string) (_ *model.AuthResult, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ReplayRegister")
+	defer tracing.End(span, &err)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
-		logx.Error().Err(err).Msg("failed to create new user")
+		logx.Error().Err(err).Msgf("failed to find the registered user with id: %s", userID)
 		return nil, err
 	}
 
-	atk, err := s.generateAccessToken(user)
+	// the account may have been suspended since it was created
+	if err := user.CanSignIn(); err != nil {
+		logx.Error().Err(err).Msgf("registered user with id: %s is not allowed to sign in", userID)
+		return nil, err
+	}
+
+	atk, err := s.generateAccessToken(ctx, user)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to generate access token")
 		return nil, err
 	}
 
-	rtk, err := s.generateRefreshToken(user)
+	rtk, err := s.generateRefreshToken(ctx, user)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to generate refresh token")
 		return nil, err
@@ -70,30 +177,48 @@ func (s *AuthService) Register(ctx context.Context, opts model.RegisterOpts) (*m
 	}, nil
 }
 
-func (s *AuthService) Login(ctx context.Context, opts model.LoginOpts) (*model.AuthResult, error) {
+func (s *AuthService) Login(ctx context.Context, opts model.LoginOpts) (_ *model.AuthResult, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	defer tracing.End(span, &err)
+
 	user, err := s.userRepo.FindByEmail(ctx, opts.Email)
 	if err != nil {
+		loginAttempts.WithLabelValues("failure").Inc()
 		logx.Error().Err(err).Msg("failed to find user by email")
 		return nil, err
 	}
 
-	if err := user.ComparePassword(opts.Password); err != nil {
+	_, compareSpan := tracer.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err = user.ComparePassword(opts.Password)
+	compareSpan.End()
+	if err != nil {
+		loginAttempts.WithLabelValues("failure").Inc()
 		logx.Error().Err(err).Msg("invalid user password")
+		s.recordLogin(ctx, user, model.LoginMethodPassword, model.LoginFailureInvalidPassword)
+		return nil, err
+	}
+
+	if err := user.CanSignIn(); err != nil {
+		loginAttempts.WithLabelValues("blocked").Inc()
+		logx.Error().Err(err).Msgf("user with id: %s is not allowed to sign in", user.ID())
+		s.recordLogin(ctx, user, model.LoginMethodPassword, string(user.Status()))
 		return nil, err
 	}
 
-	atk, err := s.generateAccessToken(user)
+	atk, err := s.generateAccessToken(ctx, user)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to generate access token")
 		return nil, err
 	}
 
-	rtk, err := s.generateRefreshToken(user)
+	rtk, err := s.generateRefreshToken(ctx, user)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to generate refresh token")
 		return nil, err
 	}
 
+	loginAttempts.WithLabelValues("success").Inc()
+	s.recordLogin(ctx, user, model.LoginMethodPassword, "")
 	return &model.AuthResult{
 		AccessToken:  atk,
 		RefreshToken: rtk,
@@ -101,9 +226,14 @@ func (s *AuthService) Login(ctx context.Context, opts model.LoginOpts) (*model.A
 	}, nil
 }
 
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*model.AuthResult, error) {
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (_ *model.AuthResult, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.RefreshToken")
+	defer tracing.End(span, &err)
+
 	claims := &model.RefreshTokenClaims{}
+	_, verifySpan := tracer.Start(ctx, "jwt.Verify")
 	token, err := s.tokenManager.VerifyTokenWithClaims(refreshToken, claims)
+	verifySpan.End()
 	if err != nil {
 		return nil, err
 	}
@@ -119,21 +249,131 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*m
 		return nil, err
 	}
 
-	atk, err := s.generateAccessToken(user)
+	if err := user.CanSignIn(); err != nil {
+		logx.Error().Err(err).Msgf("user with id: %s is not allowed to refresh tokens", user.ID())
+		s.recordLogin(ctx, user, model.LoginMethodRefresh, string(user.Status()))
+		return nil, err
+	}
+
+	atk, err := s.generateAccessToken(ctx, user)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to generate access token")
 		return nil, err
 	}
 
+	s.recordLogin(ctx, user, model.LoginMethodRefresh, "")
 	return &model.AuthResult{
 		AccessToken:  atk,
 		RefreshToken: refreshToken,
 	}, nil
 }
 
-func (s *AuthService) generateAccessToken(user *model.User) (string, error) {
+func (s *AuthService) Restore(ctx context.Context, opts model.LoginOpts) (_ *model.AuthResult, err error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Restore")
+	defer tracing.End(span, &err)
+
+	user, err := s.userRepo.FindDeletedByEmail(ctx, opts.Email)
+	if err != nil {
+		logx.Error().Err(err).Msg("failed to find deleted user by email")
+		return nil, err
+	}
+
+	_, compareSpan := tracer.Start(ctx, "bcrypt.CompareHashAndPassword")
+	err = user.ComparePassword(opts.Password)
+	compareSpan.End()
+	if err != nil {
+		logx.Error().Err(err).Msg("invalid user password")
+		s.recordLogin(ctx, user, model.LoginMethodRestore, model.LoginFailureInvalidPassword)
+		return nil, err
+	}
+
+	if !user.Restorable(s.deletionGracePeriod, time.Now()) {
+		logx.Error().Msgf("grace period of the deleted user with id: %s is over", user.ID())
+		return nil, ErrRestoreExpired
+	}
+
+	if err := user.CanSignIn(); err != nil {
+		logx.Error().Err(err).Msgf("deleted user with id: %s is not allowed to sign in", user.ID())
+		s.recordLogin(ctx, user, model.LoginMethodRestore, string(user.Status()))
+		return nil, err
+	}
+
+	if err := s.userRepo.Restore(ctx, user.ID()); err != nil {
+		logx.Error().Err(err).Msgf("failed to restore the user with id: %s", user.ID())
+		return nil, err
+	}
+
+	user, err = s.userRepo.FindByID(ctx, user.ID())
+	if err != nil {
+		logx.Error().Err(err).Msg("failed to find restored user by id")
+		return nil, err
+	}
+
+	atk, err := s.generateAccessToken(ctx, user)
+	if err != nil {
+		logx.Error().Err(err).Msg("failed to generate access token")
+		return nil, err
+	}
+
+	rtk, err := s.generateRefreshToken(ctx, user)
+	if err != nil {
+		logx.Error().Err(err).Msg("failed to generate refresh token")
+		return nil, err
+	}
+
+	s.recordLogin(ctx, user, model.LoginMethodRestore, "")
+	return &model.AuthResult{
+		AccessToken:  atk,
+		RefreshToken: rtk,
+		User:         user,
+	}, nil
+}
+
+// recordLogin keeps track of a sign-in attempt of a known user, failure is empty when it succeeded.
+// Tracking is best effort and never fails the sign-in itself.
+func (s *AuthService) recordLogin(ctx context.Context, user *model.User, method model.LoginMethod, failure string) {
 	now := time.Now()
-	return s.tokenManager.SignClaims(&model.AccessTokenClaims{
+	client := model.ClientInfoFrom(ctx)
+	if failure == "" {
+		if err := s.userRepo.RecordLogin(ctx, user.ID(), now); err != nil {
+			logx.Error().Err(err).Msgf("failed to record the last login of the user with id: %s", user.ID())
+		}
+
+		s.events.Publish(ctx, model.UserLoggedIn{
+			UserID:    user.ID(),
+			Method:    method,
+			IP:        client.IP,
+			UserAgent: client.UserAgent,
+			At:        now,
+		})
+	}
+
+	if err := s.loginHistory.Record(ctx, s.newLoginEvent(ctx, user, method, failure, now)); err != nil {
+		logx.Error().Err(err).Msgf("failed to record a login of the user with id: %s", user.ID())
+	}
+}
+
+// newLoginEvent describes a sign-in of user by the client of ctx, kept for the login history retention
+func (s *AuthService) newLoginEvent(ctx context.Context, user *model.User, method model.LoginMethod, failure string, at time.Time) model.LoginEvent {
+	client := model.ClientInfoFrom(ctx)
+	return model.LoginEvent{
+		UserID:    user.ID(),
+		Method:    method,
+		Success:   failure == "",
+		Reason:    failure,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		At:        at,
+		ExpiresAt: at.Add(s.loginHistoryRetention),
+	}
+}
+
+func (s *AuthService) generateAccessToken(ctx context.Context, user *model.User) (string, error) {
+	_, span := tracer.Start(ctx, "jwt.Sign", trace.WithAttributes(attribute.String("token.type", model.AccessToken.String())))
+	defer span.End()
+
+	now := time.Now()
+	token, err := s.tokenManager.SignClaims(&model.AccessTokenClaims{
 		Name:  user.Name(),
 		Email: user.Email(),
 		Type:  model.AccessToken,
@@ -144,11 +384,20 @@ func (s *AuthService) generateAccessToken(user *model.User) (string, error) {
 			ExpiresAt: s.tokenManager.AccessTokenExpiresAt(now),
 		},
 	})
+	if err != nil {
+		return "", err
+	}
+
+	tokensIssued.WithLabelValues(model.AccessToken.String()).Inc()
+	return token, nil
 }
 
-func (s *AuthService) generateRefreshToken(user *model.User) (string, error) {
+func (s *AuthService) generateRefreshToken(ctx context.Context, user *model.User) (string, error) {
+	_, span := tracer.Start(ctx, "jwt.Sign", trace.WithAttributes(attribute.String("token.type", model.RefreshToken.String())))
+	defer span.End()
+
 	now := time.Now()
-	return s.tokenManager.SignClaims(&model.RefreshTokenClaims{
+	token, err := s.tokenManager.SignClaims(&model.RefreshTokenClaims{
 		Type: model.RefreshToken,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.NewString(),
@@ -157,4 +406,10 @@ func (s *AuthService) generateRefreshToken(user *model.User) (string, error) {
 			ExpiresAt: s.tokenManager.RefreshTokenExpiresAt(now),
 		},
 	})
+	if err != nil {
+		return "", err
+	}
+
+	tokensIssued.WithLabelValues(model.RefreshToken.String()).Inc()
+	return token, nil
 }
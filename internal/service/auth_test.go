@@ -8,12 +8,16 @@ import (
 
 	"github.com/chai-rs/sevenhunter/internal/model"
 	"github.com/chai-rs/sevenhunter/internal/model/mocks"
+	"github.com/chai-rs/sevenhunter/internal/repo"
 	errx "github.com/chai-rs/sevenhunter/pkg/error"
 	jwtx "github.com/chai-rs/sevenhunter/pkg/jwt"
 	. "github.com/chai-rs/sevenhunter/pkg/testutil"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
 )
 
 // createTestTokenManager creates a TokenManager instance for testing
@@ -51,6 +55,37 @@ func createTestUserWithPassword(id, name, email, password string) *model.User {
 	return userWithID
 }
 
+// newTestAuthUserRepo lets the service record the last login, which the sign-in tests do not check
+func newTestAuthUserRepo(t *testing.T) *mocks.MockUserRepo {
+	repo := mocks.NewMockUserRepo(t)
+	repo.EXPECT().RecordLogin(mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return repo
+}
+
+// newTestLoginHistory accepts every login event, which the sign-in tests do not check
+func newTestLoginHistory(t *testing.T) *mocks.MockLoginHistoryRepo {
+	history := mocks.NewMockLoginHistoryRepo(t)
+	history.EXPECT().Record(mock.Anything, mock.Anything).Return(nil).Maybe()
+	return history
+}
+
+// withStatus copies a test user into the given account status
+func withStatus(user *model.User, status model.UserStatus) *model.User {
+	copied, err := model.NewUser(model.UserOpts{
+		ID:             user.ID(),
+		Name:           user.Name(),
+		Email:          user.Email(),
+		HashedPassword: user.HashedPassword(),
+		Status:         status,
+		CreatedAt:      user.CreatedAt(),
+	})
+	if err != nil {
+		panic("Failed to create test user with status: " + err.Error())
+	}
+
+	return copied
+}
+
 func TestAuthService_Register(t *testing.T) {
 	type Testcase struct {
 		name     string
@@ -94,6 +129,30 @@ func TestAuthService_Register(t *testing.T) {
 			},
 			isError: false,
 		},
+		{
+			name: "register normalizes the email",
+			input: model.RegisterOpts{
+				Name:     "John Doe",
+				Email:    " John@Example.COM ",
+				Password: "password123",
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.RegisterOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByEmail(mock.Anything, input.Email).Return(nil, mongo.ErrNoDocuments)
+				repo.EXPECT().Create(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Email() == "John@example.com" && u.EmailCanonical() == "john@example.com"
+				})).RunAndReturn(func(ctx context.Context, u *model.User) (*model.User, error) {
+					return createTestUserWithPassword("123", u.Name(), u.Email(), input.Password), nil
+				})
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "John@example.com", result.User.Email())
+			},
+			isError: false,
+		},
 		{
 			name: "register fails when user already exists",
 			input: model.RegisterOpts{
@@ -179,7 +238,8 @@ func TestAuthService_Register(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := NewAuthService(&AuthServiceOpts{
 				TokenManager: createTestTokenManager(),
-				UserRepo:     mocks.NewMockUserRepo(t),
+				UserRepo:     newTestAuthUserRepo(t),
+				LoginHistory: newTestLoginHistory(t),
 			})
 
 			if tc.arrange != nil {
@@ -268,6 +328,42 @@ func TestAuthService_Login(t *testing.T) {
 			},
 			isError: true,
 		},
+		{
+			name: "login fails when the account is suspended",
+			input: model.LoginOpts{
+				Email:    "john@example.com",
+				Password: testPassword,
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.LoginOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByEmail(mock.Anything, input.Email).Return(withStatus(testUser, model.UserStatusSuspended), nil)
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.ErrorIs(t, err, model.ErrUserSuspended)
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
+		{
+			name: "login with a wrong password does not reveal the account status",
+			input: model.LoginOpts{
+				Email:    "john@example.com",
+				Password: "wrongpassword",
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.LoginOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByEmail(mock.Anything, input.Email).Return(withStatus(testUser, model.UserStatusLocked), nil)
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "invalid email or password")
+			},
+			isError: true,
+		},
 		{
 			name: "login fails with repository error",
 			input: model.LoginOpts{
@@ -292,7 +388,8 @@ func TestAuthService_Login(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := NewAuthService(&AuthServiceOpts{
 				TokenManager: createTestTokenManager(),
-				UserRepo:     mocks.NewMockUserRepo(t),
+				UserRepo:     newTestAuthUserRepo(t),
+				LoginHistory: newTestLoginHistory(t),
 			})
 
 			if tc.arrange != nil {
@@ -326,7 +423,7 @@ func TestAuthService_RefreshToken(t *testing.T) {
 			TokenManager: tokenManager,
 			UserRepo:     nil, // Won't be used for token generation
 		})
-		return s.generateRefreshToken(testUser)
+		return s.generateRefreshToken(context.Background(), testUser)
 	}()
 	require.NoError(t, err)
 
@@ -388,6 +485,21 @@ func TestAuthService_RefreshToken(t *testing.T) {
 			},
 			isError: true,
 		},
+		{
+			name:  "refresh token fails when the account is locked",
+			input: validRefreshToken,
+			arrange: func(t *testing.T, service *AuthService, input string) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, testUser.ID()).Return(withStatus(testUser, model.UserStatusLocked), nil)
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.ErrorIs(t, err, model.ErrUserLocked)
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
 		{
 			name:  "refresh token fails with repository error",
 			input: validRefreshToken,
@@ -409,7 +521,8 @@ func TestAuthService_RefreshToken(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := NewAuthService(&AuthServiceOpts{
 				TokenManager: tokenManager,
-				UserRepo:     mocks.NewMockUserRepo(t),
+				UserRepo:     newTestAuthUserRepo(t),
+				LoginHistory: newTestLoginHistory(t),
 			})
 
 			if tc.arrange != nil {
@@ -425,6 +538,133 @@ func TestAuthService_RefreshToken(t *testing.T) {
 	}
 }
 
+func TestAuthService_Restore(t *testing.T) {
+	type Testcase struct {
+		name     string
+		input    model.LoginOpts
+		arrange  ArrangeFn[*AuthService, model.LoginOpts]
+		validate func(t *testing.T, result *model.AuthResult, err error)
+		isError  bool
+	}
+
+	testPassword := "password123"
+	testUser := createTestUserWithPassword("123", "John Doe", "john@example.com", testPassword)
+	deletedUser := func(deletedAt time.Time) *model.User {
+		user, err := model.NewUser(model.UserOpts{
+			ID:             testUser.ID(),
+			Name:           testUser.Name(),
+			Email:          testUser.Email(),
+			HashedPassword: testUser.HashedPassword(),
+			CreatedAt:      testUser.CreatedAt(),
+			DeletedAt:      deletedAt,
+		})
+		require.NoError(t, err)
+		return user
+	}
+
+	testcases := []Testcase{
+		{
+			name: "restore user successfully within the grace period",
+			input: model.LoginOpts{
+				Email:    "john@example.com",
+				Password: testPassword,
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.LoginOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindDeletedByEmail(mock.Anything, input.Email).Return(deletedUser(time.Now().Add(-time.Hour)), nil)
+				repo.EXPECT().Restore(mock.Anything, "123").Return(nil)
+				repo.EXPECT().FindByID(mock.Anything, "123").Return(testUser, nil)
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.NoError(t, err)
+				require.NotNil(t, result)
+				require.NotEmpty(t, result.AccessToken)
+				require.NotEmpty(t, result.RefreshToken)
+				require.False(t, result.User.IsDeleted())
+			},
+			isError: false,
+		},
+		{
+			name: "restore fails after the grace period",
+			input: model.LoginOpts{
+				Email:    "john@example.com",
+				Password: testPassword,
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.LoginOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindDeletedByEmail(mock.Anything, input.Email).Return(deletedUser(time.Now().Add(-48*time.Hour)), nil)
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.ErrorIs(t, err, ErrRestoreExpired)
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
+		{
+			name: "restore fails with wrong password",
+			input: model.LoginOpts{
+				Email:    "john@example.com",
+				Password: "wrongpassword",
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.LoginOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindDeletedByEmail(mock.Anything, input.Email).Return(deletedUser(time.Now().Add(-time.Hour)), nil)
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.Error(t, err)
+				require.Nil(t, result)
+				require.Contains(t, err.Error(), "invalid email or password")
+			},
+			isError: true,
+		},
+		{
+			name: "restore fails when no deleted user has the email",
+			input: model.LoginOpts{
+				Email:    "john@example.com",
+				Password: testPassword,
+			},
+			arrange: func(t *testing.T, service *AuthService, input model.LoginOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindDeletedByEmail(mock.Anything, input.Email).Return(nil, errx.Mongo(mongo.ErrNoDocuments))
+			},
+			validate: func(t *testing.T, result *model.AuthResult, err error) {
+				require.Error(t, err)
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewAuthService(&AuthServiceOpts{
+				TokenManager:        createTestTokenManager(),
+				UserRepo:            newTestAuthUserRepo(t),
+				LoginHistory:        newTestLoginHistory(t),
+				DeletionGracePeriod: 24 * time.Hour,
+			})
+
+			if tc.arrange != nil {
+				tc.arrange(t, s, tc.input)
+			}
+
+			result, err := s.Restore(context.Background(), tc.input)
+
+			if tc.validate != nil {
+				tc.validate(t, result, err)
+			}
+		})
+	}
+}
+
 func TestAuthService_GenerateAccessToken(t *testing.T) {
 	tokenManager := createTestTokenManager()
 	service := NewAuthService(&AuthServiceOpts{
@@ -434,7 +674,7 @@ func TestAuthService_GenerateAccessToken(t *testing.T) {
 
 	testUser := createTestUserWithPassword("123", "John Doe", "john@example.com", "password123")
 
-	token, err := service.generateAccessToken(testUser)
+	token, err := service.generateAccessToken(context.Background(), testUser)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 
@@ -460,7 +700,7 @@ func TestAuthService_GenerateRefreshToken(t *testing.T) {
 
 	testUser := createTestUserWithPassword("123", "John Doe", "john@example.com", "password123")
 
-	token, err := service.generateRefreshToken(testUser)
+	token, err := service.generateRefreshToken(context.Background(), testUser)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 
@@ -474,3 +714,219 @@ func TestAuthService_GenerateRefreshToken(t *testing.T) {
 	require.Equal(t, testUser.ID(), claims.Subject)
 	require.Equal(t, model.RefreshToken, claims.Type)
 }
+
+func TestAuthService_Login_RecordsHistory(t *testing.T) {
+	testPassword := "password123"
+	testUser := createTestUserWithPassword("123", "John Doe", "john@example.com", testPassword)
+	client := model.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8.0"}
+	ctx := model.WithClientInfo(context.Background(), client)
+
+	t.Run("records a successful login", func(t *testing.T) {
+		repo := mocks.NewMockUserRepo(t)
+		repo.EXPECT().FindByEmail(mock.Anything, testUser.Email()).Return(testUser, nil)
+		repo.EXPECT().RecordLogin(mock.Anything, testUser.ID(), mock.Anything).Return(nil)
+
+		history := mocks.NewMockLoginHistoryRepo(t)
+		history.EXPECT().Record(mock.Anything, mock.MatchedBy(func(event model.LoginEvent) bool {
+			return event.UserID == testUser.ID() &&
+				event.Method == model.LoginMethodPassword &&
+				event.Success &&
+				event.Reason == "" &&
+				event.IP == client.IP &&
+				event.UserAgent == client.UserAgent &&
+				event.ExpiresAt.Sub(event.At) == DefaultLoginHistoryRetention
+		})).Return(nil)
+
+		s := NewAuthService(&AuthServiceOpts{
+			TokenManager: createTestTokenManager(),
+			UserRepo:     repo,
+			LoginHistory: history,
+		})
+
+		_, err := s.Login(ctx, model.LoginOpts{Email: testUser.Email(), Password: testPassword})
+		require.NoError(t, err)
+	})
+
+	t.Run("records a failed login without touching the last login", func(t *testing.T) {
+		repo := mocks.NewMockUserRepo(t)
+		repo.EXPECT().FindByEmail(mock.Anything, testUser.Email()).Return(testUser, nil)
+
+		history := mocks.NewMockLoginHistoryRepo(t)
+		history.EXPECT().Record(mock.Anything, mock.MatchedBy(func(event model.LoginEvent) bool {
+			return event.UserID == testUser.ID() &&
+				!event.Success &&
+				event.Reason == model.LoginFailureInvalidPassword &&
+				event.IP == client.IP
+		})).Return(nil)
+
+		s := NewAuthService(&AuthServiceOpts{
+			TokenManager: createTestTokenManager(),
+			UserRepo:     repo,
+			LoginHistory: history,
+		})
+
+		_, err := s.Login(ctx, model.LoginOpts{Email: testUser.Email(), Password: "wrong-password"})
+		require.Error(t, err)
+	})
+
+	t.Run("ignores history errors", func(t *testing.T) {
+		repo := mocks.NewMockUserRepo(t)
+		repo.EXPECT().FindByEmail(mock.Anything, testUser.Email()).Return(testUser, nil)
+		repo.EXPECT().RecordLogin(mock.Anything, testUser.ID(), mock.Anything).Return(errors.New("database error"))
+
+		history := mocks.NewMockLoginHistoryRepo(t)
+		history.EXPECT().Record(mock.Anything, mock.Anything).Return(errors.New("database error"))
+
+		s := NewAuthService(&AuthServiceOpts{
+			TokenManager: createTestTokenManager(),
+			UserRepo:     repo,
+			LoginHistory: history,
+		})
+
+		result, err := s.Login(ctx, model.LoginOpts{Email: testUser.Email(), Password: testPassword})
+		require.NoError(t, err)
+		require.NotEmpty(t, result.AccessToken)
+	})
+}
+
+func TestAuthService_MemoryRepo(t *testing.T) {
+	ctx := context.Background()
+	userRepo := repo.NewMemoryUserRepo()
+	loginHistory := repo.NewMemoryLoginHistoryRepo()
+	unitOfWork := repo.NewMemoryUnitOfWork()
+	events := &eventRecorder{}
+
+	auth := NewAuthService(&AuthServiceOpts{
+		TokenManager: createTestTokenManager(),
+		UserRepo:     userRepo,
+		LoginHistory: loginHistory,
+		UnitOfWork:   unitOfWork,
+		Events:       events,
+	})
+	users := NewUserService(UserServiceOpts{
+		UserRepo:     userRepo,
+		LoginHistory: loginHistory,
+		UnitOfWork:   unitOfWork,
+		Events:       events,
+	})
+
+	registered, err := auth.Register(ctx, model.RegisterOpts{Name: "John Doe", Email: "john@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	_, err = auth.Register(ctx, model.RegisterOpts{Name: "John Again", Email: "John@example.com", Password: "password123"})
+	require.Error(t, err)
+
+	_, err = auth.Login(ctx, model.LoginOpts{Email: "john@example.com", Password: "wrong-password"})
+	require.Error(t, err)
+
+	loggedIn, err := auth.Login(ctx, model.LoginOpts{Email: "john@example.com", Password: "password123"})
+	require.NoError(t, err)
+	require.Equal(t, registered.User.ID(), loggedIn.User.ID())
+
+	_, err = auth.RefreshToken(ctx, loggedIn.RefreshToken)
+	require.NoError(t, err)
+
+	user, err := users.Update(ctx, model.UpdateUserOpts{ID: registered.User.ID(), Name: lo.ToPtr("Johnny")})
+	require.NoError(t, err)
+	require.Equal(t, "Johnny", user.Name())
+	require.False(t, user.LastLoginAt().IsZero())
+
+	page, err := users.ListLogins(ctx, model.ListLoginOpts{UserID: user.ID()})
+	require.NoError(t, err)
+	require.Equal(t, []model.LoginMethod{model.LoginMethodRefresh, model.LoginMethodPassword, model.LoginMethodPassword, model.LoginMethodRegister},
+		lo.Map(page.Events, func(e model.LoginEvent, _ int) model.LoginMethod { return e.Method }))
+	require.False(t, page.Events[2].Success)
+
+	require.NoError(t, users.Delete(ctx, user.ID()))
+	_, err = auth.Login(ctx, model.LoginOpts{Email: "john@example.com", Password: "password123"})
+	require.Error(t, err)
+
+	// failed attempts publish nothing
+	require.Equal(t, []string{
+		"user.registered", "user.logged_in", "user.logged_in", "user.logged_in", "user.updated", "user.deleted",
+	}, events.names())
+	require.Equal(t, model.LoginMethodRegister, events.events[1].(model.UserLoggedIn).Method)
+	require.Equal(t, model.LoginMethodRefresh, events.events[3].(model.UserLoggedIn).Method)
+}
+
+func TestAuthService_RegisterRollback(t *testing.T) {
+	ctx := context.Background()
+	userRepo := repo.NewMemoryUserRepo()
+	loginHistory := mocks.NewMockLoginHistoryRepo(t)
+	loginHistory.EXPECT().Record(mock.Anything, mock.Anything).Return(errors.New("database error"))
+
+	s := NewAuthService(&AuthServiceOpts{
+		TokenManager: createTestTokenManager(),
+		UserRepo:     userRepo,
+		LoginHistory: loginHistory,
+		UnitOfWork:   repo.NewMemoryUnitOfWork(),
+	})
+
+	_, err := s.Register(ctx, model.RegisterOpts{Name: "John Doe", Email: "john@example.com", Password: "password123"})
+	require.Error(t, err)
+
+	// the account is not kept without its first login
+	_, err = userRepo.FindByEmail(ctx, "john@example.com")
+	require.Error(t, err)
+}
+
+func TestAuthService_Login_Spans(t *testing.T) {
+	tp, exporter := tracing.NewInMemory()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	tracing.Install(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	testPassword := "password123"
+	testUser := createTestUserWithPassword("123", "John Doe", "john@example.com", testPassword)
+
+	repo := newTestAuthUserRepo(t)
+	repo.EXPECT().FindByEmail(mock.Anything, testUser.Email()).Return(testUser, nil)
+
+	s := NewAuthService(&AuthServiceOpts{
+		TokenManager: createTestTokenManager(),
+		UserRepo:     repo,
+		LoginHistory: newTestLoginHistory(t),
+	})
+
+	_, err := s.Login(context.Background(), model.LoginOpts{
+		Email:    testUser.Email(),
+		Password: testPassword,
+	})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	require.ElementsMatch(t, []string{"bcrypt.CompareHashAndPassword", "jwt.Sign", "jwt.Sign", "AuthService.Login"}, names)
+
+	root := spans[len(spans)-1]
+	for _, span := range spans[:len(spans)-1] {
+		require.Equal(t, root.SpanContext.SpanID(), span.Parent.SpanID())
+	}
+}
+
+func TestAuthService_ReplayRegister(t *testing.T) {
+	ctx := context.Background()
+	s := NewAuthService(&AuthServiceOpts{
+		TokenManager: createTestTokenManager(),
+		UserRepo:     repo.NewMemoryUserRepo(),
+		LoginHistory: repo.NewMemoryLoginHistoryRepo(),
+	})
+
+	registered, err := s.Register(ctx, model.RegisterOpts{Name: "John Doe", Email: "john@example.com", Password: "password123"})
+	require.NoError(t, err)
+
+	replayed, err := s.ReplayRegister(ctx, registered.User.ID())
+	require.NoError(t, err)
+	require.Equal(t, registered.User.ID(), replayed.User.ID())
+	require.NotEmpty(t, replayed.AccessToken)
+	require.NotEmpty(t, replayed.RefreshToken)
+
+	_, err = s.ReplayRegister(ctx, "000000000000000000000000")
+	require.Error(t, err)
+}
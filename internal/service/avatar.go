@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/pkg/blob"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	DefaultAvatarMaxBytes = 2 << 20
+	avatarJPEGQuality     = 85
+)
+
+var avatarContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+type AvatarService struct {
+	userRepo  model.UserRepo
+	blobs     blob.Store
+	urlPrefix string
+	maxBytes  int64
+}
+
+type AvatarServiceOpts struct {
+	UserRepo model.UserRepo
+	Blobs    blob.Store
+	// URLPrefix is the public URL of the avatar route, the user ID is appended to it
+	URLPrefix string
+	MaxBytes  int64
+}
+
+func NewAvatarService(opts AvatarServiceOpts) *AvatarService {
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultAvatarMaxBytes
+	}
+
+	return &AvatarService{
+		userRepo:  opts.UserRepo,
+		blobs:     opts.Blobs,
+		urlPrefix: opts.URLPrefix,
+		maxBytes:  maxBytes,
+	}
+}
+
+var (
+	_ model.AvatarService  = (*AvatarService)(nil)
+	_ model.UserDataPurger = (*AvatarService)(nil)
+)
+
+func (s *AvatarService) Upload(ctx context.Context, userID string, r io.Reader) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "AvatarService.Upload")
+	defer tracing.End(span, &err)
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		logx.Error().Err(err).Msgf("failed to get the user with id: %s for avatar upload", userID)
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return nil, errx.E(http.StatusBadRequest, err, "failed to read avatar")
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, model.ErrAvatarTooLarge
+	}
+
+	_, decodeSpan := tracer.Start(ctx, "image.Decode")
+	img, err := decodeAvatar(data)
+	decodeSpan.End()
+	if err != nil {
+		logx.Error().Err(err).Msgf("invalid avatar uploaded by the user with id: %s", userID)
+		return nil, err
+	}
+
+	for _, size := range model.AvatarSizes {
+		thumbnail, err := encodeThumbnail(img, size)
+		if err != nil {
+			return nil, err
+		}
+
+		key := model.AvatarKey(userID, size)
+		if err := s.blobs.Put(ctx, key, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err != nil {
+			logx.Error().Err(err).Msgf("failed to store the avatar %s", key)
+			return nil, err
+		}
+	}
+
+	// thumbnails are overwritten in place, the version parameter lets clients cache each upload forever
+	avatarURL := fmt.Sprintf("%s/%s?v=%s", s.urlPrefix, userID, uuid.NewString()[:8])
+	if err := user.Update(model.UpdateUserOpts{ID: userID, AvatarURL: &avatarURL}); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		logx.Error().Err(err).Msgf("failed to save the avatar of the user with id: %s", userID)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *AvatarService) Open(ctx context.Context, userID string, size int) (_ io.ReadCloser, _ *blob.Object, err error) {
+	ctx, span := tracer.Start(ctx, "AvatarService.Open")
+	defer tracing.End(span, &err)
+
+	if !slices.Contains(model.AvatarSizes, size) {
+		return nil, nil, model.ErrAvatarUnsupportedSize
+	}
+
+	// avatars of deleted users are kept until the purge but no longer served
+	exist, err := s.userRepo.ExistsByID(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exist {
+		return nil, nil, blob.ErrNotFound
+	}
+
+	return s.blobs.Get(ctx, model.AvatarKey(userID, size))
+}
+
+// PurgeUserData deletes the avatar thumbnails of a purged user
+func (s *AvatarService) PurgeUserData(ctx context.Context, userID string) error {
+	for _, size := range model.AvatarSizes {
+		if err := s.blobs.Delete(ctx, model.AvatarKey(userID, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeAvatar(data []byte) (image.Image, error) {
+	if !slices.Contains(avatarContentTypes, http.DetectContentType(data)) {
+		return nil, model.ErrAvatarUnsupported
+	}
+
+	// check the dimensions before decoding so a small file cannot expand into a huge bitmap
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, model.ErrAvatarInvalidImage
+	}
+	if config.Width > model.MaxAvatarDimension || config.Height > model.MaxAvatarDimension {
+		return nil, model.ErrAvatarDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, model.ErrAvatarInvalidImage
+	}
+
+	return img, nil
+}
+
+// encodeThumbnail center-crops img to a square and scales it to size as a JPEG.
+// Transparent areas are flattened onto white since JPEG has no alpha channel.
+func encodeThumbnail(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, crop, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: avatarJPEGQuality}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
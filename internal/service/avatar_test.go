@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/chai-rs/sevenhunter/internal/model"
+	"github.com/chai-rs/sevenhunter/internal/model/mocks"
+	"github.com/chai-rs/sevenhunter/pkg/blob"
+	. "github.com/chai-rs/sevenhunter/pkg/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func newTestAvatarService(t *testing.T, maxBytes int64) *AvatarService {
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	return NewAvatarService(AvatarServiceOpts{
+		UserRepo:  mocks.NewMockUserRepo(t),
+		Blobs:     blobs,
+		URLPrefix: "https://api.example.com/v1/api/avatars",
+		MaxBytes:  maxBytes,
+	})
+}
+
+func TestAvatarService_Upload(t *testing.T) {
+	type Testcase struct {
+		name     string
+		input    []byte
+		maxBytes int64
+		arrange  ArrangeFn[*AvatarService, []byte]
+		validate func(t *testing.T, service *AvatarService, result *model.User, err error)
+	}
+
+	expectUser := func(t *testing.T, service *AvatarService, _ []byte) {
+		repo, ok := service.userRepo.(*mocks.MockUserRepo)
+		require.True(t, ok)
+
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+	}
+
+	testcases := []Testcase{
+		{
+			name:  "upload stores square thumbnails and sets the avatar url",
+			input: createTestPNG(t, 300, 200),
+			arrange: func(t *testing.T, service *AvatarService, input []byte) {
+				expectUser(t, service, input)
+
+				repo := service.userRepo.(*mocks.MockUserRepo)
+				repo.EXPECT().Update(mock.Anything, mock.Anything).Return(nil)
+			},
+			validate: func(t *testing.T, service *AvatarService, result *model.User, err error) {
+				require.NoError(t, err)
+				require.True(t, strings.HasPrefix(result.AvatarURL(), "https://api.example.com/v1/api/avatars/123?v="))
+
+				for _, size := range model.AvatarSizes {
+					r, obj, err := service.blobs.Get(context.Background(), model.AvatarKey("123", size))
+					require.NoError(t, err)
+					require.Equal(t, "image/jpeg", obj.ContentType)
+
+					img, err := jpeg.Decode(r)
+					r.Close()
+					require.NoError(t, err)
+					require.Equal(t, image.Rect(0, 0, size, size), img.Bounds())
+				}
+			},
+		},
+		{
+			name:     "upload fails when the file is too large",
+			input:    bytes.Repeat([]byte{0}, 4<<10),
+			maxBytes: 1 << 10,
+			arrange:  expectUser,
+			validate: func(t *testing.T, _ *AvatarService, result *model.User, err error) {
+				require.ErrorIs(t, err, model.ErrAvatarTooLarge)
+				require.Nil(t, result)
+			},
+		},
+		{
+			name:    "upload fails when the file is not an image",
+			input:   []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"),
+			arrange: expectUser,
+			validate: func(t *testing.T, _ *AvatarService, result *model.User, err error) {
+				require.ErrorIs(t, err, model.ErrAvatarUnsupported)
+				require.Nil(t, result)
+			},
+		},
+		{
+			name:    "upload fails when the image is too wide",
+			input:   createTestPNG(t, model.MaxAvatarDimension+1, 1),
+			arrange: expectUser,
+			validate: func(t *testing.T, _ *AvatarService, result *model.User, err error) {
+				require.ErrorIs(t, err, model.ErrAvatarDimensions)
+				require.Nil(t, result)
+			},
+		},
+		{
+			name:    "upload fails when the image is corrupted",
+			input:   createTestPNG(t, 64, 64)[:100],
+			arrange: expectUser,
+			validate: func(t *testing.T, _ *AvatarService, result *model.User, err error) {
+				require.ErrorIs(t, err, model.ErrAvatarInvalidImage)
+				require.Nil(t, result)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestAvatarService(t, tc.maxBytes)
+
+			if tc.arrange != nil {
+				tc.arrange(t, s, tc.input)
+			}
+
+			result, err := s.Upload(context.Background(), "123", bytes.NewReader(tc.input))
+			tc.validate(t, s, result, err)
+		})
+	}
+}
+
+func TestAvatarService_Open(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("serves the thumbnail of a live user", func(t *testing.T) {
+		s := newTestAvatarService(t, 0)
+		require.NoError(t, s.blobs.Put(ctx, model.AvatarKey("123", 64), strings.NewReader("jpeg"), 4, "image/jpeg"))
+		s.userRepo.(*mocks.MockUserRepo).EXPECT().ExistsByID(mock.Anything, "123").Return(true, nil)
+
+		r, obj, err := s.Open(ctx, "123", 64)
+		require.NoError(t, err)
+		defer r.Close()
+
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, "jpeg", string(body))
+		require.Equal(t, int64(4), obj.Size)
+	})
+
+	t.Run("hides the avatar of a deleted user", func(t *testing.T) {
+		s := newTestAvatarService(t, 0)
+		require.NoError(t, s.blobs.Put(ctx, model.AvatarKey("123", 64), strings.NewReader("jpeg"), 4, "image/jpeg"))
+		s.userRepo.(*mocks.MockUserRepo).EXPECT().ExistsByID(mock.Anything, "123").Return(false, nil)
+
+		_, _, err := s.Open(ctx, "123", 64)
+		require.ErrorIs(t, err, blob.ErrNotFound)
+	})
+
+	t.Run("rejects sizes that are not generated", func(t *testing.T) {
+		s := newTestAvatarService(t, 0)
+
+		_, _, err := s.Open(ctx, "123", 512)
+		require.ErrorIs(t, err, model.ErrAvatarUnsupportedSize)
+	})
+
+	t.Run("purge deletes every thumbnail", func(t *testing.T) {
+		s := newTestAvatarService(t, 0)
+		for _, size := range model.AvatarSizes {
+			require.NoError(t, s.blobs.Put(ctx, model.AvatarKey("123", size), strings.NewReader("jpeg"), 4, "image/jpeg"))
+		}
+
+		require.NoError(t, s.PurgeUserData(ctx, "123"))
+		for _, size := range model.AvatarSizes {
+			_, _, err := s.blobs.Get(ctx, model.AvatarKey("123", size))
+			require.ErrorIs(t, err, blob.ErrNotFound)
+		}
+	})
+}
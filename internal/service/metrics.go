@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/chai-rs/sevenhunter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	loginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "auth",
+		Name:      "login_total",
+		Help:      "Total number of login attempts by result.",
+	}, []string{"result"})
+
+	tokensIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "auth",
+		Name:      "tokens_issued_total",
+		Help:      "Total number of issued tokens by type.",
+	}, []string{"type"})
+)
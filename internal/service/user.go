@@ -2,28 +2,86 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
 
 	"github.com/chai-rs/sevenhunter/internal/model"
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	jwtx "github.com/chai-rs/sevenhunter/pkg/jwt"
 	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/chai-rs/sevenhunter/pkg/mail"
+	"github.com/chai-rs/sevenhunter/pkg/tracing"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+const DefaultEmailChangeTTL = 24 * time.Hour
+
 type UserService struct {
-	userRepo model.UserRepo
+	userRepo        model.UserRepo
+	loginHistory    model.LoginHistoryRepo
+	unitOfWork      model.UnitOfWork
+	events          model.EventPublisher
+	tokenManager    *jwtx.TokenManager
+	mailer          mail.Mailer
+	emailChangeTTL  time.Duration
+	emailConfirmURL string
 }
 
 type UserServiceOpts struct {
-	UserRepo model.UserRepo
+	UserRepo     model.UserRepo
+	LoginHistory model.LoginHistoryRepo
+	TokenManager *jwtx.TokenManager
+	Mailer       mail.Mailer
+	// UnitOfWork makes the writes of an operation atomic, model.WithoutTransaction when nil
+	UnitOfWork model.UnitOfWork
+	// Events receives the domain events of the operations, model.NoEvents when nil
+	Events model.EventPublisher
+	// EmailChangeTTL is how long an email change can be confirmed, DefaultEmailChangeTTL when zero
+	EmailChangeTTL time.Duration
+	// EmailConfirmURL is the page that confirms an email change, the token is added as a query parameter.
+	// The confirmation email only contains the token when it is empty.
+	EmailConfirmURL string
 }
 
 func NewUserService(opts UserServiceOpts) *UserService {
+	emailChangeTTL := opts.EmailChangeTTL
+	if emailChangeTTL <= 0 {
+		emailChangeTTL = DefaultEmailChangeTTL
+	}
+
+	unitOfWork := opts.UnitOfWork
+	if unitOfWork == nil {
+		unitOfWork = model.WithoutTransaction{}
+	}
+
+	events := opts.Events
+	if events == nil {
+		events = model.NoEvents{}
+	}
+
 	return &UserService{
-		userRepo: opts.UserRepo,
+		userRepo:        opts.UserRepo,
+		loginHistory:    opts.LoginHistory,
+		unitOfWork:      unitOfWork,
+		events:          events,
+		tokenManager:    opts.TokenManager,
+		mailer:          opts.Mailer,
+		emailChangeTTL:  emailChangeTTL,
+		emailConfirmURL: opts.EmailConfirmURL,
 	}
 }
 
 var _ model.UserService = (*UserService)(nil)
 
-func (s *UserService) Count(ctx context.Context) (int64, error) {
+func (s *UserService) Count(ctx context.Context) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Count")
+	defer tracing.End(span, &err)
+
 	count, err := s.userRepo.Count(ctx)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to count the users")
@@ -33,17 +91,28 @@ func (s *UserService) Count(ctx context.Context) (int64, error) {
 	return count, nil
 }
 
-func (s *UserService) List(ctx context.Context, opts model.ListUserOpts) ([]model.User, error) {
-	users, err := s.userRepo.List(ctx, opts)
+func (s *UserService) List(ctx context.Context, opts model.ListUserOpts) (_ *model.UserPage, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.List")
+	defer tracing.End(span, &err)
+
+	if err := opts.Validate(); err != nil {
+		logx.Error().Err(err).Msg("invalid options to list the users")
+		return nil, err
+	}
+
+	page, err := s.userRepo.List(ctx, opts)
 	if err != nil {
 		logx.Error().Err(err).Msg("failed to list the users")
 		return nil, err
 	}
 
-	return users, nil
+	return page, nil
 }
 
-func (s *UserService) Get(ctx context.Context, id string) (*model.User, error) {
+func (s *UserService) Get(ctx context.Context, id string) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Get")
+	defer tracing.End(span, &err)
+
 	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
 		logx.Error().Err(err).Msgf("failed to get the user with id: %s", id)
@@ -53,7 +122,10 @@ func (s *UserService) Get(ctx context.Context, id string) (*model.User, error) {
 	return user, nil
 }
 
-func (s *UserService) Update(ctx context.Context, opts model.UpdateUserOpts) (*model.User, error) {
+func (s *UserService) Update(ctx context.Context, opts model.UpdateUserOpts) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Update")
+	defer tracing.End(span, &err)
+
 	user, err := s.Get(ctx, opts.ID)
 	if err != nil {
 		logx.Error().Err(err).Msgf("failed to get the user with id: %s for update", opts.ID)
@@ -65,20 +137,210 @@ func (s *UserService) Update(ctx context.Context, opts model.UpdateUserOpts) (*m
 		return nil, err
 	}
 
+	// asking again for the same address sends a new confirmation, e.g. when the first one got lost
+	emailRequested := opts.Email != nil && user.PendingEmail() != "" && model.NormalizeEmail(*opts.Email) == user.PendingEmail()
+	if emailRequested {
+		if err := s.checkEmailAvailable(ctx, user.PendingEmail()); err != nil {
+			logx.Error().Err(err).Msgf("email requested by the user with id: %s is not available", opts.ID)
+			return nil, err
+		}
+	}
+
+	changes := slices.Clone(user.Changes())
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		logx.Error().Err(err).Msgf("failed to save the updated user with id: %s", opts.ID)
 		return nil, err
 	}
+	s.publishUpdated(ctx, user, changes)
+
+	if emailRequested {
+		if err := s.sendEmailChange(ctx, user); err != nil {
+			logx.Error().Err(err).Msgf("failed to send the email change confirmation for the user with id: %s", opts.ID)
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+func (s *UserService) ConfirmEmail(ctx context.Context, token string) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ConfirmEmail")
+	defer tracing.End(span, &err)
+
+	claims := &model.EmailChangeClaims{}
+	if _, err := s.tokenManager.VerifyTokenWithClaims(token, claims); err != nil || claims.Type != model.EmailChangeToken {
+		logx.Error().Err(err).Msg("invalid email change token")
+		return nil, model.ErrEmailChangeInvalid
+	}
+
+	user, err := s.userRepo.FindByID(ctx, claims.Subject)
+	if err != nil {
+		logx.Error().Err(err).Msgf("failed to get the user with id: %s to confirm the email", claims.Subject)
+		return nil, err
+	}
+
+	// a newer request or an earlier confirmation replaced the pending email this token was issued for
+	if err := user.ConfirmEmail(claims.Email); err != nil {
+		logx.Error().Err(err).Msgf("email change of the user with id: %s is no longer pending", claims.Subject)
+		return nil, err
+	}
+
+	changes := slices.Clone(user.Changes())
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		logx.Error().Err(err).Msgf("failed to save the confirmed email of the user with id: %s", claims.Subject)
+		return nil, err
+	}
+	s.publishUpdated(ctx, user, changes)
+
+	return user, nil
+}
+
+func (s *UserService) ChangeStatus(ctx context.Context, opts model.ChangeUserStatusOpts) (_ *model.User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ChangeStatus")
+	defer tracing.End(span, &err)
+
+	if err := opts.Validate(); err != nil {
+		logx.Error().Err(err).Msg("invalid options to change the user status")
+		return nil, err
+	}
+
+	// an admin locking themselves out would need another admin to get back in
+	if opts.ID == opts.ChangedBy {
+		return nil, model.ErrStatusSelf
+	}
+
+	user, err := s.Get(ctx, opts.ID)
+	if err != nil {
+		logx.Error().Err(err).Msgf("failed to get the user with id: %s to change the status", opts.ID)
+		return nil, err
+	}
+
+	if err := user.ChangeStatus(opts, time.Now()); err != nil {
+		logx.Error().Err(err).Msgf("failed to change the status of the user with id: %s", opts.ID)
+		return nil, err
+	}
+
+	changes := slices.Clone(user.Changes())
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		logx.Error().Err(err).Msgf("failed to save the status of the user with id: %s", opts.ID)
+		return nil, err
+	}
+	s.publishUpdated(ctx, user, changes)
 
+	logx.Info().Msgf("user with id: %s changed the status of the user with id: %s to %s", opts.ChangedBy, opts.ID, opts.Status)
 	return user, nil
 }
 
-func (s *UserService) Delete(ctx context.Context, id string) error {
-	err := s.userRepo.Delete(ctx, id)
+// ListLogins returns a page of the sign-ins of a user, newest first
+func (s *UserService) ListLogins(ctx context.Context, opts model.ListLoginOpts) (_ *model.LoginPage, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ListLogins")
+	defer tracing.End(span, &err)
+
+	if err := opts.Validate(); err != nil {
+		logx.Error().Err(err).Msg("invalid options to list the logins")
+		return nil, err
+	}
+
+	page, err := s.loginHistory.List(ctx, opts)
+	if err != nil {
+		logx.Error().Err(err).Msgf("failed to list the logins of the user with id: %s", opts.UserID)
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// checkEmailAvailable fails when the email belongs to another account, including one that can still be restored
+func (s *UserService) checkEmailAvailable(ctx context.Context, email string) error {
+	exist, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if exist != nil {
+		return model.ErrEmailTaken
+	}
+
+	deleted, err := s.userRepo.FindDeletedByEmail(ctx, email)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if deleted != nil {
+		return model.ErrEmailTaken
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var appErr *errx.Error
+	return errors.As(err, &appErr) && appErr.Code == http.StatusNotFound
+}
+
+// sendEmailChange asks the new address to confirm the change and lets the current address know about it
+func (s *UserService) sendEmailChange(ctx context.Context, user *model.User) error {
+	now := time.Now()
+	token, err := s.tokenManager.SignClaims(&model.EmailChangeClaims{
+		Email: user.PendingEmail(),
+		Type:  model.EmailChangeToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   user.ID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.emailChangeTTL)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	tokensIssued.WithLabelValues(model.EmailChangeToken.String()).Inc()
+
+	confirmation := fmt.Sprintf("Hi %s,\n\nConfirm that you want to use this address for your SevenHunter account", user.Name())
+	if s.emailConfirmURL != "" {
+		confirmation += fmt.Sprintf(" by opening:\n\n%s?token=%s\n", s.emailConfirmURL, url.QueryEscape(token))
+	} else {
+		confirmation += fmt.Sprintf(" with this code:\n\n%s\n", token)
+	}
+	confirmation += fmt.Sprintf("\nThe request expires in %s. Your email stays the same until it is confirmed.\n", s.emailChangeTTL)
+
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      user.PendingEmail(),
+		Subject: "Confirm your new email address",
+		Body:    confirmation,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email(),
+		Subject: "Your email address is about to change",
+		Body: fmt.Sprintf("Hi %s,\n\nA change of the email address of your SevenHunter account to %s was requested. "+
+			"It takes effect once confirmed from the new address.\n\nIf this was not you, save your current address on your profile again to cancel it.\n", user.Name(), user.PendingEmail()),
+	})
+}
+
+func (s *UserService) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.Delete")
+	defer tracing.End(span, &err)
+
+	// writes that go with deleting the account, such as revoking its sessions, join this transaction
+	err = s.unitOfWork.WithTransaction(ctx, func(ctx context.Context) error {
+		return s.userRepo.Delete(ctx, id)
+	})
 	if err != nil {
 		logx.Error().Err(err).Msgf("failed to delete the user with id: %s", id)
 		return err
 	}
 
+	s.events.Publish(ctx, model.UserDeleted{UserID: id, At: time.Now()})
 	return nil
 }
+
+// publishUpdated tells the subscribers about the fields of user that were just saved, nothing is published without changes
+func (s *UserService) publishUpdated(ctx context.Context, user *model.User, changes []model.UserField) {
+	if len(changes) == 0 {
+		return
+	}
+
+	s.events.Publish(ctx, model.UserUpdated{User: user.Clone(), Changes: changes, At: time.Now()})
+}
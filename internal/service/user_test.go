@@ -3,13 +3,20 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/chai-rs/sevenhunter/internal/model"
 	"github.com/chai-rs/sevenhunter/internal/model/mocks"
 	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/chai-rs/sevenhunter/pkg/mail"
 	. "github.com/chai-rs/sevenhunter/pkg/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -103,7 +110,7 @@ func TestUserService_List(t *testing.T) {
 		name     string
 		input    model.ListUserOpts
 		arrange  ArrangeFn[*UserService, model.ListUserOpts]
-		expected []model.User
+		expected *model.UserPage
 		isError  bool
 	}
 
@@ -115,9 +122,9 @@ func TestUserService_List(t *testing.T) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
 				require.True(t, ok)
 
-				repo.EXPECT().List(mock.Anything, input).Return([]model.User{}, nil)
+				repo.EXPECT().List(mock.Anything, input).Return(&model.UserPage{Users: []model.User{}}, nil)
 			},
-			expected: []model.User{},
+			expected: &model.UserPage{Users: []model.User{}},
 			isError:  false,
 		},
 		{
@@ -130,27 +137,103 @@ func TestUserService_List(t *testing.T) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
 				require.True(t, ok)
 
-				repo.EXPECT().List(mock.Anything, input).Return([]model.User{*user1, *user2}, nil)
+				repo.EXPECT().List(mock.Anything, input).Return(&model.UserPage{Users: []model.User{*user1, *user2}}, nil)
 			},
-			expected: []model.User{*user1, *user2},
+			expected: &model.UserPage{Users: []model.User{*user1, *user2}},
 			isError:  false,
 		},
 		{
 			name: "list users with pagination cursor",
 			input: model.ListUserOpts{
-				Cursor:  "cursor123",
-				Limit:   5,
-				SortAsc: false,
+				Cursor: &model.UserCursor{Sort: model.UserSortName, SortAsc: true, Value: "Jane Smith", ID: "2"},
+				Limit:  5,
 			},
 			arrange: func(t *testing.T, service *UserService, input model.ListUserOpts) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
 				require.True(t, ok)
 
-				repo.EXPECT().List(mock.Anything, input).Return([]model.User{*user1}, nil)
+				repo.EXPECT().List(mock.Anything, input).Return(&model.UserPage{
+					Users:   []model.User{*user1},
+					Next:    model.NewUserCursor(user1, model.UserSortName, true, false),
+					Prev:    model.NewUserCursor(user1, model.UserSortName, true, true),
+					HasMore: true,
+				}, nil)
 			},
-			expected: []model.User{*user1},
+			expected: &model.UserPage{
+				Users:   []model.User{*user1},
+				Next:    &model.UserCursor{Sort: model.UserSortName, SortAsc: true, Value: "John Doe", ID: "1"},
+				Prev:    &model.UserCursor{Sort: model.UserSortName, SortAsc: true, Value: "John Doe", ID: "1", Backward: true},
+				HasMore: true,
+			},
+			isError: false,
+		},
+		{
+			name: "list users sorted by email",
+			input: model.ListUserOpts{
+				Sort:    model.UserSortEmail,
+				SortAsc: true,
+			},
+			arrange: func(t *testing.T, service *UserService, input model.ListUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().List(mock.Anything, input).Return(&model.UserPage{Users: []model.User{*user2, *user1}}, nil)
+			},
+			expected: &model.UserPage{Users: []model.User{*user2, *user1}},
+			isError:  false,
+		},
+		{
+			name: "list users fails with unknown sort field",
+			input: model.ListUserOpts{
+				Sort: "hashed_password",
+			},
+			expected: nil,
+			isError:  true,
+		},
+		{
+			name: "list users fails with malformed cursor time",
+			input: model.ListUserOpts{
+				Cursor: &model.UserCursor{Sort: model.UserSortCreatedAt, Value: "yesterday", ID: "1"},
+			},
+			expected: nil,
+			isError:  true,
+		},
+		{
+			name: "list users with filters",
+			input: model.ListUserOpts{
+				NamePrefix:  "Jo",
+				Search:      "example",
+				CreatedFrom: time.UnixMilli(1000),
+				CreatedTo:   time.UnixMilli(2000),
+				Role:        model.UserRoleUser,
+				Status:      model.UserStatusActive,
+			},
+			arrange: func(t *testing.T, service *UserService, input model.ListUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().List(mock.Anything, input).Return(&model.UserPage{Users: []model.User{*user1}}, nil)
+			},
+			expected: &model.UserPage{Users: []model.User{*user1}},
 			isError:  false,
 		},
+		{
+			name: "list users fails with unknown role",
+			input: model.ListUserOpts{
+				Role: "owner",
+			},
+			expected: nil,
+			isError:  true,
+		},
+		{
+			name: "list users fails with inverted created range",
+			input: model.ListUserOpts{
+				CreatedFrom: time.UnixMilli(2000),
+				CreatedTo:   time.UnixMilli(1000),
+			},
+			expected: nil,
+			isError:  true,
+		},
 		{
 			name:  "list users fails with repository error",
 			input: model.ListUserOpts{},
@@ -277,31 +360,188 @@ func TestUserService_Update(t *testing.T) {
 			name: "update user successfully",
 			input: model.UpdateUserOpts{
 				ID:    "123",
-				Name:  "John Updated",
-				Email: "john.updated@example.com",
+				Name:  lo.ToPtr("John Updated"),
+				Email: lo.ToPtr("john@example.com"),
 			},
 			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
 				require.True(t, ok)
 
-				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(existingUser, nil)
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
 				repo.EXPECT().Update(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
-					return u.Name() == "John Updated" && u.Email() == "john.updated@example.com"
+					return u.Name() == "John Updated" && u.Email() == "john@example.com"
 				})).Return(nil)
 			},
 			validate: func(t *testing.T, result *model.User) {
 				require.NotNil(t, result)
 				require.Equal(t, "John Updated", result.Name())
-				require.Equal(t, "john.updated@example.com", result.Email())
+				require.Equal(t, "john@example.com", result.Email())
+				require.Empty(t, result.PendingEmail())
+			},
+			isError: false,
+		},
+		{
+			name: "update user successfully when If-Match version matches",
+			input: model.UpdateUserOpts{
+				ID:       "123",
+				Name:     lo.ToPtr("John Updated"),
+				Email:    lo.ToPtr("john@example.com"),
+				Versions: []int64{1},
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+				repo.EXPECT().Update(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, u *model.User) error {
+					u.MarkSaved(u.Version() + 1)
+					return nil
+				})
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.NotNil(t, result)
+				require.Equal(t, int64(2), result.Version())
+			},
+			isError: false,
+		},
+		{
+			name: "update user fails when If-Match version is stale",
+			input: model.UpdateUserOpts{
+				ID:       "123",
+				Name:     lo.ToPtr("John Updated"),
+				Email:    lo.ToPtr("john.updated@example.com"),
+				Versions: []int64{3},
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+				// Update will not be called because the version does not match
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
+		{
+			name: "update user fails when modified concurrently",
+			input: model.UpdateUserOpts{
+				ID:    "123",
+				Name:  lo.ToPtr("John Updated"),
+				Email: lo.ToPtr("john@example.com"),
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+				repo.EXPECT().Update(mock.Anything, mock.Anything).Return(model.ErrUserVersionMismatch)
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
+		{
+			name: "update only the name",
+			input: model.UpdateUserOpts{
+				ID:   "123",
+				Name: lo.ToPtr("John Renamed"),
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+				repo.EXPECT().Update(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return slices.Equal(u.Changes(), []model.UserField{model.UserFieldName})
+				})).Return(nil)
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.NotNil(t, result)
+				require.Equal(t, "John Renamed", result.Name())
+				require.Equal(t, "john@example.com", result.Email())
+			},
+			isError: false,
+		},
+		{
+			name: "update profile fields and merge metadata",
+			input: model.UpdateUserOpts{
+				ID:       "123",
+				Bio:      lo.ToPtr("Gopher"),
+				Locale:   lo.ToPtr("th-TH"),
+				Timezone: lo.ToPtr("Asia/Bangkok"),
+				Phone:    lo.ToPtr("+66812345678"),
+				Metadata: map[string]*string{"theme": lo.ToPtr("dark")},
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+				repo.EXPECT().Update(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return slices.Equal(u.Changes(), []model.UserField{
+						model.UserFieldBio, model.UserFieldLocale, model.UserFieldTimezone, model.UserFieldPhone, model.UserFieldMetadata,
+					})
+				})).Return(nil)
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.NotNil(t, result)
+				require.Equal(t, "Asia/Bangkok", result.Timezone())
+				require.Equal(t, map[string]string{"theme": "dark"}, result.Metadata())
 			},
 			isError: false,
 		},
+		{
+			name: "update user fails with invalid profile fields",
+			input: model.UpdateUserOpts{
+				ID:        "123",
+				AvatarURL: lo.ToPtr("javascript:alert(1)"),
+				Locale:    lo.ToPtr("not a locale"),
+				Timezone:  lo.ToPtr("Mars/Olympus"),
+				Phone:     lo.ToPtr("0812345678"),
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
+		{
+			name: "update user fails with too many metadata entries",
+			input: model.UpdateUserOpts{
+				ID: "123",
+				Metadata: func() map[string]*string {
+					metadata := make(map[string]*string)
+					for i := range model.MaxMetadataEntries + 1 {
+						metadata[fmt.Sprintf("key%d", i)] = lo.ToPtr("value")
+					}
+					return metadata
+				}(),
+			},
+			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
+				repo, ok := service.userRepo.(*mocks.MockUserRepo)
+				require.True(t, ok)
+
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+			},
+			validate: func(t *testing.T, result *model.User) {
+				require.Nil(t, result)
+			},
+			isError: true,
+		},
 		{
 			name: "update user fails when user not found",
 			input: model.UpdateUserOpts{
 				ID:    "nonexistent",
-				Name:  "John Updated",
-				Email: "john.updated@example.com",
+				Name:  lo.ToPtr("John Updated"),
+				Email: lo.ToPtr("john.updated@example.com"),
 			},
 			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
@@ -318,8 +558,8 @@ func TestUserService_Update(t *testing.T) {
 			name: "update user fails with invalid email",
 			input: model.UpdateUserOpts{
 				ID:    "123",
-				Name:  "John Updated",
-				Email: "invalid-email",
+				Name:  lo.ToPtr("John Updated"),
+				Email: lo.ToPtr("invalid-email"),
 			},
 			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
@@ -337,8 +577,8 @@ func TestUserService_Update(t *testing.T) {
 			name: "update user fails with empty name",
 			input: model.UpdateUserOpts{
 				ID:    "123",
-				Name:  "",
-				Email: "john@example.com",
+				Name:  lo.ToPtr(""),
+				Email: lo.ToPtr("john@example.com"),
 			},
 			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
@@ -355,14 +595,14 @@ func TestUserService_Update(t *testing.T) {
 			name: "update user fails when repository update fails",
 			input: model.UpdateUserOpts{
 				ID:    "123",
-				Name:  "John Updated",
-				Email: "john.updated@example.com",
+				Name:  lo.ToPtr("John Updated"),
+				Email: lo.ToPtr("john@example.com"),
 			},
 			arrange: func(t *testing.T, service *UserService, input model.UpdateUserOpts) {
 				repo, ok := service.userRepo.(*mocks.MockUserRepo)
 				require.True(t, ok)
 
-				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(existingUser, nil)
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
 				repo.EXPECT().Update(mock.Anything, mock.Anything).Return(errors.New("database error"))
 			},
 			validate: func(t *testing.T, result *model.User) {
@@ -396,6 +636,427 @@ func TestUserService_Update(t *testing.T) {
 	}
 }
 
+// mailRecorder keeps the sent emails instead of delivering them
+type mailRecorder struct {
+	messages []mail.Message
+	err      error
+}
+
+func (m *mailRecorder) Send(_ context.Context, msg mail.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// eventRecorder keeps the published events instead of delivering them
+type eventRecorder struct {
+	events []model.Event
+}
+
+func (r *eventRecorder) Publish(_ context.Context, event model.Event) {
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) names() []string {
+	return lo.Map(r.events, func(e model.Event, _ int) string { return e.EventName() })
+}
+
+func newTestEmailUserService(t *testing.T) (*UserService, *mocks.MockUserRepo, *mailRecorder) {
+	repo := mocks.NewMockUserRepo(t)
+	mailer := &mailRecorder{}
+	s := NewUserService(UserServiceOpts{
+		UserRepo:        repo,
+		TokenManager:    createTestTokenManager(),
+		Mailer:          mailer,
+		EmailConfirmURL: "https://app.example.com/email/confirm",
+	})
+
+	return s, repo, mailer
+}
+
+func TestUserService_UpdateEmail(t *testing.T) {
+	notFound := errx.M(404, "resource not found")
+
+	t.Run("new email waits for confirmation", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().FindByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().FindDeletedByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().Update(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+			return slices.Equal(u.Changes(), []model.UserField{model.UserFieldPendingEmail})
+		})).Return(nil)
+
+		result, err := s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("john.new@example.com"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "john@example.com", result.Email())
+		require.Equal(t, "john.new@example.com", result.PendingEmail())
+
+		require.Len(t, mailer.messages, 2)
+		require.Equal(t, "john.new@example.com", mailer.messages[0].To)
+		require.Contains(t, mailer.messages[0].Body, "https://app.example.com/email/confirm?token=")
+		require.Equal(t, "john@example.com", mailer.messages[1].To)
+		require.Contains(t, mailer.messages[1].Body, "john.new@example.com")
+	})
+
+	t.Run("requesting the pending email again resends the confirmation", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		user, err := model.NewUser(model.UserOpts{
+			ID:             "123",
+			Name:           "John Doe",
+			Email:          "john@example.com",
+			PendingEmail:   "john.new@example.com",
+			HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			CreatedAt:      time.Now(),
+		})
+		require.NoError(t, err)
+
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(user, nil)
+		repo.EXPECT().FindByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().FindDeletedByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().Update(mock.Anything, mock.Anything).Return(nil)
+
+		_, err = s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("john.new@example.com"),
+		})
+		require.NoError(t, err)
+		require.Len(t, mailer.messages, 2)
+	})
+
+	t.Run("requesting the current email cancels the pending change", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		user, err := model.NewUser(model.UserOpts{
+			ID:             "123",
+			Name:           "John Doe",
+			Email:          "john@example.com",
+			PendingEmail:   "john.new@example.com",
+			HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			CreatedAt:      time.Now(),
+		})
+		require.NoError(t, err)
+
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(user, nil)
+		repo.EXPECT().Update(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+			return slices.Equal(u.Changes(), []model.UserField{model.UserFieldPendingEmail})
+		})).Return(nil)
+
+		result, err := s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("john@example.com"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "john@example.com", result.Email())
+		require.Empty(t, result.PendingEmail())
+		require.Empty(t, mailer.messages)
+	})
+
+	t.Run("email of another account is rejected", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().FindByEmail(mock.Anything, "jane@example.com").Return(createTestUser("456", "Jane Doe", "jane@example.com"), nil)
+
+		_, err := s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("jane@example.com"),
+		})
+		require.ErrorIs(t, err, model.ErrEmailTaken)
+		require.Empty(t, mailer.messages)
+	})
+
+	t.Run("email of a restorable account is rejected", func(t *testing.T) {
+		s, repo, _ := newTestEmailUserService(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().FindByEmail(mock.Anything, "jane@example.com").Return(nil, notFound)
+		repo.EXPECT().FindDeletedByEmail(mock.Anything, "jane@example.com").Return(createTestUser("456", "Jane Doe", "jane@example.com"), nil)
+
+		_, err := s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("jane@example.com"),
+		})
+		require.ErrorIs(t, err, model.ErrEmailTaken)
+	})
+
+	t.Run("lookup failures are not taken for a free email", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().FindByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().FindDeletedByEmail(mock.Anything, "john.new@example.com").Return(nil, errx.InternalServerError)
+
+		_, err := s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("john.new@example.com"),
+		})
+		require.ErrorIs(t, err, errx.InternalServerError)
+		require.Empty(t, mailer.messages)
+	})
+
+	t.Run("update fails when the confirmation cannot be sent", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		mailer.err = errors.New("smtp unavailable")
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().FindByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().FindDeletedByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().Update(mock.Anything, mock.Anything).Return(nil)
+
+		_, err := s.Update(context.Background(), model.UpdateUserOpts{
+			ID:    "123",
+			Email: lo.ToPtr("john.new@example.com"),
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestUserService_ConfirmEmail(t *testing.T) {
+	pendingUser := func() *model.User {
+		user, err := model.NewUser(model.UserOpts{
+			ID:             "123",
+			Name:           "John Doe",
+			Email:          "john@example.com",
+			PendingEmail:   "john.new@example.com",
+			HashedPassword: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			CreatedAt:      time.Now(),
+		})
+		require.NoError(t, err)
+		return user
+	}
+
+	signToken := func(t *testing.T, s *UserService, claims *model.EmailChangeClaims) string {
+		token, err := s.tokenManager.SignClaims(claims)
+		require.NoError(t, err)
+		return token
+	}
+
+	validClaims := func(email string) *model.EmailChangeClaims {
+		return &model.EmailChangeClaims{
+			Email: email,
+			Type:  model.EmailChangeToken,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "123",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+	}
+
+	t.Run("confirm the email change sent by mail", func(t *testing.T) {
+		s, repo, mailer := newTestEmailUserService(t)
+		notFound := errx.M(404, "resource not found")
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil).Once()
+		repo.EXPECT().FindByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().FindDeletedByEmail(mock.Anything, "john.new@example.com").Return(nil, notFound)
+		repo.EXPECT().Update(mock.Anything, mock.Anything).Return(nil)
+
+		requested, err := s.Update(context.Background(), model.UpdateUserOpts{ID: "123", Email: lo.ToPtr("john.new@example.com")})
+		require.NoError(t, err)
+
+		link := mailer.messages[0].Body
+		start := strings.Index(link, "?token=") + len("?token=")
+		token, err := url.QueryUnescape(strings.Fields(link[start:])[0])
+		require.NoError(t, err)
+
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(requested, nil).Once()
+		result, err := s.ConfirmEmail(context.Background(), token)
+		require.NoError(t, err)
+		require.Equal(t, "john.new@example.com", result.Email())
+		require.Empty(t, result.PendingEmail())
+	})
+
+	t.Run("confirm fails when a newer change replaced the pending email", func(t *testing.T) {
+		s, repo, _ := newTestEmailUserService(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(pendingUser(), nil)
+
+		_, err := s.ConfirmEmail(context.Background(), signToken(t, s, validClaims("john.old@example.com")))
+		require.ErrorIs(t, err, model.ErrEmailChangeInvalid)
+	})
+
+	t.Run("confirm fails with an expired token", func(t *testing.T) {
+		s, _, _ := newTestEmailUserService(t)
+		claims := validClaims("john.new@example.com")
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+		_, err := s.ConfirmEmail(context.Background(), signToken(t, s, claims))
+		require.ErrorIs(t, err, model.ErrEmailChangeInvalid)
+	})
+
+	t.Run("confirm fails with another kind of token", func(t *testing.T) {
+		s, _, _ := newTestEmailUserService(t)
+		claims := validClaims("john.new@example.com")
+		claims.Type = model.AccessToken
+
+		_, err := s.ConfirmEmail(context.Background(), signToken(t, s, claims))
+		require.ErrorIs(t, err, model.ErrEmailChangeInvalid)
+	})
+
+	t.Run("confirm fails when the address was taken in the meantime", func(t *testing.T) {
+		s, repo, _ := newTestEmailUserService(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(pendingUser(), nil)
+		repo.EXPECT().Update(mock.Anything, mock.Anything).Return(model.ErrEmailTaken)
+
+		_, err := s.ConfirmEmail(context.Background(), signToken(t, s, validClaims("john.new@example.com")))
+		require.ErrorIs(t, err, model.ErrEmailTaken)
+	})
+}
+
+func TestUserService_ChangeStatus(t *testing.T) {
+	type Testcase struct {
+		name     string
+		input    model.ChangeUserStatusOpts
+		arrange  ArrangeFn[*UserService, model.ChangeUserStatusOpts]
+		validate func(t *testing.T, result *model.User, err error)
+	}
+
+	testcases := []Testcase{
+		{
+			name:  "suspend an active user",
+			input: model.ChangeUserStatusOpts{ID: "123", Status: model.UserStatusSuspended, Reason: "spam", ChangedBy: "admin-1"},
+			arrange: func(t *testing.T, service *UserService, input model.ChangeUserStatusOpts) {
+				repo := service.userRepo.(*mocks.MockUserRepo)
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+				repo.EXPECT().Update(mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+					return u.Status() == model.UserStatusSuspended && len(u.StatusHistory()) == 1
+				})).Return(nil)
+			},
+			validate: func(t *testing.T, result *model.User, err error) {
+				require.NoError(t, err)
+				require.Equal(t, model.UserStatusSuspended, result.Status())
+				require.Equal(t, "admin-1", result.StatusHistory()[0].ChangedBy)
+			},
+		},
+		{
+			name:  "change fails without a reason",
+			input: model.ChangeUserStatusOpts{ID: "123", Status: model.UserStatusSuspended, ChangedBy: "admin-1"},
+			validate: func(t *testing.T, result *model.User, err error) {
+				require.Error(t, err)
+				require.Nil(t, result)
+			},
+		},
+		{
+			name:  "change fails with an unknown status",
+			input: model.ChangeUserStatusOpts{ID: "123", Status: "banned", Reason: "spam", ChangedBy: "admin-1"},
+			validate: func(t *testing.T, result *model.User, err error) {
+				require.Error(t, err)
+				require.Nil(t, result)
+			},
+		},
+		{
+			name:  "admins cannot change their own status",
+			input: model.ChangeUserStatusOpts{ID: "admin-1", Status: model.UserStatusLocked, Reason: "testing", ChangedBy: "admin-1"},
+			validate: func(t *testing.T, result *model.User, err error) {
+				require.ErrorIs(t, err, model.ErrStatusSelf)
+			},
+		},
+		{
+			name:  "change fails when the transition is not allowed",
+			input: model.ChangeUserStatusOpts{ID: "123", Status: model.UserStatusPending, Reason: "reverify", ChangedBy: "admin-1"},
+			arrange: func(t *testing.T, service *UserService, input model.ChangeUserStatusOpts) {
+				repo := service.userRepo.(*mocks.MockUserRepo)
+				repo.EXPECT().FindByID(mock.Anything, input.ID).Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+			},
+			validate: func(t *testing.T, result *model.User, err error) {
+				require.Error(t, err)
+				require.Nil(t, result)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewUserService(UserServiceOpts{
+				UserRepo: mocks.NewMockUserRepo(t),
+			})
+
+			if tc.arrange != nil {
+				tc.arrange(t, s, tc.input)
+			}
+
+			result, err := s.ChangeStatus(context.Background(), tc.input)
+			tc.validate(t, result, err)
+		})
+	}
+}
+
+func TestUserService_ListLogins(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	page := &model.LoginPage{
+		Events: []model.LoginEvent{
+			{ID: "e2", UserID: "123", Method: model.LoginMethodRefresh, Success: true, At: at},
+			{ID: "e1", UserID: "123", Method: model.LoginMethodPassword, Reason: model.LoginFailureInvalidPassword, At: at.Add(-time.Hour)},
+		},
+		Next:    &model.LoginCursor{At: at.Add(-time.Hour), ID: "e1"},
+		HasMore: true,
+	}
+
+	type Testcase struct {
+		name     string
+		input    model.ListLoginOpts
+		arrange  ArrangeFn[*UserService, model.ListLoginOpts]
+		expected *model.LoginPage
+		isError  bool
+	}
+
+	testcases := []Testcase{
+		{
+			name:  "list logins successfully",
+			input: model.ListLoginOpts{UserID: "123", Limit: 2},
+			arrange: func(t *testing.T, service *UserService, input model.ListLoginOpts) {
+				history, ok := service.loginHistory.(*mocks.MockLoginHistoryRepo)
+				require.True(t, ok)
+
+				history.EXPECT().List(mock.Anything, input).Return(page, nil)
+			},
+			expected: page,
+		},
+		{
+			name:    "list logins fails without a user",
+			input:   model.ListLoginOpts{},
+			isError: true,
+		},
+		{
+			name:    "list logins fails with an incomplete cursor",
+			input:   model.ListLoginOpts{UserID: "123", Cursor: &model.LoginCursor{ID: "e1"}},
+			isError: true,
+		},
+		{
+			name:  "list logins fails with repository error",
+			input: model.ListLoginOpts{UserID: "123"},
+			arrange: func(t *testing.T, service *UserService, input model.ListLoginOpts) {
+				history, ok := service.loginHistory.(*mocks.MockLoginHistoryRepo)
+				require.True(t, ok)
+
+				history.EXPECT().List(mock.Anything, input).Return(nil, errors.New("database error"))
+			},
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewUserService(UserServiceOpts{
+				UserRepo:     mocks.NewMockUserRepo(t),
+				LoginHistory: mocks.NewMockLoginHistoryRepo(t),
+			})
+
+			if tc.arrange != nil {
+				tc.arrange(t, s, tc.input)
+			}
+
+			result, err := s.ListLogins(context.Background(), tc.input)
+			if tc.isError {
+				require.Error(t, err)
+				require.Nil(t, result)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
 func TestUserService_Delete(t *testing.T) {
 	type Testcase struct {
 		name    string
@@ -460,3 +1121,55 @@ func TestUserService_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_Events(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes the saved changes", func(t *testing.T) {
+		repo := mocks.NewMockUserRepo(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().Update(mock.Anything, mock.Anything).RunAndReturn(func(_ context.Context, u *model.User) error {
+			u.MarkSaved(u.Version() + 1)
+			return nil
+		})
+
+		events := &eventRecorder{}
+		s := NewUserService(UserServiceOpts{UserRepo: repo, Events: events})
+
+		_, err := s.Update(ctx, model.UpdateUserOpts{ID: "123", Name: lo.ToPtr("Johnny"), Bio: lo.ToPtr("hello")})
+		require.NoError(t, err)
+		require.Len(t, events.events, 1)
+
+		updated := events.events[0].(model.UserUpdated)
+		require.Equal(t, []model.UserField{model.UserFieldName, model.UserFieldBio}, updated.Changes)
+		require.Equal(t, "Johnny", updated.User.Name())
+		require.EqualValues(t, 2, updated.User.Version())
+	})
+
+	t.Run("publishes nothing without changes", func(t *testing.T) {
+		repo := mocks.NewMockUserRepo(t)
+		repo.EXPECT().FindByID(mock.Anything, "123").Return(createTestUser("123", "John Doe", "john@example.com"), nil)
+		repo.EXPECT().Update(mock.Anything, mock.Anything).Return(nil)
+
+		events := &eventRecorder{}
+		s := NewUserService(UserServiceOpts{UserRepo: repo, Events: events})
+
+		_, err := s.Update(ctx, model.UpdateUserOpts{ID: "123", Name: lo.ToPtr("John Doe")})
+		require.NoError(t, err)
+		require.Empty(t, events.events)
+	})
+
+	t.Run("publishes deletions once they are stored", func(t *testing.T) {
+		repo := mocks.NewMockUserRepo(t)
+		repo.EXPECT().Delete(mock.Anything, "123").Return(nil).Once()
+		repo.EXPECT().Delete(mock.Anything, "456").Return(errors.New("database error")).Once()
+
+		events := &eventRecorder{}
+		s := NewUserService(UserServiceOpts{UserRepo: repo, Events: events})
+
+		require.NoError(t, s.Delete(ctx, "123"))
+		require.Error(t, s.Delete(ctx, "456"))
+		require.Len(t, events.events, 1)
+		require.Equal(t, "123", events.events[0].(model.UserDeleted).UserID)
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// usersBaseProperties are the fields every user document has
+var usersBaseProperties = bson.D{
+	{Key: "_id", Value: bson.M{"bsonType": "objectId", "description": "auto-generated unique identifier"}},
+	{Key: "email", Value: bson.M{"bsonType": "string", "description": "must be a string and is required"}},
+	{Key: "hashed_password", Value: bson.M{"bsonType": "string", "description": "must be a string and is required"}},
+	{Key: "name", Value: bson.M{"bsonType": "string", "description": "must be a string if provided"}},
+	{Key: "created_at", Value: bson.M{"bsonType": "date", "description": "must be a date and is required"}},
+}
+
+func usersSchema(properties bson.D) bson.M {
+	return bson.M{"$jsonSchema": bson.M{
+		"bsonType":   "object",
+		"required":   bson.A{"_id", "email", "name", "hashed_password", "created_at"},
+		"properties": properties,
+	}}
+}
+
+var users = migrate.Migration{
+	ID: "20251108173001-users",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		opts := options.CreateCollection().SetValidator(usersSchema(usersBaseProperties))
+		if err := db.CreateCollection(ctx, "users", opts); err != nil {
+			return err
+		}
+
+		_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+			// Create unique index on email
+			{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+			// Create index on name if it exists
+			{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetSparse(true)},
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return db.Collection("users").Drop(ctx)
+	},
+}
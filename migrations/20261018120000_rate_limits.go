@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var rateLimits = migrate.Migration{
+	ID: "20261018120000-rate-limits",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		if err := db.CreateCollection(ctx, "rate_limits"); err != nil {
+			return err
+		}
+
+		// Drop rate limit buckets once they can no longer affect the sliding window
+		_, err := db.Collection("rate_limits").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return db.Collection("rate_limits").Drop(ctx)
+	},
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var idempotencyKeys = migrate.Migration{
+	ID: "20261018130000-idempotency-keys",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		if err := db.CreateCollection(ctx, "idempotency_keys"); err != nil {
+			return err
+		}
+
+		_, err := db.Collection("idempotency_keys").Indexes().CreateMany(ctx, []mongo.IndexModel{
+			// Forget stored responses and stale in-flight claims once they expire
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			// Look up keys owned by a user
+			{Keys: bson.D{{Key: "principal", Value: 1}}},
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return db.Collection("idempotency_keys").Drop(ctx)
+	},
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var usersSearch = migrate.Migration{
+	ID: "20261018140000-users-search",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		users := db.Collection("users")
+
+		// Backfill the fields introduced for list filters
+		if _, err := users.UpdateMany(ctx, bson.M{"role": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"role": "user"}}); err != nil {
+			return err
+		}
+		if _, err := users.UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"status": "active"}}); err != nil {
+			return err
+		}
+
+		_, err := users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			// Support created_at range filters while paginating on _id
+			{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+			// Support role and status filters while paginating on _id
+			{Keys: bson.D{{Key: "role", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: 1}}},
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		if err := dropIndex(ctx, db, "users", bson.D{{Key: "role", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: 1}}); err != nil {
+			return err
+		}
+		if err := dropIndex(ctx, db, "users", bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}); err != nil {
+			return err
+		}
+
+		_, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"role": "", "status": ""}})
+		return err
+	},
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var usersSort = migrate.Migration{
+	ID: "20261018150000-users-sort",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		// Support sorting users by name or email with _id as tiebreaker
+		_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+			{Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}},
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		if err := dropIndex(ctx, db, "users", bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}); err != nil {
+			return err
+		}
+		return dropIndex(ctx, db, "users", bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	},
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var usersSoftDelete = migrate.Migration{
+	ID: "20261018160000-users-soft-delete",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		// Find deleted users past the grace period, live users have no deleted_at
+		_, err := db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$type": "date"}}),
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return dropIndex(ctx, db, "users", bson.D{{Key: "deleted_at", Value: 1}})
+	},
+}
@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var usersVersion = migrate.Migration{
+	ID: "20261018170000-users-version",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		// Users start at version 1, every update increments it
+		_, err := db.Collection("users").UpdateMany(ctx, bson.M{"version": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"version": 1}})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"version": ""}})
+		return err
+	},
+}
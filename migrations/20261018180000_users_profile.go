@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"context"
+	"slices"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var usersProfileProperties = bson.D{
+	{Key: "avatar_url", Value: bson.M{"bsonType": "string", "maxLength": 2048, "description": "http or https URL of the avatar if provided"}},
+	{Key: "bio", Value: bson.M{"bsonType": "string", "description": "free text of at most 500 characters if provided"}},
+	{Key: "locale", Value: bson.M{"bsonType": "string", "description": "BCP 47 language tag if provided"}},
+	{Key: "timezone", Value: bson.M{"bsonType": "string", "description": "IANA time zone name if provided"}},
+	{Key: "phone", Value: bson.M{"bsonType": "string", "pattern": `^\+[1-9][0-9]{6,14}$`, "description": "E.164 phone number if provided"}},
+	{Key: "metadata", Value: bson.M{
+		"bsonType":             "object",
+		"maxProperties":        20,
+		"additionalProperties": bson.M{"bsonType": "string", "maxLength": 500},
+		"description":          "at most 20 string entries if provided",
+	}},
+}
+
+var usersProfile = migrate.Migration{
+	ID: "20261018180000-users-profile",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		// Profile fields are optional, existing documents stay valid without them.
+		// Drop values that an earlier client may have written with the wrong type.
+		for _, property := range usersProfileProperties {
+			bsonType := property.Value.(bson.M)["bsonType"]
+			filter := bson.M{property.Key: bson.M{"$exists": true, "$not": bson.M{"$type": bsonType}}}
+			if _, err := db.Collection("users").UpdateMany(ctx, filter, bson.M{"$unset": bson.M{property.Key: ""}}); err != nil {
+				return err
+			}
+		}
+
+		return db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: "users"},
+			{Key: "validator", Value: usersSchema(slices.Concat(usersBaseProperties, usersProfileProperties))},
+		}).Err()
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		err := db.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: "users"},
+			{Key: "validator", Value: usersSchema(usersBaseProperties)},
+		}).Err()
+		if err != nil {
+			return err
+		}
+
+		unset := bson.M{}
+		for _, property := range usersProfileProperties {
+			unset[property.Key] = ""
+		}
+		_, err = db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": unset})
+		return err
+	},
+}
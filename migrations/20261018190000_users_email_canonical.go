@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The email rules as they were when the migration was written, later changes to the ones in the model
+// must not change what it does
+
+// normalizeEmail trims the address and lowercases its domain
+func normalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	return email[:at+1] + strings.ToLower(email[at+1:])
+}
+
+// canonicalEmail lowercases the address, Gmail addresses also drop dots and "+" suffixes
+// from the local part and googlemail.com becomes gmail.com
+func canonicalEmail(email string) string {
+	email = strings.ToLower(normalizeEmail(email))
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local, _, _ = strings.Cut(local, "+")
+		local, domain = strings.ReplaceAll(local, ".", ""), "gmail.com"
+	}
+
+	return local + "@" + domain
+}
+
+type emailAccount struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Email string             `bson:"email"`
+}
+
+var usersEmailCanonical = migrate.Migration{
+	ID: "20261018190000-users-email-canonical",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		users := db.Collection("users")
+
+		cursor, err := users.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"email": 1}))
+		if err != nil {
+			return err
+		}
+
+		var accounts []emailAccount
+		if err := cursor.All(ctx, &accounts); err != nil {
+			return err
+		}
+
+		byCanonical := make(map[string][]emailAccount)
+		for _, account := range accounts {
+			canonical := canonicalEmail(account.Email)
+			byCanonical[canonical] = append(byCanonical[canonical], account)
+		}
+
+		// Accounts whose emails only differ by case or provider aliases cannot be merged automatically,
+		// stop before changing anything and list them so they can be resolved by hand
+		if report := duplicateEmailReport(byCanonical); report != "" {
+			return errors.New(report)
+		}
+
+		for canonical, matches := range byCanonical {
+			account := matches[0]
+			update := bson.M{"$set": bson.M{"email": normalizeEmail(account.Email), "email_canonical": canonical}}
+			if _, err := users.UpdateByID(ctx, account.ID, update); err != nil {
+				return err
+			}
+		}
+
+		// Deleted users keep their address until they are purged, so the index covers them too
+		_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email_canonical", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		if err := dropIndex(ctx, db, "users", bson.D{{Key: "email_canonical", Value: 1}}); err != nil {
+			return err
+		}
+
+		_, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"email_canonical": ""}})
+		return err
+	},
+}
+
+// duplicateEmailReport lists the canonical emails used by more than one account, empty when there are none
+func duplicateEmailReport(byCanonical map[string][]emailAccount) string {
+	var lines []string
+	for canonical, matches := range byCanonical {
+		if len(matches) < 2 {
+			continue
+		}
+
+		users := make([]string, 0, len(matches))
+		for _, account := range matches {
+			users = append(users, fmt.Sprintf("%s <%s>", account.ID.Hex(), account.Email))
+		}
+		lines = append(lines, canonical+": "+strings.Join(users, ", "))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+
+	slices.Sort(lines)
+	return fmt.Sprintf("%d email addresses are used by more than one user:\n%s", len(lines), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loginHistory = migrate.Migration{
+	ID: "20261018200000-login-history",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		if err := db.CreateCollection(ctx, "login_history"); err != nil {
+			return err
+		}
+
+		_, err := db.Collection("login_history").Indexes().CreateMany(ctx, []mongo.IndexModel{
+			// Drop entries once the retention set when they were recorded is over
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			// Page through the history of a user, newest first
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "at", Value: -1}, {Key: "_id", Value: -1}}},
+		})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		if err := db.Collection("login_history").Drop(ctx); err != nil {
+			return err
+		}
+
+		_, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"last_login_at": ""}})
+		return err
+	},
+}
@@ -0,0 +1,35 @@
+// Package migrations holds the changes to the Mongo database, applied in order by `main migrate up`.
+// A new migration goes in its own file named after its id and is added to All.
+package migrations
+
+import (
+	"context"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// All returns every migration, the runner applies them in id order
+func All() []migrate.Migration {
+	return []migrate.Migration{
+		users,
+		rateLimits,
+		idempotencyKeys,
+		usersSearch,
+		usersSort,
+		usersSoftDelete,
+		usersVersion,
+		usersProfile,
+		usersEmailCanonical,
+		loginHistory,
+	}
+}
+
+// dropIndex drops the index on keys whatever its name is
+func dropIndex(ctx context.Context, db *mongo.Database, collection string, keys bson.D) error {
+	return db.RunCommand(ctx, bson.D{
+		{Key: "dropIndexes", Value: collection},
+		{Key: "index", Value: keys},
+	}).Err()
+}
@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/internal/repo"
+	"github.com/chai-rs/sevenhunter/pkg/idempotency"
+	"github.com/chai-rs/sevenhunter/pkg/mongo/migrate"
+	"github.com/chai-rs/sevenhunter/pkg/mongo/schema"
+	"github.com/chai-rs/sevenhunter/pkg/ratelimit"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestAll(t *testing.T) {
+	id := regexp.MustCompile(`^\d{14}-[a-z0-9-]+$`)
+	for _, m := range All() {
+		require.Regexp(t, id, m.ID)
+		require.NotNil(t, m.Up, m.ID)
+		require.NotNil(t, m.Down, m.ID)
+	}
+}
+
+func TestDuplicateEmailReport(t *testing.T) {
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	report := duplicateEmailReport(map[string][]emailAccount{
+		"johndoe@gmail.com": {{ID: first, Email: "John.Doe@gmail.com"}, {ID: second, Email: "johndoe+news@gmail.com"}},
+		"jane@example.com":  {{ID: primitive.NewObjectID(), Email: "jane@example.com"}},
+	})
+	require.Equal(t, "1 email addresses are used by more than one user:\n"+
+		"johndoe@gmail.com: "+first.Hex()+" <John.Doe@gmail.com>, "+second.Hex()+" <johndoe+news@gmail.com>", report)
+
+	require.Empty(t, duplicateEmailReport(map[string][]emailAccount{
+		"jane@example.com": {{ID: primitive.NewObjectID(), Email: "jane@example.com"}},
+	}))
+}
+
+// TestMigrations applies every migration and reverts them all, it needs MONGO_TEST_URI
+func TestMigrations(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	db := client.Database("sevenhunter_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	m, err := migrate.New(migrate.Opts{DB: db, Migrations: All()})
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, len(All()))
+
+	// the stores find the collections they expect
+	drifts, err := schema.Reconcile(ctx, schema.Opts{
+		DB:          db,
+		Collections: []schema.Collection{repo.UserSchema, repo.LoginHistorySchema, ratelimit.MongoSchema, idempotency.MongoSchema},
+		Mode:        schema.ModeVerify,
+	})
+	require.NoError(t, err)
+	require.Empty(t, drifts)
+
+	// the canonical email is backfilled and unique
+	users := db.Collection("users")
+	_, err = users.InsertOne(ctx, bson.M{
+		"name": "John", "email": "John.Doe@gmail.com", "email_canonical": "johndoe@gmail.com",
+		"hashed_password": "hash", "created_at": time.Now(),
+	})
+	require.NoError(t, err)
+	_, err = users.InsertOne(ctx, bson.M{
+		"name": "John", "email": "johndoe@gmail.com", "email_canonical": "johndoe@gmail.com",
+		"hashed_password": "hash", "created_at": time.Now(),
+	})
+	require.True(t, mongo.IsDuplicateKeyError(err))
+
+	for range All() {
+		_, err := m.Down(ctx)
+		require.NoError(t, err)
+	}
+	_, err = m.Down(ctx)
+	require.ErrorIs(t, err, migrate.ErrNothingToUndo)
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, len(All()))
+}
@@ -0,0 +1,48 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+)
+
+var (
+	ErrNotFound   = errx.M(http.StatusNotFound, "blob not found")
+	ErrInvalidKey = errx.M(http.StatusBadRequest, "invalid blob key")
+)
+
+// Object describes a stored blob
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+	ETag        string
+	ModTime     time.Time
+}
+
+// Store keeps binary objects by key. Keys are slash separated paths without "." or ".." segments.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound when the key does not exist, the caller closes the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Delete succeeds when the key does not exist
+	Delete(ctx context.Context, key string) error
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return ErrInvalidKey
+	}
+
+	for segment := range strings.SplitSeq(key, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.Contains(segment, "\\") {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 stands in for an S3-compatible service, it serves path-style object requests from memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+}
+
+type fakeS3Object struct {
+	body        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeS3Object{body: body, contentType: r.Header.Get("Content-Type"), modTime: time.Now()}
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, len(body)))
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `<Error><Code>NoSuchKey</Code><Message>not found</Message><Key>%s</Key></Error>`, key)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(obj.body)))
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, len(obj.body)))
+		w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(obj.body)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newStores(t *testing.T) map[string]Store {
+	local, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	srv := httptest.NewTLSServer(&fakeS3{objects: make(map[string]fakeS3Object)})
+	t.Cleanup(srv.Close)
+
+	s3, err := newS3Store(S3Config{
+		Endpoint:  strings.TrimPrefix(srv.URL, "https://"),
+		Bucket:    "avatars",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		UseSSL:    true,
+	}, srv.Client().Transport)
+	require.NoError(t, err)
+
+	return map[string]Store{"local": local, "s3": s3}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	content := []byte("\xff\xd8\xffjpeg bytes")
+
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("get fails for a missing key", func(t *testing.T) {
+				_, _, err := store.Get(ctx, "users/1/missing.jpg")
+				require.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("put then get returns the content", func(t *testing.T) {
+				err := store.Put(ctx, "users/1/avatar.jpg", bytes.NewReader(content), int64(len(content)), "image/jpeg")
+				require.NoError(t, err)
+
+				r, obj, err := store.Get(ctx, "users/1/avatar.jpg")
+				require.NoError(t, err)
+				defer r.Close()
+
+				got, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.Equal(t, content, got)
+				require.Equal(t, "image/jpeg", obj.ContentType)
+				require.Equal(t, int64(len(content)), obj.Size)
+				require.NotEmpty(t, obj.ETag)
+			})
+
+			t.Run("delete removes the key and tolerates missing keys", func(t *testing.T) {
+				require.NoError(t, store.Delete(ctx, "users/1/avatar.jpg"))
+				require.NoError(t, store.Delete(ctx, "users/1/avatar.jpg"))
+
+				_, _, err := store.Get(ctx, "users/1/avatar.jpg")
+				require.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("rejects keys escaping the store", func(t *testing.T) {
+				for _, key := range []string{"", "/etc/passwd", "../secret", "users/../../secret", "users//1", "users\\1"} {
+					err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "image/jpeg")
+					require.ErrorIs(t, err, ErrInvalidKey, key)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package blob
+
+import "fmt"
+
+type Config struct {
+	Driver      string `default:"local"`
+	LocalRoot   string `split_words:"true" default:"data/blobs"`
+	S3Endpoint  string `split_words:"true" default:"localhost:9000"`
+	S3Bucket    string `split_words:"true" default:"sevenhunter"`
+	S3Region    string `split_words:"true" default:"us-east-1"`
+	S3AccessKey string `split_words:"true"`
+	S3SecretKey string `split_words:"true"`
+	S3UseSSL    bool   `split_words:"true" default:"false"`
+}
+
+func (c *Config) New() (Store, error) {
+	switch c.Driver {
+	case "local":
+		return NewLocalStore(c.LocalRoot)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  c.S3Endpoint,
+			Bucket:    c.S3Bucket,
+			Region:    c.S3Region,
+			AccessKey: c.S3AccessKey,
+			SecretKey: c.S3SecretKey,
+			UseSSL:    c.S3UseSSL,
+		})
+	}
+
+	return nil, fmt.Errorf("unknown blob driver %q", c.Driver)
+}
@@ -0,0 +1,149 @@
+// Package cache holds in-process caches shared by the stores
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// generationSlots bounds the memory of the per-key generations, keys sharing a slot only
+// keep each other from being cached while one of them is deleted
+const generationSlots = 256
+
+// LRU keeps up to size entries for ttl each, the least recently used entry is evicted to make room.
+// It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[K]*list.Element
+	// order has the most recently used entry at the front
+	order *list.List
+	// generations of the keys, incremented by Delete and Clear, see SetIfUnchanged
+	generations [generationSlots]uint64
+	seed        maphash.Seed
+	now         func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func NewLRU[K comparable, V any](size int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:  max(size, 1),
+		ttl:   ttl,
+		items: make(map[K]*list.Element),
+		order: list.New(),
+		seed:  maphash.MakeSeed(),
+		now:   time.Now,
+	}
+}
+
+// Get returns the value of key unless it is missing or expired
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := elem.Value.(*entry[K, V])
+	if !c.now().Before(e.expiresAt) {
+		c.remove(elem)
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value)
+}
+
+// Generation changes whenever key is deleted or the cache is cleared.
+// Read it before loading the value of key and pass it to SetIfUnchanged.
+func (c *LRU[K, V]) Generation(key K) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[c.slot(key)]
+}
+
+// SetIfUnchanged stores the value unless key was deleted since generation was read,
+// so a value loaded before a concurrent write cannot be cached after that write dropped it
+func (c *LRU[K, V]) SetIfUnchanged(key K, generation uint64, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[c.slot(key)] != generation {
+		return false
+	}
+
+	c.set(key, value)
+	return true
+}
+
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[c.slot(key)]++
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *LRU[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.generations {
+		c.generations[i]++
+	}
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Len counts the entries, expired ones included until they are looked up or evicted
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) set(key K, value V) {
+	expiresAt := c.now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		e.value, e.expiresAt = value, expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRU[K, V]) slot(key K) uint64 {
+	return maphash.Comparable(c.seed, key) % generationSlots
+}
+
+func (c *LRU[K, V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry[K, V]).key)
+}
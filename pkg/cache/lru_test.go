@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	newLRU := func() *LRU[string, int] {
+		c := NewLRU[string, int](2, time.Minute)
+		c.now = func() time.Time { return now }
+		return c
+	}
+
+	t.Run("evicts the least recently used entry", func(t *testing.T) {
+		c := newLRU()
+		c.Set("a", 1)
+		c.Set("b", 2)
+		_, _ = c.Get("a")
+		c.Set("c", 3)
+
+		_, ok := c.Get("b")
+		require.False(t, ok)
+		value, ok := c.Get("a")
+		require.True(t, ok)
+		require.Equal(t, 1, value)
+		require.Equal(t, 2, c.Len())
+	})
+
+	t.Run("entries expire after the ttl", func(t *testing.T) {
+		c := newLRU()
+		c.Set("a", 1)
+
+		c.now = func() time.Time { return now.Add(59 * time.Second) }
+		_, ok := c.Get("a")
+		require.True(t, ok)
+
+		c.now = func() time.Time { return now.Add(time.Minute) }
+		_, ok = c.Get("a")
+		require.False(t, ok)
+		require.Zero(t, c.Len())
+	})
+
+	t.Run("set refreshes the ttl", func(t *testing.T) {
+		c := newLRU()
+		c.Set("a", 1)
+
+		c.now = func() time.Time { return now.Add(30 * time.Second) }
+		c.Set("a", 2)
+
+		c.now = func() time.Time { return now.Add(80 * time.Second) }
+		value, ok := c.Get("a")
+		require.True(t, ok)
+		require.Equal(t, 2, value)
+	})
+
+	t.Run("delete and clear", func(t *testing.T) {
+		c := newLRU()
+		c.Set("a", 1)
+		c.Set("b", 2)
+
+		c.Delete("a")
+		_, ok := c.Get("a")
+		require.False(t, ok)
+
+		c.Clear()
+		_, ok = c.Get("b")
+		require.False(t, ok)
+		require.Zero(t, c.Len())
+	})
+
+	t.Run("a value loaded before a delete is not cached", func(t *testing.T) {
+		c := newLRU()
+		generation := c.Generation("a")
+		c.Delete("a")
+
+		require.False(t, c.SetIfUnchanged("a", generation, 1))
+		_, ok := c.Get("a")
+		require.False(t, ok)
+
+		require.True(t, c.SetIfUnchanged("a", c.Generation("a"), 1))
+		_, ok = c.Get("a")
+		require.True(t, ok)
+	})
+
+	t.Run("deleting a key leaves the loads of other keys alone", func(t *testing.T) {
+		c := newLRU()
+		generation := c.Generation("a")
+		c.Delete(otherSlot(t, c, "a"))
+
+		require.True(t, c.SetIfUnchanged("a", generation, 1))
+	})
+
+	t.Run("clear changes every generation", func(t *testing.T) {
+		c := newLRU()
+		generation := c.Generation("a")
+		c.Clear()
+
+		require.False(t, c.SetIfUnchanged("a", generation, 1))
+	})
+}
+
+// otherSlot finds a key that does not share the generation of key
+func otherSlot(t *testing.T, c *LRU[string, int], key string) string {
+	for i := range generationSlots + 1 {
+		other := key + strconv.Itoa(i)
+		if c.slot(other) != c.slot(key) {
+			return other
+		}
+	}
+	t.Fatal("every key shares the generation of " + key)
+	return ""
+}
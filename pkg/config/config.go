@@ -18,12 +18,16 @@ func MustNew[T any](prefix string) *T {
 	return conf
 }
 
+// envFile is registered once so several configs can be read, the arguments after the flags are left to the caller
+var envFile = flag.String("env", "", "path to .env file")
+
 func New[T any](prefix string) (*T, error) {
-	filepath := flag.String("env", "", "path to .env file")
-	flag.Parse()
+	if !flag.Parsed() {
+		flag.Parse()
+	}
 
-	if filepath != nil && *filepath != "" {
-		if err := exportEnvironment(*filepath); err != nil {
+	if *envFile != "" {
+		if err := exportEnvironment(*envFile); err != nil {
 			return nil, fmt.Errorf("failed to load env file: %w", err)
 		}
 	}
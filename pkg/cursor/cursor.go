@@ -0,0 +1,76 @@
+package cursor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+)
+
+var (
+	ErrInvalidCursor = func(e error) error { return errx.E(http.StatusBadRequest, e, "invalid cursor") }
+
+	errMalformed = errors.New("malformed cursor")
+	errSignature = errors.New("cursor signature mismatch")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Codec turns pagination state into opaque strings that clients can pass back but not forge.
+// A cursor is the base64url JSON payload and its HMAC-SHA256, joined by a dot.
+type Codec struct {
+	secret []byte
+}
+
+func NewCodec(secret string) *Codec {
+	return &Codec{secret: []byte(secret)}
+}
+
+// Encode serializes v to JSON and signs it
+func (c *Codec) Encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(c.sign(payload)), nil
+}
+
+// Decode verifies the signature of s and unmarshals its payload into v
+func (c *Codec) Decode(s string, v any) error {
+	rawPayload, rawSignature, ok := bytes.Cut([]byte(s), []byte("."))
+	if !ok {
+		return ErrInvalidCursor(errMalformed)
+	}
+
+	payload, err := encoding.DecodeString(string(rawPayload))
+	if err != nil {
+		return ErrInvalidCursor(err)
+	}
+
+	signature, err := encoding.DecodeString(string(rawSignature))
+	if err != nil {
+		return ErrInvalidCursor(err)
+	}
+
+	if !hmac.Equal(signature, c.sign(payload)) {
+		return ErrInvalidCursor(errSignature)
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidCursor(err)
+	}
+
+	return nil
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
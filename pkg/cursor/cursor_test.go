@@ -0,0 +1,82 @@
+package cursor
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	errx "github.com/chai-rs/sevenhunter/pkg/error"
+	"github.com/stretchr/testify/require"
+)
+
+type position struct {
+	Key string `json:"k"`
+	ID  string `json:"id"`
+}
+
+func TestCodec(t *testing.T) {
+	codec := NewCodec("secret")
+	valid, err := codec.Encode(position{Key: "alice", ID: "1"})
+	require.NoError(t, err)
+
+	payload, _, _ := strings.Cut(valid, ".")
+	forged, err := NewCodec("other").Encode(position{Key: "alice", ID: "1"})
+	require.NoError(t, err)
+	_, forgedSignature, _ := strings.Cut(forged, ".")
+
+	tampered, err := NewCodec("other").Encode(position{Key: "bob", ID: "2"})
+	require.NoError(t, err)
+	tamperedPayload, _, _ := strings.Cut(tampered, ".")
+	_, signature, _ := strings.Cut(valid, ".")
+
+	type Testcase struct {
+		name     string
+		input    string
+		expected position
+		isError  bool
+	}
+
+	testcases := []Testcase{
+		{
+			name:     "round trips a signed cursor",
+			input:    valid,
+			expected: position{Key: "alice", ID: "1"},
+		},
+		{
+			name:    "rejects a cursor signed with another secret",
+			input:   payload + "." + forgedSignature,
+			isError: true,
+		},
+		{
+			name:    "rejects a tampered payload",
+			input:   tamperedPayload + "." + signature,
+			isError: true,
+		},
+		{
+			name:    "rejects a cursor without signature",
+			input:   payload,
+			isError: true,
+		},
+		{
+			name:    "rejects garbage",
+			input:   "not a cursor.at all",
+			isError: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got position
+			err := codec.Decode(tc.input, &got)
+			if tc.isError {
+				var e *errx.Error
+				require.ErrorAs(t, err, &e)
+				require.Equal(t, http.StatusBadRequest, e.Code)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
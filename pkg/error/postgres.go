@@ -0,0 +1,29 @@
+package errx
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// postgresUniqueViolation is the SQLSTATE of a unique constraint violation
+const postgresUniqueViolation = "23505"
+
+func Postgres(err error) *Error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return E(http.StatusNotFound, err, "resource not found")
+	case IsPostgresUniqueViolation(err):
+		return E(http.StatusConflict, err, "resource already exists")
+	}
+
+	return InternalServerError
+}
+
+// IsPostgresUniqueViolation reports whether err is a unique constraint violation, like mongo.IsDuplicateKeyError
+func IsPostgresUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolation
+}
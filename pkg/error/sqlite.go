@@ -0,0 +1,32 @@
+package errx
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func SQLite(err error) *Error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return E(http.StatusNotFound, err, "resource not found")
+	case IsSQLiteUniqueViolation(err):
+		return E(http.StatusConflict, err, "resource already exists")
+	}
+
+	return InternalServerError
+}
+
+// IsSQLiteUniqueViolation reports whether err is a unique or primary key constraint violation
+func IsSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
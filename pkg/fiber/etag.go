@@ -0,0 +1,40 @@
+package fx
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetETag sets a strong entity tag built from a resource version
+func SetETag(c *fiber.Ctx, version int64) {
+	c.Set(fiber.HeaderETag, strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// IfMatch returns the versions listed in the If-Match header. It returns nil when the header is
+// missing or "*", and an empty non-nil slice when no listed tag is a version so that nothing matches.
+func IfMatch(c *fiber.Ctx) []int64 {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	versions := []int64{}
+	for tag := range strings.SplitSeq(header, ",") {
+		// weak tags never match If-Match
+		value, err := strconv.Unquote(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions
+}
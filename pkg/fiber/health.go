@@ -0,0 +1,29 @@
+package fx
+
+import (
+	"net/http"
+
+	"github.com/chai-rs/sevenhunter/pkg/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+// livez reports that the process is up, it never checks dependencies
+func livez(c *fiber.Ctx) error {
+	return Ok(c, health.Report{Status: health.StatusOK})
+}
+
+// readyz reports whether every registered dependency is usable and the server is not draining
+func readyz(registry *health.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := registry.Ready(c.UserContext())
+		if report.Healthy() {
+			return Ok(c, report)
+		}
+
+		return c.Status(http.StatusServiceUnavailable).JSON(Response{
+			Success: false,
+			Message: "service not ready",
+			Result:  report,
+		})
+	}
+}
@@ -15,6 +15,26 @@ type Response struct {
 	Result  any    `json:"result,omitempty"`                                             // The actual response data
 }
 
+// Pagination carries the cursors of a paginated result
+type Pagination struct {
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJzIjoiY3JlYXRlZF9hdCJ9.c2lnbmF0dXJl"` // Cursor to request the next page, empty on the last page
+	PrevCursor string `json:"prev_cursor,omitempty" example:"eyJzIjoiY3JlYXRlZF9hdCJ9.c2lnbmF0dXJl"` // Cursor to request the previous page, empty on the first page
+	HasMore    bool   `json:"has_more" example:"true"`                                               // Indicates if there are items after this page
+}
+
+// PaginatedResponse represents the standard API response wrapper for paginated results
+type PaginatedResponse struct {
+	Success    bool       `json:"success" example:"true"`                                       // Indicates if the request was successful
+	Message    string     `json:"message,omitempty" example:"Operation completed successfully"` // Error or informational message
+	Result     any        `json:"result,omitempty"`                                             // The page items
+	Pagination Pagination `json:"pagination"`                                                   // Cursors to navigate between pages
+}
+
+// NoStore keeps caches and idempotent replays from storing a response, e.g. one that carries credentials
+func NoStore(c *fiber.Ctx) {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+}
+
 func Ok(c *fiber.Ctx, result ...any) error {
 	resp := Response{
 		Success: true,
@@ -39,6 +59,14 @@ func Created(c *fiber.Ctx, result ...any) error {
 	return c.Status(fiber.StatusCreated).JSON(resp)
 }
 
+func Paginated(c *fiber.Ctx, result any, pagination Pagination) error {
+	return c.Status(fiber.StatusOK).JSON(PaginatedResponse{
+		Success:    true,
+		Result:     result,
+		Pagination: pagination,
+	})
+}
+
 func ErrorHandler(c *fiber.Ctx, err error) error {
 	resp := Response{
 		Success: false,
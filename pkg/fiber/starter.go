@@ -8,27 +8,32 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/chai-rs/sevenhunter/pkg/health"
 	logx "github.com/chai-rs/sevenhunter/pkg/logger"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/healthcheck"
 )
 
-const DefaultAddr = ":8080"
+const (
+	DefaultAddr       = ":8080"
+	DefaultDrainDelay = 5 * time.Second
+)
 
 var DefaultStartOpts = StartOpts{
 	Address:    DefaultAddr,
 	ShutdownFn: func() error { return nil },
+	DrainDelay: DefaultDrainDelay,
 }
 
 type StartOpts struct {
 	Address    string
 	ShutdownFn func() error
+	// Health backs the /readyz probe, an empty registry is used when nil
+	Health *health.Registry
+	// DrainDelay is how long /readyz reports not-ready before the server stops accepting requests
+	DrainDelay time.Duration
 }
 
 func Start(app *fiber.App, opts ...StartOpts) error {
-	app.Use(healthcheck.New())
-	app.Use(notfound)
-
 	opt := DefaultStartOpts
 	if len(opts) > 0 {
 		opt = opts[0]
@@ -39,6 +44,15 @@ func Start(app *fiber.App, opts ...StartOpts) error {
 		addr = DefaultAddr
 	}
 
+	registry := opt.Health
+	if registry == nil {
+		registry = health.NewRegistry()
+	}
+
+	app.Get("/livez", livez)
+	app.Get("/readyz", readyz(registry))
+	app.Use(notfound)
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
@@ -46,6 +60,13 @@ func Start(app *fiber.App, opts ...StartOpts) error {
 		<-shutdown
 		logx.Info().Msg("shutting down...")
 
+		// fail readiness first so load balancers stop routing before connections drain
+		registry.Drain()
+		if opt.DrainDelay > 0 {
+			logx.Info().Dur("delay", opt.DrainDelay).Msg("draining traffic...")
+			time.Sleep(opt.DrainDelay)
+		}
+
 		if err := opt.ShutdownFn(); err != nil {
 			logx.Error().Err(err).Msg("shutdown error")
 		}
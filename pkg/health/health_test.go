@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Ready(t *testing.T) {
+	type Testcase struct {
+		name     string
+		checks   map[string]error
+		drain    bool
+		expected string
+	}
+
+	testcases := []Testcase{
+		{
+			name:     "ready without checks",
+			expected: StatusOK,
+		},
+		{
+			name: "ready when every check passes",
+			checks: map[string]error{
+				"mongo":     nil,
+				"scheduler": nil,
+			},
+			expected: StatusOK,
+		},
+		{
+			name: "not ready when a check fails",
+			checks: map[string]error{
+				"mongo":     errors.New("connection refused"),
+				"scheduler": nil,
+			},
+			expected: StatusFail,
+		},
+		{
+			name: "not ready while draining",
+			checks: map[string]error{
+				"mongo": nil,
+			},
+			drain:    true,
+			expected: StatusDraining,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry()
+			for name, err := range tc.checks {
+				r.Register(name, CheckerFunc(func(ctx context.Context) error { return err }))
+			}
+
+			if tc.drain {
+				r.Drain()
+			}
+
+			report := r.Ready(context.Background())
+			require.Equal(t, tc.expected, report.Status)
+			require.Len(t, report.Checks, len(tc.checks))
+
+			for name, err := range tc.checks {
+				if err != nil {
+					require.Equal(t, StatusFail, report.Checks[name].Status)
+					require.Equal(t, err.Error(), report.Checks[name].Error)
+				} else {
+					require.Equal(t, StatusOK, report.Checks[name].Status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/schema"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const mongoCollection = "idempotency_keys"
+
+type mongoRecord struct {
+	Key         string    `bson:"_id"`
+	Principal   string    `bson:"principal"`
+	RequestHash string    `bson:"request_hash"`
+	Completed   bool      `bson:"completed"`
+	StatusCode  int       `bson:"status_code,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+func (r *mongoRecord) toRecord() *Record {
+	return &Record{
+		Key:         r.Key,
+		Principal:   r.Principal,
+		RequestHash: r.RequestHash,
+		Completed:   r.Completed,
+		StatusCode:  r.StatusCode,
+		ContentType: r.ContentType,
+		Body:        r.Body,
+		ExpiresAt:   r.ExpiresAt,
+	}
+}
+
+// MongoStore shares records between replicas, expired records are removed by a TTL index on expires_at
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		collection: db.Collection(mongoCollection),
+	}
+}
+
+var _ Store = (*MongoStore)(nil)
+
+// MongoSchema is the idempotency_keys collection MongoStore relies on
+var MongoSchema = schema.Collection{
+	Name: mongoCollection,
+	Indexes: []schema.Index{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfterSeconds: lo.ToPtr(int32(0))},
+		{Keys: bson.D{{Key: "principal", Value: 1}}},
+	},
+}
+
+func (s *MongoStore) Begin(ctx context.Context, rec Record) (*Record, error) {
+	now := time.Now()
+
+	// claim the key when it is free or its previous record expired, the TTL monitor only runs once a minute
+	filter := bson.M{
+		"_id":        rec.Key,
+		"expires_at": bson.M{"$lte": now},
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"principal":    rec.Principal,
+			"request_hash": rec.RequestHash,
+			"completed":    false,
+			"created_at":   now,
+			"expires_at":   rec.ExpiresAt,
+		},
+		"$unset": bson.M{
+			"status_code":  "",
+			"content_type": "",
+			"body":         "",
+		},
+	}
+
+	_, err := s.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err == nil {
+		return nil, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var existing mongoRecord
+	if err := s.collection.FindOne(ctx, bson.M{"_id": rec.Key}).Decode(&existing); err != nil {
+		return nil, err
+	}
+
+	return existing.toRecord(), nil
+}
+
+func (s *MongoStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte, expiresAt time.Time) error {
+	update := bson.M{
+		"$set": bson.M{
+			"completed":    true,
+			"status_code":  statusCode,
+			"content_type": contentType,
+			"body":         body,
+			"expires_at":   expiresAt,
+		},
+	}
+
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": key}, update)
+	return err
+}
+
+func (s *MongoStore) Release(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func (s *MongoStore) DeletePrincipal(ctx context.Context, principal string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"principal": principal})
+	return err
+}
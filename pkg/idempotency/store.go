@@ -0,0 +1,30 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Record is the stored outcome of the first request made with an idempotency key
+type Record struct {
+	Key         string
+	Principal   string
+	RequestHash string
+	Completed   bool
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+type Store interface {
+	// Begin claims rec.Key for an in-flight request. When the key is already claimed and not expired
+	// the existing record is returned and nothing is written.
+	Begin(ctx context.Context, rec Record) (*Record, error)
+	// Complete stores the response of a claimed key and keeps it until expiresAt
+	Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte, expiresAt time.Time) error
+	// Release drops a claimed key so the request can be retried
+	Release(ctx context.Context, key string) error
+	// DeletePrincipal drops every record owned by principal
+	DeletePrincipal(ctx context.Context, principal string) error
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
@@ -64,6 +66,21 @@ func (tm *TokenManager) VerifyTokenWithClaims(tokenStr string, claims jwt.Claims
 	return nil, errx.M(http.StatusUnauthorized, "unauthorized")
 }
 
+// Check verifies that a signing key is loaded and usable by signing and verifying a probe token
+func (tm *TokenManager) Check(ctx context.Context) error {
+	if tm.config.Secret == "" {
+		return errors.New("signing key not loaded")
+	}
+
+	token, err := tm.SignClaims(jwt.RegisteredClaims{Subject: "healthcheck"})
+	if err != nil {
+		return err
+	}
+
+	_, err = tm.VerifyToken(token)
+	return err
+}
+
 func (tm *TokenManager) AccessTokenExpiresAt(now time.Time) *jwt.NumericDate {
 	return jwt.NewNumericDate(now.Add(tm.config.AccessTokenTTL))
 }
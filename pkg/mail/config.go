@@ -0,0 +1,29 @@
+package mail
+
+import "fmt"
+
+type Config struct {
+	Driver       string `default:"log"`
+	From         string `default:"SevenHunter <no-reply@sevenhunter.local>"`
+	SMTPHost     string `split_words:"true" default:"localhost"`
+	SMTPPort     int    `split_words:"true" default:"587"`
+	SMTPUsername string `split_words:"true"`
+	SMTPPassword string `split_words:"true"`
+}
+
+func (c *Config) New() (Mailer, error) {
+	switch c.Driver {
+	case "log":
+		return NewLogMailer(), nil
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     c.SMTPHost,
+			Port:     c.SMTPPort,
+			Username: c.SMTPUsername,
+			Password: c.SMTPPassword,
+			From:     c.From,
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unknown mail driver %q", c.Driver)
+}
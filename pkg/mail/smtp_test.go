@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type received struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts a single session on a local port and records what was sent
+func fakeSMTP(t *testing.T) (host string, port int, result <-chan received) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan received, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var r received
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				r.from = arg
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				r.to = append(r.to, arg)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				r.data = string(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				ch <- r
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, ch
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	host, port, result := fakeSMTP(t)
+
+	mailer := NewSMTPMailer(SMTPConfig{
+		Host: host,
+		Port: port,
+		From: "SevenHunter <no-reply@example.com>",
+	})
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "john@example.com",
+		Subject: "Confirm your new email",
+		Body:    "line one\nline two",
+	})
+	require.NoError(t, err)
+
+	r := <-result
+	require.Equal(t, "FROM:<no-reply@example.com>", r.from)
+	require.Equal(t, []string{"TO:<john@example.com>"}, r.to)
+
+	headers, body, ok := strings.Cut(r.data, "\n\n")
+	require.True(t, ok)
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(headers + "\n\n"))).ReadMIMEHeader()
+	require.NoError(t, err)
+	require.Equal(t, "SevenHunter <no-reply@example.com>", msg.Get("From"))
+	require.Equal(t, "john@example.com", msg.Get("To"))
+	require.Equal(t, "Confirm your new email", msg.Get("Subject"))
+	require.Equal(t, "text/plain; charset=utf-8", msg.Get("Content-Type"))
+	require.Equal(t, "line one\nline two\n", body)
+}
+
+func TestSMTPMailer_SendFailsWhenServerIsDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	mailer := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: port, From: "no-reply@example.com"})
+	err = mailer.Send(context.Background(), Message{To: "john@example.com", Subject: "hi", Body: "hi"})
+	require.Error(t, err)
+}
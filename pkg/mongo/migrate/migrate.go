@@ -0,0 +1,249 @@
+// Package migrate applies versioned changes to a Mongo database. Applied migrations are recorded in the
+// changelog collection and a document in changelog_lock keeps two runners from migrating at the same time,
+// the same collections migrate-mongo uses, so databases it migrated carry on where it stopped.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ChangelogCollection = "changelog"
+	LockCollection      = "changelog_lock"
+
+	// DefaultLockTTL is how long a lock is held before another runner may take it over
+	DefaultLockTTL = 10 * time.Minute
+
+	lockID = "migrate"
+	// legacySuffix ends the changelog entries written by migrate-mongo, which are named after the .js file
+	legacySuffix = ".js"
+)
+
+var (
+	ErrLocked        = errors.New("another migration is running, wait for it or remove the lock from changelog_lock")
+	ErrNothingToUndo = errors.New("no migration has been applied")
+)
+
+// Migration is one change to the database. ID orders migrations and is recorded once Up succeeds,
+// it starts with a timestamp such as 20261018200000-login-history.
+type Migration struct {
+	ID   string
+	Up   func(ctx context.Context, db *mongo.Database) error
+	Down func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status is a known migration and when it was applied, AppliedAt is zero while it is pending
+type Status struct {
+	ID        string
+	AppliedAt time.Time
+}
+
+func (s Status) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// changelogEntry keeps the field names of migrate-mongo
+type changelogEntry struct {
+	FileName  string    `bson:"fileName"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+type lockDocument struct {
+	ID        string    `bson:"_id"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+type Opts struct {
+	DB         *mongo.Database
+	Migrations []Migration
+	// LockTTL defaults to DefaultLockTTL
+	LockTTL time.Duration
+}
+
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	changelog  *mongo.Collection
+	lock       *mongo.Collection
+	lockTTL    time.Duration
+}
+
+// New checks that the migrations have distinct ids and sorts them
+func New(opts Opts) (*Migrator, error) {
+	migrations := slices.Clone(opts.Migrations)
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	for i, m := range migrations {
+		if m.ID == "" || m.Up == nil {
+			return nil, fmt.Errorf("migration %q needs an id and an up function", m.ID)
+		}
+		if i > 0 && migrations[i-1].ID == m.ID {
+			return nil, fmt.Errorf("migration %q is defined twice", m.ID)
+		}
+	}
+
+	return &Migrator{
+		db:         opts.DB,
+		migrations: migrations,
+		changelog:  opts.DB.Collection(ChangelogCollection),
+		lock:       opts.DB.Collection(LockCollection),
+		lockTTL:    cmp.Or(opts.LockTTL, DefaultLockTTL),
+	}, nil
+}
+
+// Up applies the pending migrations in order and returns their ids, it stops at the first one that fails
+func (m *Migrator) Up(ctx context.Context) (applied []string, err error) {
+	unlock, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := done[migration.ID]; ok {
+			continue
+		}
+
+		if err := migration.Up(ctx, m.db); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", migration.ID, err)
+		}
+
+		entry := changelogEntry{FileName: migration.ID, AppliedAt: time.Now()}
+		if _, err := m.changelog.InsertOne(ctx, entry); err != nil {
+			return applied, fmt.Errorf("record migration %s: %w", migration.ID, err)
+		}
+
+		logx.Info().Str("migration", migration.ID).Msg("applied migration")
+		applied = append(applied, migration.ID)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the migration applied last and returns its id
+func (m *Migrator) Down(ctx context.Context) (reverted string, err error) {
+	unlock, err := m.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	done, err := m.applied(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var last *Migration
+	for i := range m.migrations {
+		at, ok := done[m.migrations[i].ID]
+		if !ok {
+			continue
+		}
+		// migrations are sorted, so the later id wins when several were applied at the same time
+		if last == nil || !at.Before(done[last.ID]) {
+			last = &m.migrations[i]
+		}
+	}
+	if last == nil {
+		return "", ErrNothingToUndo
+	}
+	if last.Down == nil {
+		return "", fmt.Errorf("migration %s cannot be reverted", last.ID)
+	}
+
+	if err := last.Down(ctx, m.db); err != nil {
+		return "", fmt.Errorf("migration %s: %w", last.ID, err)
+	}
+
+	filter := bson.M{"fileName": bson.M{"$in": bson.A{last.ID, last.ID + legacySuffix}}}
+	if _, err := m.changelog.DeleteMany(ctx, filter); err != nil {
+		return "", fmt.Errorf("unrecord migration %s: %w", last.ID, err)
+	}
+
+	logx.Info().Str("migration", last.ID).Msg("reverted migration")
+	return last.ID, nil
+}
+
+// Status lists every known migration in order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		statuses = append(statuses, Status{ID: migration.ID, AppliedAt: done[migration.ID]})
+	}
+
+	return statuses, nil
+}
+
+// applied returns when each recorded migration was applied, by id
+func (m *Migrator) applied(ctx context.Context) (map[string]time.Time, error) {
+	cursor, err := m.changelog.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []changelogEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		done[strings.TrimSuffix(entry.FileName, legacySuffix)] = entry.AppliedAt
+	}
+
+	return done, nil
+}
+
+// acquire takes the lock and returns the function that releases it. A lock older than the TTL is
+// left by a runner that died and is taken over.
+func (m *Migrator) acquire(ctx context.Context) (func() error, error) {
+	now := time.Now()
+	if _, err := m.lock.DeleteMany(ctx, bson.M{"createdAt": bson.M{"$lt": now.Add(-m.lockTTL)}}); err != nil {
+		return nil, err
+	}
+
+	// migrate-mongo locks with a document of its own, any document means the lock is held
+	count, err := m.lock.CountDocuments(ctx, bson.M{}, options.Count().SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrLocked
+	}
+
+	_, err = m.lock.InsertOne(ctx, lockDocument{ID: lockID, CreatedAt: now})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		_, err := m.lock.DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": lockID})
+		return err
+	}, nil
+}
@@ -0,0 +1,176 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDatabase returns an empty database that is dropped after the test,
+// the test is skipped unless MONGO_TEST_URI points at a server that can be written to
+func newTestDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	require.NoError(t, client.Ping(ctx, nil))
+
+	db := client.Database("sevenhunter_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return db
+}
+
+// recorder returns migrations that only write their id to the log collection
+func recorder(ids ...string) []Migration {
+	migrations := make([]Migration, 0, len(ids))
+	for _, id := range ids {
+		migrations = append(migrations, Migration{
+			ID: id,
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("log").InsertOne(ctx, bson.M{"_id": id})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("log").DeleteOne(ctx, bson.M{"_id": id})
+				return err
+			},
+		})
+	}
+	return migrations
+}
+
+func TestNew(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	require.NoError(t, err)
+	db := client.Database("unused")
+
+	_, err = New(Opts{DB: db, Migrations: recorder("1-a", "2-b", "1-a")})
+	require.ErrorContains(t, err, "defined twice")
+
+	_, err = New(Opts{DB: db, Migrations: []Migration{{ID: "1-a"}}})
+	require.ErrorContains(t, err, "up function")
+
+	m, err := New(Opts{DB: db, Migrations: recorder("2-b", "1-a")})
+	require.NoError(t, err)
+	require.Equal(t, "1-a", m.migrations[0].ID)
+	require.Equal(t, DefaultLockTTL, m.lockTTL)
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("up, status and down", func(t *testing.T) {
+		db := newTestDatabase(t)
+		m, err := New(Opts{DB: db, Migrations: recorder("2-b", "1-a", "3-c")})
+		require.NoError(t, err)
+
+		applied, err := m.Up(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1-a", "2-b", "3-c"}, applied)
+
+		applied, err = m.Up(ctx)
+		require.NoError(t, err)
+		require.Empty(t, applied)
+
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, statuses, 3)
+		for _, status := range statuses {
+			require.True(t, status.Applied(), status.ID)
+		}
+
+		reverted, err := m.Down(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "3-c", reverted)
+
+		statuses, err = m.Status(ctx)
+		require.NoError(t, err)
+		require.False(t, statuses[2].Applied())
+
+		count, err := db.Collection("log").CountDocuments(ctx, bson.M{})
+		require.NoError(t, err)
+		require.EqualValues(t, 2, count)
+	})
+
+	t.Run("entries written by migrate-mongo count as applied", func(t *testing.T) {
+		db := newTestDatabase(t)
+		_, err := db.Collection(ChangelogCollection).InsertOne(ctx, bson.M{"fileName": "1-a.js", "appliedAt": time.Now()})
+		require.NoError(t, err)
+
+		m, err := New(Opts{DB: db, Migrations: recorder("1-a", "2-b")})
+		require.NoError(t, err)
+
+		applied, err := m.Up(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2-b"}, applied)
+
+		_, err = m.Down(ctx)
+		require.NoError(t, err)
+		reverted, err := m.Down(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "1-a", reverted)
+
+		count, err := db.Collection(ChangelogCollection).CountDocuments(ctx, bson.M{})
+		require.NoError(t, err)
+		require.Zero(t, count)
+
+		_, err = m.Down(ctx)
+		require.ErrorIs(t, err, ErrNothingToUndo)
+	})
+
+	t.Run("a failed migration stops the run", func(t *testing.T) {
+		db := newTestDatabase(t)
+		migrations := recorder("1-a", "3-c")
+		migrations = append(migrations, Migration{
+			ID: "2-b",
+			Up: func(context.Context, *mongo.Database) error { return mongo.ErrNilDocument },
+		})
+		m, err := New(Opts{DB: db, Migrations: migrations})
+		require.NoError(t, err)
+
+		applied, err := m.Up(ctx)
+		require.ErrorIs(t, err, mongo.ErrNilDocument)
+		require.Equal(t, []string{"1-a"}, applied)
+
+		// the lock is released after a failure
+		count, err := db.Collection(LockCollection).CountDocuments(ctx, bson.M{})
+		require.NoError(t, err)
+		require.Zero(t, count)
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		db := newTestDatabase(t)
+		m, err := New(Opts{DB: db, Migrations: recorder("1-a"), LockTTL: time.Minute})
+		require.NoError(t, err)
+
+		// held by migrate-mongo or another runner
+		_, err = db.Collection(LockCollection).InsertOne(ctx, bson.M{"createdAt": time.Now()})
+		require.NoError(t, err)
+		_, err = m.Up(ctx)
+		require.ErrorIs(t, err, ErrLocked)
+
+		// left behind by a runner that died
+		_, err = db.Collection(LockCollection).UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"createdAt": time.Now().Add(-time.Hour)}})
+		require.NoError(t, err)
+		applied, err := m.Up(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1-a"}, applied)
+	})
+}
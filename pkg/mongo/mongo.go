@@ -2,19 +2,33 @@ package mongo
 
 import (
 	"context"
+	"errors"
 
+	"github.com/chai-rs/sevenhunter/pkg/health"
 	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
+// Config is only required when something is stored in Mongo, New fails without it
 type Config struct {
-	Database string `split_words:"true" required:"true"`
-	URI      string `envconfig:"URI" required:"true"`
+	Database string `split_words:"true"`
+	URI      string `envconfig:"URI"`
+	// SchemaMode is ensure or verify, see schema.Reconcile
+	SchemaMode string `split_words:"true" default:"ensure"`
+	// SchemaStrict refuses to start while collections drift from what the stores expect
+	SchemaStrict bool `split_words:"true"`
 }
 
 func (conf *Config) New(ctx context.Context) (*mongo.Client, error) {
-	opts := options.Client().ApplyURI(conf.URI)
+	if conf.URI == "" || conf.Database == "" {
+		return nil, errors.New("MONGO_URI and MONGO_DATABASE are required")
+	}
+
+	opts := options.Client().ApplyURI(conf.URI).SetMonitor(otelmongo.NewMonitor())
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
@@ -42,3 +56,24 @@ func (conf *Config) MustDatabase() *mongo.Database {
 	}
 	return client.Database(conf.Database)
 }
+
+// SupportsTransactions reports whether the deployment is a replica set or a sharded cluster,
+// a standalone server cannot run transactions
+func SupportsTransactions(ctx context.Context, client *mongo.Client) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err
+	}
+
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// HealthChecker pings the primary of the client's deployment
+func HealthChecker(client *mongo.Client) health.CheckerFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	}
+}
@@ -0,0 +1,354 @@
+// Package schema checks the indexes and validators of Mongo collections against what the code expects.
+// Collections are created by the migrations, Reconcile only looks at the ones that already exist and
+// either repairs what is missing or reports it.
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Mode string
+
+const (
+	// ModeEnsure creates missing indexes and replaces a validator that differs
+	ModeEnsure Mode = "ensure"
+	// ModeVerify only reports the drift
+	ModeVerify Mode = "verify"
+)
+
+var ErrDrift = errors.New("mongo schema does not match")
+
+// Collection is what a store expects of its collection, a nil Validator is not checked
+type Collection struct {
+	Name      string
+	Validator bson.M
+	Indexes   []Index
+}
+
+// Index is matched on its keys, other indexes on the collection are left alone
+type Index struct {
+	Keys               bson.D
+	Unique             bool
+	Sparse             bool
+	ExpireAfterSeconds *int32
+	PartialFilter      bson.M
+}
+
+// Name is the name Mongo gives the index by default, such as email_1 or user_id_1_at_-1
+func (i Index) Name() string {
+	parts := make([]string, 0, len(i.Keys))
+	for _, key := range i.Keys {
+		parts = append(parts, fmt.Sprintf("%s_%v", key.Key, key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func (i Index) model() mongo.IndexModel {
+	opts := options.Index()
+	if i.Unique {
+		opts.SetUnique(true)
+	}
+	if i.Sparse {
+		opts.SetSparse(true)
+	}
+	if i.ExpireAfterSeconds != nil {
+		opts.SetExpireAfterSeconds(*i.ExpireAfterSeconds)
+	}
+	if i.PartialFilter != nil {
+		opts.SetPartialFilterExpression(i.PartialFilter)
+	}
+
+	return mongo.IndexModel{Keys: i.Keys, Options: opts}
+}
+
+// Drift is one difference between a collection and its spec, Fixed is set once ModeEnsure repaired it
+type Drift struct {
+	Collection string
+	Problem    string
+	Fixed      bool
+}
+
+func (d Drift) String() string {
+	return d.Collection + ": " + d.Problem
+}
+
+type Opts struct {
+	DB          *mongo.Database
+	Collections []Collection
+	// Mode defaults to ModeEnsure
+	Mode Mode
+	// Strict makes Reconcile fail with ErrDrift while any drift is left
+	Strict bool
+}
+
+// existingIndex is an entry of listIndexes
+type existingIndex struct {
+	Name               string `bson:"name"`
+	Key                bson.D `bson:"key"`
+	Unique             bool   `bson:"unique"`
+	Sparse             bool   `bson:"sparse"`
+	ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	PartialFilter      bson.M `bson:"partialFilterExpression"`
+}
+
+// Reconcile compares every collection with its spec, logs the drift it finds and returns it.
+// An error other than ErrDrift means the database could not be inspected.
+func Reconcile(ctx context.Context, opts Opts) ([]Drift, error) {
+	mode := opts.Mode
+	switch mode {
+	case "":
+		mode = ModeEnsure
+	case ModeEnsure, ModeVerify:
+	default:
+		return nil, fmt.Errorf("unknown schema mode %q", mode)
+	}
+
+	var drifts []Drift
+	for _, spec := range opts.Collections {
+		found, err := reconcileCollection(ctx, opts.DB, spec, mode)
+		if err != nil {
+			return drifts, fmt.Errorf("reconcile %s: %w", spec.Name, err)
+		}
+		drifts = append(drifts, found...)
+	}
+
+	var left []string
+	for _, drift := range drifts {
+		if drift.Fixed {
+			logx.Warn().Str("collection", drift.Collection).Msg("repaired schema drift: " + drift.Problem)
+			continue
+		}
+
+		logx.Error().Str("collection", drift.Collection).Str("mode", string(mode)).Msg("schema drift: " + drift.Problem)
+		left = append(left, drift.String())
+	}
+
+	if opts.Strict && len(left) > 0 {
+		return drifts, fmt.Errorf("%w:\n%s", ErrDrift, strings.Join(left, "\n"))
+	}
+
+	return drifts, nil
+}
+
+func reconcileCollection(ctx context.Context, db *mongo.Database, spec Collection, mode Mode) ([]Drift, error) {
+	specs, err := db.ListCollectionSpecifications(ctx, bson.M{"name": spec.Name})
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		// Creating it here would make the migration that creates it fail, so it is only reported
+		return []Drift{{Collection: spec.Name, Problem: "collection does not exist, run the migrations"}}, nil
+	}
+
+	var drifts []Drift
+	if spec.Validator != nil {
+		drift, err := reconcileValidator(ctx, db, spec, specs[0].Options, mode)
+		if err != nil {
+			return nil, err
+		}
+		if drift != nil {
+			drifts = append(drifts, *drift)
+		}
+	}
+
+	found, err := reconcileIndexes(ctx, db.Collection(spec.Name), spec, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(drifts, found...), nil
+}
+
+func reconcileValidator(ctx context.Context, db *mongo.Database, spec Collection, options bson.Raw, mode Mode) (*Drift, error) {
+	var current any
+	if value, err := options.LookupErr("validator"); err == nil {
+		current = value.Document()
+	}
+
+	same, err := equal(current, spec.Validator)
+	if err != nil {
+		return nil, err
+	}
+	if same {
+		return nil, nil
+	}
+
+	drift := &Drift{Collection: spec.Name, Problem: "validator differs from the expected one"}
+	if current == nil {
+		drift.Problem = "validator is missing"
+	}
+	if mode != ModeEnsure {
+		return drift, nil
+	}
+
+	err = db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: spec.Name},
+		{Key: "validator", Value: spec.Validator},
+	}).Err()
+	if err != nil {
+		drift.Problem += ", replacing it failed: " + err.Error()
+		return drift, nil
+	}
+
+	drift.Fixed = true
+	return drift, nil
+}
+
+func reconcileIndexes(ctx context.Context, collection *mongo.Collection, spec Collection, mode Mode) ([]Drift, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing []existingIndex
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+	for _, index := range spec.Indexes {
+		match, err := findIndex(existing, index.Keys)
+		if err != nil {
+			return nil, err
+		}
+
+		if match != nil {
+			// Options of an existing index cannot be changed in place and dropping it is not safe
+			// while the application is serving, so a mismatch is only reported
+			problems, err := compareIndex(*match, index)
+			if err != nil {
+				return nil, err
+			}
+			for _, problem := range problems {
+				drifts = append(drifts, Drift{Collection: spec.Name, Problem: "index " + match.Name + " " + problem})
+			}
+			continue
+		}
+
+		drift := Drift{Collection: spec.Name, Problem: "index " + index.Name() + " is missing"}
+		if mode == ModeEnsure {
+			if _, err := collection.Indexes().CreateOne(ctx, index.model()); err != nil {
+				drift.Problem += ", creating it failed: " + err.Error()
+			} else {
+				drift.Fixed = true
+			}
+		}
+		drifts = append(drifts, drift)
+	}
+
+	return drifts, nil
+}
+
+func findIndex(existing []existingIndex, keys bson.D) (*existingIndex, error) {
+	for i := range existing {
+		if len(existing[i].Key) != len(keys) {
+			continue
+		}
+
+		same := true
+		for j, key := range keys {
+			current := existing[i].Key[j]
+			if current.Key != key.Key {
+				same = false
+				break
+			}
+
+			// Key directions may be stored as int32, int64 or double
+			ok, err := equal(bson.M{"v": current.Value}, bson.M{"v": key.Value})
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				same = false
+				break
+			}
+		}
+		if same {
+			return &existing[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// compareIndex describes how an existing index differs from its spec
+func compareIndex(current existingIndex, index Index) ([]string, error) {
+	var problems []string
+	if current.Unique != index.Unique {
+		problems = append(problems, fmt.Sprintf("should have unique %t", index.Unique))
+	}
+	if current.Sparse != index.Sparse {
+		problems = append(problems, fmt.Sprintf("should have sparse %t", index.Sparse))
+	}
+
+	switch {
+	case index.ExpireAfterSeconds == nil && current.ExpireAfterSeconds != nil:
+		problems = append(problems, "should not expire documents")
+	case index.ExpireAfterSeconds != nil && current.ExpireAfterSeconds == nil:
+		problems = append(problems, fmt.Sprintf("should expire documents after %ds", *index.ExpireAfterSeconds))
+	case index.ExpireAfterSeconds != nil && int64(*index.ExpireAfterSeconds) != *current.ExpireAfterSeconds:
+		problems = append(problems, fmt.Sprintf("should expire documents after %ds, not %ds",
+			*index.ExpireAfterSeconds, *current.ExpireAfterSeconds))
+	}
+
+	var filter any
+	if current.PartialFilter != nil {
+		filter = current.PartialFilter
+	}
+	var expected any
+	if index.PartialFilter != nil {
+		expected = index.PartialFilter
+	}
+	same, err := equal(filter, expected)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		problems = append(problems, "has a different partial filter")
+	}
+
+	return problems, nil
+}
+
+// equal compares two documents the way Mongo would, ignoring the order of fields and whether
+// a number is stored as int32, int64 or double. Two nil documents are equal.
+func equal(a, b any) (bool, error) {
+	if a == nil || b == nil {
+		return a == nil && b == nil, nil
+	}
+
+	x, err := normalize(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := normalize(b)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(x, y), nil
+}
+
+func normalize(doc any) (any, error) {
+	data, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestDatabase returns an empty database that is dropped after the test,
+// the test is skipped unless MONGO_TEST_URI points at a server that can be written to
+func newTestDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	require.NoError(t, client.Ping(ctx, nil))
+
+	db := client.Database("sevenhunter_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		_ = db.Drop(ctx)
+		_ = client.Disconnect(ctx)
+	})
+
+	return db
+}
+
+func TestIndexName(t *testing.T) {
+	index := Index{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "at", Value: -1}, {Key: "_id", Value: -1}}}
+	require.Equal(t, "user_id_1_at_-1__id_-1", index.Name())
+}
+
+func TestEqual(t *testing.T) {
+	same, err := equal(
+		bson.M{"$jsonSchema": bson.M{"required": bson.A{"email"}, "maxLength": int32(10)}},
+		bson.D{{Key: "$jsonSchema", Value: bson.D{{Key: "maxLength", Value: 10.0}, {Key: "required", Value: bson.A{"email"}}}}},
+	)
+	require.NoError(t, err)
+	require.True(t, same)
+
+	same, err = equal(bson.M{"required": bson.A{"email", "name"}}, bson.M{"required": bson.A{"name", "email"}})
+	require.NoError(t, err)
+	require.False(t, same)
+
+	same, err = equal(nil, bson.M{})
+	require.NoError(t, err)
+	require.False(t, same)
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	users := Collection{
+		Name: "users",
+		Indexes: []Index{
+			{Keys: bson.D{{Key: "email", Value: 1}}, Unique: true},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfterSeconds: lo.ToPtr(int32(0))},
+		},
+	}
+
+	t.Run("missing collection is only reported", func(t *testing.T) {
+		db := newTestDatabase(t)
+
+		drifts, err := Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}})
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.False(t, drifts[0].Fixed)
+		require.Contains(t, drifts[0].Problem, "does not exist")
+
+		names, err := db.ListCollectionNames(ctx, bson.M{})
+		require.NoError(t, err)
+		require.Empty(t, names)
+	})
+
+	t.Run("verify reports missing indexes", func(t *testing.T) {
+		db := newTestDatabase(t)
+		require.NoError(t, db.CreateCollection(ctx, "users"))
+
+		drifts, err := Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}, Mode: ModeVerify})
+		require.NoError(t, err)
+		require.Len(t, drifts, 2)
+		require.Equal(t, "index email_1 is missing", drifts[0].Problem)
+		require.False(t, drifts[0].Fixed)
+
+		_, err = Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}, Mode: ModeVerify, Strict: true})
+		require.ErrorIs(t, err, ErrDrift)
+		require.ErrorContains(t, err, "users: index email_1 is missing")
+	})
+
+	t.Run("ensure creates missing indexes", func(t *testing.T) {
+		db := newTestDatabase(t)
+		require.NoError(t, db.CreateCollection(ctx, "users"))
+
+		drifts, err := Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}, Strict: true})
+		require.NoError(t, err)
+		require.Len(t, drifts, 2)
+		require.True(t, drifts[0].Fixed)
+		require.True(t, drifts[1].Fixed)
+
+		drifts, err = Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}, Mode: ModeVerify, Strict: true})
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+
+	t.Run("index with other options is reported", func(t *testing.T) {
+		db := newTestDatabase(t)
+		_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "email", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(60)},
+		})
+		require.NoError(t, err)
+
+		drifts, err := Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}})
+		require.NoError(t, err)
+		require.Len(t, drifts, 2)
+		require.Equal(t, "index email_1 should have unique true", drifts[0].Problem)
+		require.Equal(t, "index expires_at_1 should expire documents after 0s, not 60s", drifts[1].Problem)
+		require.False(t, drifts[0].Fixed)
+
+		_, err = Reconcile(ctx, Opts{DB: db, Collections: []Collection{users}, Strict: true})
+		require.ErrorIs(t, err, ErrDrift)
+	})
+
+	t.Run("validator", func(t *testing.T) {
+		db := newTestDatabase(t)
+		require.NoError(t, db.CreateCollection(ctx, "users"))
+		spec := Collection{
+			Name:      "users",
+			Validator: bson.M{"$jsonSchema": bson.M{"bsonType": "object", "required": bson.A{"email"}}},
+		}
+
+		drifts, err := Reconcile(ctx, Opts{DB: db, Collections: []Collection{spec}, Mode: ModeVerify})
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.Equal(t, "validator is missing", drifts[0].Problem)
+
+		drifts, err = Reconcile(ctx, Opts{DB: db, Collections: []Collection{spec}})
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.True(t, drifts[0].Fixed)
+
+		drifts, err = Reconcile(ctx, Opts{DB: db, Collections: []Collection{spec}, Mode: ModeVerify})
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		_, err := Reconcile(ctx, Opts{Mode: "repair"})
+		require.ErrorContains(t, err, "unknown schema mode")
+	})
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLockID keys the advisory lock that keeps replicas from migrating at the same time
+const migrationLockID = 7_421_965_301
+
+const createMigrationTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    TEXT PRIMARY KEY,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Migrate applies the *.sql files at the root of fsys that have not been applied yet, in name order.
+// Each file runs in its own transaction and is recorded in schema_migrations under its name.
+func Migrate(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS) error {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// the lock belongs to the session, so it is taken and released on the same connection
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("lock migrations: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID)
+	}()
+
+	if _, err := conn.Exec(ctx, createMigrationTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	for _, name := range names {
+		version := path.Base(name)
+		if err := apply(ctx, conn.Conn(), fsys, name, version); err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, conn *pgx.Conn, fsys fs.FS, name, version string) error {
+	var applied bool
+	err := conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+	if err != nil || applied {
+		return err
+	}
+
+	sql, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		// without arguments the script runs over the simple protocol, which allows several statements
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	logx.Info().Str("version", version).Msg("applied postgres migration")
+	return nil
+}
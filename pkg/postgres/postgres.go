@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/chai-rs/sevenhunter/pkg/health"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Config is only required when users are stored in Postgres, New fails without it.
+// Pool settings such as pool_max_conns are passed in the URL.
+type Config struct {
+	URL string `envconfig:"URL"`
+}
+
+func (conf *Config) New(ctx context.Context) (*pgxpool.Pool, error) {
+	if conf.URL == "" {
+		return nil, errors.New("POSTGRES_URL is required")
+	}
+
+	pool, err := pgxpool.New(ctx, conf.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+func (conf *Config) MustPool() *pgxpool.Pool {
+	pool, err := conf.New(context.Background())
+	if err != nil {
+		logx.Panic().Err(err).Msg("failed to initialize postgres pool")
+	}
+	return pool
+}
+
+// HealthChecker pings a connection of the pool
+func HealthChecker(pool *pgxpool.Pool) health.CheckerFunc {
+	return func(ctx context.Context) error {
+		return pool.Ping(ctx)
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Policy allows Limit requests per Window
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time left until the current window rolls over
+	Reset time.Duration
+}
+
+// Store keeps hit counters per bucket, implementations must make Incr atomic across callers sharing the store
+type Store interface {
+	// Incr records one hit in bucket and returns the bucket's new count, the bucket may be dropped after expiresAt
+	Incr(ctx context.Context, bucket string, expiresAt time.Time) (int64, error)
+	// Count returns the hits recorded in bucket, zero if it does not exist
+	Count(ctx context.Context, bucket string) (int64, error)
+}
+
+// Limiter implements a sliding window counter: the previous window's count is weighted by how much of it still
+// overlaps the sliding window and added to the current window's count.
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+// NewLimiter panics when the policy allows nothing or has no window, both are configuration mistakes
+func NewLimiter(store Store, policy Policy) *Limiter {
+	if policy.Limit <= 0 || policy.Window <= 0 {
+		panic(fmt.Sprintf("ratelimit: limit and window must be positive, got limit %d and window %s", policy.Limit, policy.Window))
+	}
+
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+func (l *Limiter) Policy() Policy {
+	return l.policy
+}
+
+// Take records a hit for key and reports whether it fits in the policy
+func (l *Limiter) Take(ctx context.Context, key string) (Result, error) {
+	now := l.now()
+	window := l.policy.Window
+	current := now.Truncate(window)
+	previous := current.Add(-window)
+
+	count, err := l.store.Incr(ctx, bucket(key, current), current.Add(2*window))
+	if err != nil {
+		return Result{}, err
+	}
+
+	prevCount, err := l.store.Count(ctx, bucket(key, previous))
+	if err != nil {
+		return Result{}, err
+	}
+
+	elapsed := now.Sub(current)
+	weight := float64(window-elapsed) / float64(window)
+	estimate := int(math.Ceil(float64(prevCount)*weight)) + int(count)
+
+	return Result{
+		Allowed:   estimate <= l.policy.Limit,
+		Limit:     l.policy.Limit,
+		Remaining: max(l.policy.Limit-estimate, 0),
+		Reset:     window - elapsed,
+	}, nil
+}
+
+func bucket(key string, window time.Time) string {
+	return fmt.Sprintf("%s:%d", key, window.Unix())
+}
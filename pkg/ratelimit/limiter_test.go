@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Take(t *testing.T) {
+	policy := Policy{Limit: 3, Window: time.Minute}
+	start := time.Now().Truncate(time.Minute)
+
+	type Testcase struct {
+		name     string
+		arrange  func(t *testing.T, l *Limiter)
+		at       time.Time
+		key      string
+		expected Result
+	}
+
+	take := func(t *testing.T, l *Limiter, at time.Time, key string, times int) {
+		l.now = func() time.Time { return at }
+		for range times {
+			_, err := l.Take(context.Background(), key)
+			require.NoError(t, err)
+		}
+	}
+
+	testcases := []Testcase{
+		{
+			name: "first request is allowed",
+			at:   start,
+			key:  "client",
+			expected: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 2,
+				Reset:     time.Minute,
+			},
+		},
+		{
+			name: "request over the limit is rejected",
+			arrange: func(t *testing.T, l *Limiter) {
+				take(t, l, start, "client", 3)
+			},
+			at:  start.Add(10 * time.Second),
+			key: "client",
+			expected: Result{
+				Allowed:   false,
+				Limit:     3,
+				Remaining: 0,
+				Reset:     50 * time.Second,
+			},
+		},
+		{
+			name: "keys are limited independently",
+			arrange: func(t *testing.T, l *Limiter) {
+				take(t, l, start, "client", 3)
+			},
+			at:  start.Add(10 * time.Second),
+			key: "other",
+			expected: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 2,
+				Reset:     50 * time.Second,
+			},
+		},
+		{
+			name: "previous window still counts while it overlaps",
+			arrange: func(t *testing.T, l *Limiter) {
+				take(t, l, start, "client", 3)
+			},
+			at:  start.Add(70 * time.Second),
+			key: "client",
+			expected: Result{
+				Allowed:   false,
+				Limit:     3,
+				Remaining: 0,
+				Reset:     50 * time.Second,
+			},
+		},
+		{
+			name: "previous window is forgotten once the sliding window has passed it",
+			arrange: func(t *testing.T, l *Limiter) {
+				take(t, l, start, "client", 3)
+			},
+			at:  start.Add(2 * time.Minute),
+			key: "client",
+			expected: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 2,
+				Reset:     time.Minute,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLimiter(NewMemoryStore(), policy)
+			if tc.arrange != nil {
+				tc.arrange(t, l)
+			}
+
+			l.now = func() time.Time { return tc.at }
+			result, err := l.Take(context.Background(), tc.key)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	type Testcase struct {
+		name   string
+		policy Policy
+	}
+
+	testcases := []Testcase{
+		{name: "zero limit", policy: Policy{Limit: 0, Window: time.Minute}},
+		{name: "negative limit", policy: Policy{Limit: -1, Window: time.Minute}},
+		{name: "zero window", policy: Policy{Limit: 3}},
+		{name: "negative window", policy: Policy{Limit: 3, Window: -time.Minute}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Panics(t, func() { NewLimiter(NewMemoryStore(), tc.policy) })
+		})
+	}
+}
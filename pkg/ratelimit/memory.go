@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// MemoryStore keeps counters in process, limits are per replica
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:   make(map[string]*memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func (s *MemoryStore) Incr(_ context.Context, bucket string, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+
+	entry, ok := s.entries[bucket]
+	if !ok {
+		entry = &memoryEntry{expiresAt: expiresAt}
+		s.entries[bucket] = entry
+	}
+
+	entry.count++
+	return entry.count, nil
+}
+
+func (s *MemoryStore) Count(_ context.Context, bucket string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[bucket]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, nil
+	}
+
+	return entry.count, nil
+}
+
+// sweep drops expired buckets, callers must hold the lock
+func (s *MemoryStore) sweep() {
+	now := time.Now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+
+	for bucket, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, bucket)
+		}
+	}
+	s.lastSweep = now
+}
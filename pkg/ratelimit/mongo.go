@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/chai-rs/sevenhunter/pkg/mongo/schema"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const mongoCollection = "rate_limits"
+
+type mongoBucket struct {
+	ID        string    `bson:"_id"`
+	Count     int64     `bson:"count"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// MongoStore shares counters between replicas, expired buckets are removed by a TTL index on expires_at
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		collection: db.Collection(mongoCollection),
+	}
+}
+
+var _ Store = (*MongoStore)(nil)
+
+// MongoSchema is the rate_limits collection MongoStore relies on
+var MongoSchema = schema.Collection{
+	Name: mongoCollection,
+	Indexes: []schema.Index{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfterSeconds: lo.ToPtr(int32(0))},
+	},
+}
+
+func (s *MongoStore) Incr(ctx context.Context, bucket string, expiresAt time.Time) (int64, error) {
+	var (
+		b      mongoBucket
+		filter = bson.M{"_id": bucket}
+		update = bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"expires_at": expiresAt},
+		}
+		opts = options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	)
+
+	if err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&b); err != nil {
+		return 0, err
+	}
+
+	return b.Count, nil
+}
+
+func (s *MongoStore) Count(ctx context.Context, bucket string) (int64, error) {
+	var b mongoBucket
+	err := s.collection.FindOne(ctx, bson.M{"_id": bucket}).Decode(&b)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return b.Count, nil
+}
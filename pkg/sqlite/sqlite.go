@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+
+	"github.com/chai-rs/sevenhunter/pkg/health"
+	logx "github.com/chai-rs/sevenhunter/pkg/logger"
+	_ "modernc.org/sqlite"
+)
+
+// Config is only used when users are stored in SQLite, the file is created when it does not exist
+type Config struct {
+	Path string `default:"sevenhunter.db"`
+}
+
+func (conf *Config) New(ctx context.Context) (*sql.DB, error) {
+	if conf.Path == "" {
+		return nil, errors.New("SQLITE_PATH is required")
+	}
+
+	return Open(ctx, conf.Path)
+}
+
+func (conf *Config) MustDB() *sql.DB {
+	db, err := conf.New(context.Background())
+	if err != nil {
+		logx.Panic().Err(err).Msg("failed to open sqlite database")
+	}
+	return db
+}
+
+// Open opens the database file at path with WAL journaling. SQLite allows a single writer, so the pool
+// is limited to one connection and writes queue in process instead of failing with SQLITE_BUSY.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// HealthChecker pings the database
+func HealthChecker(db *sql.DB) health.CheckerFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}